@@ -19,6 +19,10 @@ type ClientConfig struct {
 	InsecureSkipVerify bool   `toml:"insecure_skip_verify"`
 	ServerName         string `toml:"tls_server_name"`
 
+	TLSCipherSuites []string `toml:"tls_cipher_suites"`
+	TLSMinVersion   string   `toml:"tls_min_version"`
+	TLSMaxVersion   string   `toml:"tls_max_version"`
+
 	SSLCA   string `toml:"ssl_ca" deprecated:"1.7.0;use 'tls_ca' instead"`
 	SSLCert string `toml:"ssl_cert" deprecated:"1.7.0;use 'tls_cert' instead"`
 	SSLKey  string `toml:"ssl_key" deprecated:"1.7.0;use 'tls_key' instead"`
@@ -55,9 +59,11 @@ func (c *ClientConfig) TLSConfig() (*tls.Config, error) {
 	// a TLS connection. That is, any of:
 	//     * client certificate settings,
 	//     * peer certificate authorities,
-	//     * disabled security, or
-	//     * an SNI server name.
-	if c.TLSCA == "" && c.TLSKey == "" && c.TLSCert == "" && !c.InsecureSkipVerify && c.ServerName == "" {
+	//     * disabled security,
+	//     * an SNI server name, or
+	//     * restricted cipher suites or versions.
+	if c.TLSCA == "" && c.TLSKey == "" && c.TLSCert == "" && !c.InsecureSkipVerify && c.ServerName == "" &&
+		len(c.TLSCipherSuites) == 0 && c.TLSMinVersion == "" && c.TLSMaxVersion == "" {
 		return nil, nil
 	}
 
@@ -85,6 +91,10 @@ func (c *ClientConfig) TLSConfig() (*tls.Config, error) {
 		tlsConfig.ServerName = c.ServerName
 	}
 
+	if err := setCiphersAndVersions(tlsConfig, "client", c.TLSCipherSuites, c.TLSMinVersion, c.TLSMaxVersion); err != nil {
+		return nil, err
+	}
+
 	return tlsConfig, nil
 }
 
@@ -113,45 +123,54 @@ func (c *ServerConfig) TLSConfig() (*tls.Config, error) {
 		}
 	}
 
-	if len(c.TLSCipherSuites) != 0 {
-		cipherSuites, err := ParseCiphers(c.TLSCipherSuites)
+	if err := setCiphersAndVersions(tlsConfig, "server", c.TLSCipherSuites, c.TLSMinVersion, c.TLSMaxVersion); err != nil {
+		return nil, err
+	}
+
+	// Since clientAuth is tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	// there must be certs to validate.
+	if len(c.TLSAllowedCACerts) > 0 && len(c.TLSAllowedDNSNames) > 0 {
+		tlsConfig.VerifyPeerCertificate = c.verifyPeerCertificate
+	}
+
+	return tlsConfig, nil
+}
+
+// setCiphersAndVersions restricts the cipher suites and TLS versions of the
+// config. The side is either "client" or "server" for the error messages.
+func setCiphersAndVersions(tlsConfig *tls.Config, side string, ciphers []string, minVersion, maxVersion string) error {
+	if len(ciphers) != 0 {
+		cipherSuites, err := ParseCiphers(ciphers)
 		if err != nil {
-			return nil, fmt.Errorf(
-				"could not parse server cipher suites %s: %v", strings.Join(c.TLSCipherSuites, ","), err)
+			return fmt.Errorf(
+				"could not parse %s cipher suites %s: %v", side, strings.Join(ciphers, ","), err)
 		}
 		tlsConfig.CipherSuites = cipherSuites
 	}
 
-	if c.TLSMaxVersion != "" {
-		version, err := ParseTLSVersion(c.TLSMaxVersion)
+	if maxVersion != "" {
+		version, err := ParseTLSVersion(maxVersion)
 		if err != nil {
-			return nil, fmt.Errorf(
-				"could not parse tls max version %q: %v", c.TLSMaxVersion, err)
+			return fmt.Errorf(
+				"could not parse tls max version %q: %v", maxVersion, err)
 		}
 		tlsConfig.MaxVersion = version
 	}
 
-	if c.TLSMinVersion != "" {
-		version, err := ParseTLSVersion(c.TLSMinVersion)
+	if minVersion != "" {
+		version, err := ParseTLSVersion(minVersion)
 		if err != nil {
-			return nil, fmt.Errorf(
-				"could not parse tls min version %q: %v", c.TLSMinVersion, err)
+			return fmt.Errorf(
+				"could not parse tls min version %q: %v", minVersion, err)
 		}
 		tlsConfig.MinVersion = version
 	}
 
 	if tlsConfig.MinVersion != 0 && tlsConfig.MaxVersion != 0 && tlsConfig.MinVersion > tlsConfig.MaxVersion {
-		return nil, fmt.Errorf(
+		return fmt.Errorf(
 			"tls min version %q can't be greater than tls max version %q", tlsConfig.MinVersion, tlsConfig.MaxVersion)
 	}
-
-	// Since clientAuth is tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
-	// there must be certs to validate.
-	if len(c.TLSAllowedCACerts) > 0 && len(c.TLSAllowedDNSNames) > 0 {
-		tlsConfig.VerifyPeerCertificate = c.verifyPeerCertificate
-	}
-
-	return tlsConfig, nil
+	return nil
 }
 
 func makeCertPool(certFiles []string) (*x509.CertPool, error) {
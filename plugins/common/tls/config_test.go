@@ -104,6 +104,33 @@ func TestClientConfig(t *testing.T) {
 			expNil: false,
 			expErr: false,
 		},
+		{
+			name: "restrict ciphers and versions",
+			client: tls.ClientConfig{
+				TLSCipherSuites: []string{pki.CipherSuite()},
+				TLSMinVersion:   pki.TLSMinVersion(),
+				TLSMaxVersion:   pki.TLSMaxVersion(),
+			},
+			expNil: false,
+			expErr: false,
+		},
+		{
+			name: "invalid cipher suite",
+			client: tls.ClientConfig{
+				TLSCipherSuites: []string{"TLS_NOT_A_CIPHER"},
+			},
+			expNil: true,
+			expErr: true,
+		},
+		{
+			name: "min version greater than max version",
+			client: tls.ClientConfig{
+				TLSMinVersion: pki.TLSMaxVersion(),
+				TLSMaxVersion: pki.TLSMinVersion(),
+			},
+			expNil: true,
+			expErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
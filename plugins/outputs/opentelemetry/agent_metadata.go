@@ -0,0 +1,60 @@
+package opentelemetry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+const (
+	agentVersionAttribute          = "telegraf.version"
+	agentConfigHashAttribute       = "telegraf.config_hash"
+	processRuntimeNameAttribute    = "process.runtime.name"
+	processRuntimeVersionAttribute = "process.runtime.version"
+)
+
+// collectAgentMetadata determines the resource attributes describing the
+// agent for include_agent_metadata. The configuration hash covers the
+// settings of the plugin as configured, before environment variables are
+// expanded and defaults applied, so it only changes with the configuration.
+// Outputs never see the configuration of the other plugins.
+func (o *OpenTelemetry) collectAgentMetadata() error {
+	// Signal outputs keep the metadata of the plugin they were copied from.
+	if !o.IncludeAgentMetadata || o.agentAttributes != nil {
+		return nil
+	}
+
+	settings := *o
+	settings.Log = nil
+	buf, err := json.Marshal(&settings)
+	if err != nil {
+		return fmt.Errorf("hashing the configuration failed: %w", err)
+	}
+	sum := sha256.Sum256(buf)
+
+	version := internal.Version()
+	if version == "" {
+		version = "unknown"
+	}
+	o.agentAttributes = map[string]string{
+		agentVersionAttribute:          version,
+		agentConfigHashAttribute:       hex.EncodeToString(sum[:8]),
+		processRuntimeNameAttribute:    "go",
+		processRuntimeVersionAttribute: runtime.Version(),
+	}
+	return nil
+}
+
+// setAgentAttributes adds the agent attributes the resource does not have
+// already, so tags and configured attributes take precedence.
+func (o *OpenTelemetry) setAgentAttributes(resource pcommon.Resource) {
+	for k, v := range o.agentAttributes {
+		resource.Attributes().InsertString(k, v)
+	}
+}
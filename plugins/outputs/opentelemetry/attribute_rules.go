@@ -0,0 +1,94 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"github.com/influxdata/telegraf/filter"
+)
+
+// ruleAttributePrefix marks the tags carrying the attributes of the
+// attribute rules on their way to the resource. The prefix keeps them apart
+// from the tags of the metric.
+const ruleAttributePrefix = "\x00attribute_rule:"
+
+// AttributeRule adds resource attributes to the metrics matching the name
+// filter.
+type AttributeRule struct {
+	NameFilter []string          `toml:"name_filter"`
+	Attributes map[string]string `toml:"attributes"`
+}
+
+type attributeRule struct {
+	filter     filter.Filter
+	attributes map[string]string
+}
+
+// compileAttributeRules compiles the attribute_rule tables and collects the
+// tags carrying their attributes.
+func (o *OpenTelemetry) compileAttributeRules() error {
+	o.attributeRules = make([]attributeRule, 0, len(o.AttributeRules))
+	o.ruleTags = nil
+	seen := make(map[string]bool)
+	for i, rule := range o.AttributeRules {
+		if len(rule.NameFilter) == 0 {
+			return fmt.Errorf("name_filter of attribute_rule %d must not be empty", i+1)
+		}
+		f, err := filter.Compile(rule.NameFilter)
+		if err != nil {
+			return fmt.Errorf("invalid name_filter of attribute_rule %d: %w", i+1, err)
+		}
+		attributes, err := o.expandValues("attribute_rule attributes", rule.Attributes)
+		if err != nil {
+			return err
+		}
+		o.attributeRules = append(o.attributeRules, attributeRule{filter: f, attributes: attributes})
+		for k := range attributes {
+			if !seen[k] {
+				seen[k] = true
+				o.ruleTags = append(o.ruleTags, ruleAttributePrefix+k)
+			}
+		}
+	}
+	return nil
+}
+
+// addRuleAttributes adds the attributes of the rules matching the metric to
+// its tags. Later rules override the attributes of earlier ones.
+func (o *OpenTelemetry) addRuleAttributes(name string, tags map[string]string) {
+	for _, rule := range o.attributeRules {
+		if !rule.filter.Match(name) {
+			continue
+		}
+		for k, v := range rule.attributes {
+			tags[ruleAttributePrefix+k] = v
+		}
+	}
+}
+
+// applyRuleAttributes replaces the promoted rule tags of the resource by the
+// attributes, overriding attributes of the same name. It returns the keys of
+// the attributes set.
+func applyRuleAttributes(resource pcommon.Resource) map[string]bool {
+	values := make(map[string]string)
+	resource.Attributes().Range(func(k string, v pcommon.Value) bool {
+		if strings.HasPrefix(k, ruleAttributePrefix) {
+			values[k] = v.StringVal()
+		}
+		return true
+	})
+	if len(values) == 0 {
+		return nil
+	}
+
+	keys := make(map[string]bool, len(values))
+	for k, v := range values {
+		resource.Attributes().Remove(k)
+		key := strings.TrimPrefix(k, ruleAttributePrefix)
+		upsertTypedAttribute(resource.Attributes(), key, v)
+		keys[key] = true
+	}
+	return keys
+}
@@ -0,0 +1,105 @@
+package opentelemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/influxdata/telegraf"
+)
+
+// valuesFile keeps the values loaded from the file of an option, such as the
+// resource_attributes_file or the headers_file, reading the file again once
+// the interval has passed. If that fails the previous values are kept. The
+// values are replaced as a whole and never modified, so the map returned by
+// get stays consistent while it is used.
+type valuesFile struct {
+	option   string
+	path     string
+	interval time.Duration
+	log      telegraf.Logger
+
+	sync.Mutex
+	values map[string]string
+	loaded time.Time
+}
+
+func newValuesFile(option, path string, interval time.Duration, log telegraf.Logger) (*valuesFile, error) {
+	values, err := loadValuesFile(option, path)
+	if err != nil {
+		return nil, err
+	}
+	return &valuesFile{
+		option:   option,
+		path:     path,
+		interval: interval,
+		log:      log,
+		values:   values,
+		loaded:   time.Now(),
+	}, nil
+}
+
+// get returns the current values of the file.
+func (f *valuesFile) get() map[string]string {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.interval <= 0 || time.Since(f.loaded) < f.interval {
+		return f.values
+	}
+	f.loaded = time.Now()
+
+	values, err := loadValuesFile(f.option, f.path)
+	if err != nil {
+		f.log.Errorf("Reloading %s failed, keeping the previous values: %v", f.option, err)
+		return f.values
+	}
+	f.values = values
+	return f.values
+}
+
+// loadValuesFile reads a flat JSON or TOML table of values, chosen by the
+// extension of the file. Values are strings, numbers or booleans.
+func loadValuesFile(option, path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s failed: %w", option, err)
+	}
+
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	case ".toml":
+		_, err = toml.Decode(string(data), &values)
+	default:
+		return nil, fmt.Errorf("%s %q must be a .json or .toml file", option, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s %q failed: %w", option, path, err)
+	}
+
+	result := make(map[string]string, len(values))
+	for k, v := range values {
+		switch v := v.(type) {
+		case string:
+			result[k] = v
+		case bool:
+			result[k] = strconv.FormatBool(v)
+		case int64:
+			result[k] = strconv.FormatInt(v, 10)
+		case float64:
+			result[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return nil, fmt.Errorf("unsupported value of %q in %s %q", k, option, path)
+		}
+	}
+	return result, nil
+}
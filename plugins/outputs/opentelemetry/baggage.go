@@ -0,0 +1,145 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+const (
+	baggageHeader = "baggage"
+
+	// Limits of the W3C baggage specification.
+	maxBaggageMembers = 180
+	maxBaggageSize    = 8192
+)
+
+// compileBaggage validates the baggage entries and baggage_tags and adds the
+// baggage header of the configured entries to the headers.
+func (o *OpenTelemetry) compileBaggage() error {
+	if len(o.Baggage) == 0 && len(o.BaggageTags) == 0 {
+		return nil
+	}
+	for k := range o.Headers {
+		if strings.EqualFold(k, baggageHeader) {
+			return fmt.Errorf("baggage and baggage_tags cannot be combined with a %q header", baggageHeader)
+		}
+	}
+	if len(o.Baggage)+len(o.BaggageTags) > maxBaggageMembers {
+		return fmt.Errorf("baggage and baggage_tags must not have more than %d entries", maxBaggageMembers)
+	}
+
+	var err error
+	if o.Baggage, err = o.expandValues("baggage", o.Baggage); err != nil {
+		return err
+	}
+	for k := range o.Baggage {
+		if !isBaggageKey(k) {
+			return fmt.Errorf("invalid baggage key %q", k)
+		}
+	}
+	for _, tag := range o.BaggageTags {
+		if !isBaggageKey(tag) {
+			return fmt.Errorf("invalid baggage key %q in baggage_tags", tag)
+		}
+	}
+	if len(o.Baggage) == 0 {
+		return nil
+	}
+
+	header := encodeBaggage(o.Baggage)
+	if len(header) > maxBaggageSize {
+		return fmt.Errorf("baggage of %d bytes exceeds the limit of %d bytes", len(header), maxBaggageSize)
+	}
+	headers := make(map[string]string, len(o.Headers)+1)
+	for k, v := range o.Headers {
+		headers[k] = v
+	}
+	headers[baggageHeader] = header
+	o.Headers = headers
+	return nil
+}
+
+// tagBaggage returns the baggage header of the configured entries and the
+// baggage_tags of the metric, or an empty string if the metric has none of
+// the tags. Tags override configured entries of the same key. If the header
+// would exceed the size limit, the configured entries are sent only.
+func (o *OpenTelemetry) tagBaggage(metric telegraf.Metric) string {
+	var entries map[string]string
+	for _, tag := range o.BaggageTags {
+		value, ok := metric.GetTag(tag)
+		if !ok {
+			continue
+		}
+		if entries == nil {
+			entries = make(map[string]string, len(o.Baggage)+len(o.BaggageTags))
+			for k, v := range o.Baggage {
+				entries[k] = v
+			}
+		}
+		entries[tag] = value
+	}
+	if entries == nil {
+		return ""
+	}
+	header := encodeBaggage(entries)
+	if len(header) > maxBaggageSize {
+		return ""
+	}
+	return header
+}
+
+// encodeBaggage returns the W3C baggage header of the entries, sorted by key.
+func encodeBaggage(entries map[string]string) string {
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	members := make([]string, 0, len(keys))
+	for _, k := range keys {
+		members = append(members, k+"="+encodeBaggageValue(entries[k]))
+	}
+	return strings.Join(members, ",")
+}
+
+// encodeBaggageValue percent-encodes the bytes of the value that are not
+// allowed in a baggage value, as well as the percent sign.
+func encodeBaggageValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if isBaggageOctet(c) && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// isBaggageOctet reports whether c is allowed unencoded in a baggage value,
+// which excludes control characters, whitespace, '"', ',', ';' and '\'.
+func isBaggageOctet(c byte) bool {
+	return c == 0x21 || c >= 0x23 && c <= 0x2b || c >= 0x2d && c <= 0x3a || c >= 0x3c && c <= 0x5b || c >= 0x5d && c <= 0x7e
+}
+
+// isBaggageKey reports whether the key is a valid HTTP token.
+func isBaggageKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
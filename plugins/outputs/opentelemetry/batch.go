@@ -0,0 +1,153 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+)
+
+const defaultMaxBatchHold = config.Duration(10 * time.Second)
+
+// batcher coalesces the converted metrics of successive writes into batches
+// of the preferred number of data points. Full batches are exported right
+// away; the remainder is held until later writes fill it up or the hold time
+// has passed.
+type batcher struct {
+	size   int
+	hold   time.Duration
+	export func(metrics pmetric.Metrics, headers map[string]string) error
+	log    telegraf.Logger
+
+	mu      sync.Mutex
+	pending map[string]*pendingBatch
+	// flushes tracks the hold timers, so closing can wait for running ones.
+	flushes sync.WaitGroup
+}
+
+// pendingBatch is the remainder held for the requests with the headers.
+type pendingBatch struct {
+	headers map[string]string
+	metrics pmetric.Metrics
+	timer   *time.Timer
+}
+
+func newBatcher(size int, hold time.Duration, export func(pmetric.Metrics, map[string]string) error, log telegraf.Logger) *batcher {
+	return &batcher{size: size, hold: hold, export: export, log: log, pending: make(map[string]*pendingBatch)}
+}
+
+// add appends the metrics to the held ones and exports the full batches.
+func (b *batcher) add(metrics pmetric.Metrics, headers map[string]string) error {
+	key := headersKey(headers)
+
+	b.mu.Lock()
+	p, ok := b.pending[key]
+	if !ok {
+		p = &pendingBatch{headers: headers, metrics: pmetric.NewMetrics()}
+		b.pending[key] = p
+	}
+	metrics.ResourceMetrics().MoveAndAppendTo(p.metrics.ResourceMetrics())
+	if p.metrics.DataPointCount() < b.size {
+		b.holdLocked(key, p)
+		b.mu.Unlock()
+		return nil
+	}
+
+	full := splitMetricsByCount(p.metrics, b.size)
+	if last := full[len(full)-1]; last.DataPointCount() < b.size {
+		p.metrics = last
+		full = full[:len(full)-1]
+		b.holdLocked(key, p)
+	} else {
+		b.removeLocked(key, p)
+	}
+	b.mu.Unlock()
+
+	for _, batch := range full {
+		if err := b.export(batch, headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// holdLocked starts the hold timer of the remainder unless it is running
+// already, in which case the remainder is flushed with the data points held
+// before.
+func (b *batcher) holdLocked(key string, p *pendingBatch) {
+	if p.timer == nil {
+		b.flushes.Add(1)
+		p.timer = time.AfterFunc(b.hold, func() { b.flushHeld(key, p) })
+	}
+}
+
+// flushHeld exports the remainder once the hold time has passed.
+func (b *batcher) flushHeld(key string, p *pendingBatch) {
+	defer b.flushes.Done()
+
+	b.mu.Lock()
+	if b.pending[key] != p {
+		// Exported meanwhile as part of a full batch.
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, key)
+	b.mu.Unlock()
+
+	b.exportHeld(p)
+}
+
+// flushAll exports all held remainders and waits for running flushes.
+func (b *batcher) flushAll() {
+	b.mu.Lock()
+	held := make([]*pendingBatch, 0, len(b.pending))
+	for key, p := range b.pending {
+		b.removeLocked(key, p)
+		held = append(held, p)
+	}
+	b.mu.Unlock()
+
+	for _, p := range held {
+		b.exportHeld(p)
+	}
+	b.flushes.Wait()
+}
+
+func (b *batcher) exportHeld(p *pendingBatch) {
+	count := p.metrics.DataPointCount()
+	if count == 0 {
+		return
+	}
+	if err := b.export(p.metrics, p.headers); err != nil {
+		b.log.Errorf("Exporting %d held data points failed: %v", count, err)
+	}
+}
+
+// removeLocked drops the held remainder and stops its hold timer.
+func (b *batcher) removeLocked(key string, p *pendingBatch) {
+	delete(b.pending, key)
+	if p.timer != nil && p.timer.Stop() {
+		b.flushes.Done()
+	}
+}
+
+// headersKey identifies the headers of the requests.
+func headersKey(headers map[string]string) string {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var key strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&key, "%q=%q,", k, headers[k])
+	}
+	return key.String()
+}
@@ -0,0 +1,134 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+)
+
+const defaultMaxSeriesWindow = time.Hour
+
+// seriesLimiter caps the number of distinct series, identified by the
+// resource attributes, the metric name and the data point attributes,
+// exported within a window. Once the cap is reached, data points of new
+// series lose their high cardinality attributes and are kept if that
+// collapses them into a known series, and dropped otherwise. The series are
+// forgotten when the window ends.
+type seriesLimiter struct {
+	max           int
+	window        time.Duration
+	stripPatterns filter.Filter
+
+	mu      sync.Mutex
+	series  map[string]bool
+	started time.Time
+}
+
+func newSeriesLimiter(max int, window time.Duration, strip filter.Filter) *seriesLimiter {
+	return &seriesLimiter{max: max, window: window, stripPatterns: strip, series: make(map[string]bool)}
+}
+
+// compileSeriesLimit checks max_series and max_series_window and compiles
+// high_cardinality_attributes.
+func (o *OpenTelemetry) compileSeriesLimit() error {
+	if o.MaxSeries < 0 {
+		return fmt.Errorf("max_series must not be negative")
+	}
+	if o.MaxSeriesWindow < 0 {
+		return fmt.Errorf("max_series_window must not be negative")
+	}
+	if o.MaxSeriesWindow == 0 {
+		o.MaxSeriesWindow = config.Duration(defaultMaxSeriesWindow)
+	}
+	var err error
+	if o.cardinalityFilter, err = filter.Compile(o.HighCardinalityAttributes); err != nil {
+		return fmt.Errorf("invalid high_cardinality_attributes: %w", err)
+	}
+	return nil
+}
+
+// limit removes the data points of series beyond the cap, and returns the
+// number of data points collapsed and dropped.
+func (l *seriesLimiter) limit(metrics pmetric.Metrics, now time.Time) (collapsed, dropped int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.started) >= l.window {
+		l.series = make(map[string]bool, len(l.series))
+		l.started = now
+	}
+
+	metrics.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		rKey := attributesToKey(rm.Resource().Attributes().Sort())
+		rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+			sm.Metrics().RemoveIf(func(metric pmetric.Metric) bool {
+				mKey := rKey + "|" + metric.Name() + "|"
+				attributes := dataPointAttributes(metric)
+				var i int
+				removeDataPoints(metric, func() bool {
+					keep, stripped := l.admit(mKey, attributes[i])
+					i++
+					if stripped && keep {
+						collapsed++
+					}
+					if !keep {
+						dropped++
+					}
+					return !keep
+				})
+				return len(dataPointAttributes(metric)) == 0
+			})
+			return sm.Metrics().Len() == 0
+		})
+		return rm.ScopeMetrics().Len() == 0
+	})
+	return collapsed, dropped
+}
+
+// admit reports whether the data point with the attributes is kept and
+// whether its high cardinality attributes were removed.
+func (l *seriesLimiter) admit(mKey string, attributes pcommon.Map) (keep, stripped bool) {
+	key := mKey + attributesToKey(attributes.Sort())
+	if l.series[key] {
+		return true, false
+	}
+	if len(l.series) < l.max {
+		l.series[key] = true
+		return true, false
+	}
+	if l.stripPatterns == nil {
+		return false, false
+	}
+
+	var removed bool
+	attributes.RemoveIf(func(k string, _ pcommon.Value) bool {
+		if l.stripPatterns.Match(k) {
+			removed = true
+			return true
+		}
+		return false
+	})
+	if !removed {
+		return false, false
+	}
+	return l.series[mKey+attributesToKey(attributes.Sort())], true
+}
+
+// limitSeries applies max_series to the metrics and reports the data points
+// collapsed and dropped.
+func (o *OpenTelemetry) limitSeries(metrics pmetric.Metrics) {
+	collapsed, dropped := o.seriesLimiter.limit(metrics, time.Now())
+	if collapsed > 0 {
+		o.Log.Debugf("Removed the high_cardinality_attributes of %d data points of new series exceeding max_series", collapsed)
+	}
+	if dropped > 0 {
+		o.stats.seriesDropped.Incr(int64(dropped))
+		o.Log.Warnf("Dropped %d data points of new series exceeding the max_series of %d within %s", dropped, o.MaxSeries, o.MaxSeriesWindow)
+	}
+}
@@ -0,0 +1,87 @@
+package opentelemetry
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+)
+
+const defaultCircuitBreakerCooldown = config.Duration(30 * time.Second)
+
+var errCircuitOpen = errors.New("circuit breaker open, export skipped")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker stops exporting after a number of consecutive transient
+// failures. While open, exports fail immediately. After the cooldown a
+// single export probes the collector and closes the circuit on success or
+// opens it again on failure.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	log       telegraf.Logger
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, log telegraf.Logger) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, log: log}
+}
+
+// allow reports whether an export may be attempted.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = circuitHalfOpen
+		b.log.Info("Circuit breaker half-open, probing the collector")
+		return true
+	case circuitHalfOpen:
+		// Only the probe is attempted.
+		return false
+	}
+	return true
+}
+
+// record updates the state with the result of an allowed export.
+func (b *circuitBreaker) record(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || !shouldPersist(err) {
+		if b.state != circuitClosed {
+			b.log.Info("Circuit breaker closed, the collector recovered")
+		}
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		if b.state == circuitHalfOpen {
+			b.log.Warnf("Circuit breaker opened again, probe failed: %v", err)
+		} else {
+			b.log.Warnf("Circuit breaker opened after %d consecutive failures, pausing exports for %s", b.failures, b.cooldown)
+		}
+		b.state = circuitOpen
+		b.openedAt = now
+	}
+}
@@ -0,0 +1,249 @@
+package opentelemetry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+const (
+	compressionZstd   = "zstd"
+	compressionSnappy = "snappy"
+)
+
+// compressors are the gRPC compressors of this plugin, registered only once
+// an output sends with them over gRPC.
+var compressors = map[string]encoding.Compressor{
+	compressionZstd:   &zstdCompressor{},
+	compressionSnappy: &snappyCompressor{},
+}
+
+var compressorsMu sync.Mutex
+
+// registerCompressor registers the gRPC compressor of the compression method
+// unless one is registered already. The registry of gRPC is process-wide, so
+// the compressor is also accepted by the gRPC servers of the process, such
+// as the one of the opentelemetry input. Plugins are initialized before any
+// of them connects, as gRPC requires for registering compressors.
+func registerCompressor(compression string) {
+	compressor, ok := compressors[compression]
+	if !ok {
+		return
+	}
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	if encoding.GetCompressor(compression) == nil {
+		encoding.RegisterCompressor(compressor)
+	}
+}
+
+// checkCompression returns an error if the compression method is neither
+// one of this plugin nor registered with gRPC. The HTTP transport supports
+// the same methods.
+func checkCompression(compression string) error {
+	if _, ok := compressors[compression]; ok || compression == "none" {
+		return nil
+	}
+	if encoding.GetCompressor(compression) == nil {
+		return fmt.Errorf("unsupported compression %q", compression)
+	}
+	return nil
+}
+
+// isCompressionUnsupported reports whether the gRPC export failed as the
+// server has no decompressor for the compression of the request.
+func isCompressionUnsupported(err error) bool {
+	s, ok := status.FromError(err)
+	return ok && s.Code() == codes.Unimplemented && strings.Contains(s.Message(), "grpc-encoding")
+}
+
+// fallBackToUncompressed switches to sending uncompressed requests if the
+// export failed for the compression and compression_fallback is enabled. It
+// reports whether the export should be repeated uncompressed.
+func (o *OpenTelemetry) fallBackToUncompressed(err error) bool {
+	if !o.CompressionFallback || !isCompressionUnsupported(err) {
+		return false
+	}
+	if atomic.CompareAndSwapInt32(&o.uncompressed, 0, 1) {
+		o.Log.Warnf("Collector does not support %q compression, sending uncompressed requests: %v", o.Compression, err)
+	}
+	return true
+}
+
+// uncompressedCallOption overrides the compressor of the call and of the
+// connection.
+var uncompressedCallOption = grpc.UseCompressor(encoding.Identity)
+
+// newContentEncoder returns the encoder for the HTTP request body. A level of
+// zero selects the default level of gzip.
+func newContentEncoder(compression string, level int) (internal.ContentEncoder, error) {
+	switch compression {
+	case "gzip":
+		if level != 0 {
+			return newGzipEncoder(level)
+		}
+		return internal.NewContentEncoder(compression)
+	case compressionZstd:
+		return newZstdEncoder()
+	case compressionSnappy:
+		return &snappyEncoder{}, nil
+	case "none":
+		return internal.NewIdentityEncoder(), nil
+	default:
+		return internal.NewContentEncoder(compression)
+	}
+}
+
+// zstdCompressor implements the gRPC zstd compressor, which grpc-go does not
+// ship itself.
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+func (*zstdCompressor) Name() string {
+	return compressionZstd
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	if enc, ok := c.encoders.Get().(*zstd.Encoder); ok {
+		enc.Reset(w)
+		return &zstdWriter{Encoder: enc, pool: &c.encoders}, nil
+	}
+	enc, err := zstd.NewWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	return &zstdWriter{Encoder: enc, pool: &c.encoders}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	if dec, ok := c.decoders.Get().(*zstdReader); ok {
+		if err := dec.Reset(r); err != nil {
+			return nil, err
+		}
+		dec.done = false
+		return dec, nil
+	}
+	dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdReader{Decoder: dec, pool: &c.decoders}, nil
+}
+
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.pool.Put(w.Encoder)
+	return err
+}
+
+// zstdReader returns its decoder to the pool once the message is fully read.
+type zstdReader struct {
+	*zstd.Decoder
+	pool *sync.Pool
+	done bool
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	n, err := r.Decoder.Read(p)
+	if err == io.EOF && !r.done {
+		r.done = true
+		r.pool.Put(r)
+	}
+	return n, err
+}
+
+// snappyCompressor implements the gRPC snappy compressor using the framed
+// stream format, like the OpenTelemetry Collector.
+type snappyCompressor struct{}
+
+func (*snappyCompressor) Name() string {
+	return compressionSnappy
+}
+
+func (*snappyCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return snappy.NewBufferedWriter(w), nil
+}
+
+func (*snappyCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return snappy.NewReader(r), nil
+}
+
+// gzipEncoder compresses the HTTP request body using gzip at the given level.
+type gzipEncoder struct {
+	writer *gzip.Writer
+	buf    *bytes.Buffer
+}
+
+func newGzipEncoder(level int) (*gzipEncoder, error) {
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	return &gzipEncoder{writer: writer, buf: &buf}, nil
+}
+
+func (e *gzipEncoder) Encode(data []byte) ([]byte, error) {
+	e.buf.Reset()
+	e.writer.Reset(e.buf)
+
+	if _, err := e.writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := e.writer.Close(); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// zstdEncoders are the encoders of the HTTP request bodies, reused as
+// creating a zstd encoder allocates several megabytes.
+var zstdEncoders sync.Pool
+
+// zstdEncoder compresses the HTTP request body using zstd at the default
+// level.
+type zstdEncoder struct{}
+
+func newZstdEncoder() (*zstdEncoder, error) {
+	return &zstdEncoder{}, nil
+}
+
+func (*zstdEncoder) Encode(data []byte) ([]byte, error) {
+	encoder, ok := zstdEncoders.Get().(*zstd.Encoder)
+	if !ok {
+		var err error
+		if encoder, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	}
+	defer zstdEncoders.Put(encoder)
+	return encoder.EncodeAll(data, nil), nil
+}
+
+// snappyEncoder compresses the HTTP request body using the snappy block
+// format, as expected by the OTLP/HTTP receiver of the collector.
+type snappyEncoder struct{}
+
+func (*snappyEncoder) Encode(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
@@ -0,0 +1,56 @@
+package opentelemetry
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+
+	"github.com/influxdata/telegraf"
+)
+
+// watchConnectivity logs the state changes of the connection until it is
+// closed and closes done then. Repeated reconnection attempts after a
+// failure are only logged once. The time from the start of the last
+// connection attempt until the connection is ready is passed to connected,
+// an attempt under way when the watch starts being timed from dialed.
+func watchConnectivity(conn *grpc.ClientConn, address string, log telegraf.Logger, dialed time.Time, connected func(time.Duration), done chan<- struct{}) {
+	defer close(done)
+
+	connecting := dialed
+	state := conn.GetState()
+	if state == connectivity.Ready {
+		// The dial blocked until the connection was ready.
+		connected(time.Since(dialed))
+		connecting = time.Time{}
+	}
+	// No state change follows the shutdown, so waiting for one would block
+	// forever if the connection was closed before the watch started.
+	for state != connectivity.Shutdown {
+		if !conn.WaitForStateChange(context.Background(), state) {
+			return
+		}
+		previous := state
+		state = conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			log.Infof("Connected to %q", address)
+			if !connecting.IsZero() {
+				connected(time.Since(connecting))
+				connecting = time.Time{}
+			}
+		case connectivity.TransientFailure:
+			if previous != connectivity.TransientFailure {
+				log.Warnf("Connection to %q failed, reconnecting", address)
+			}
+		case connectivity.Connecting:
+			connecting = time.Now()
+			if previous == connectivity.Ready || previous == connectivity.Idle {
+				log.Infof("Connecting to %q", address)
+			}
+		case connectivity.Idle:
+			log.Infof("Connection to %q is idle", address)
+		}
+	}
+}
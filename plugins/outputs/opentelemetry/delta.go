@@ -0,0 +1,216 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/influxdata/telegraf/filter"
+)
+
+const (
+	temporalityCumulative = "cumulative"
+	temporalityDelta      = "delta"
+
+	// deltaStaleness is how long the last value of a series is kept without
+	// receiving a new one.
+	deltaStaleness = time.Hour
+)
+
+// deltaSeries is the last cumulative value seen for a series.
+type deltaSeries struct {
+	timestamp    pcommon.Timestamp
+	intValue     int64
+	doubleValue  float64
+	count        uint64
+	sum          float64
+	bucketCounts []uint64
+	lastSeen     time.Time
+}
+
+// deltaConverter turns cumulative sums and histograms into deltas against
+// the previous value of the same series. The first value of a series only
+// serves as the baseline and is not sent. A value lower than the previous
+// one is taken as a counter reset and sent unchanged.
+type deltaConverter struct {
+	series map[string]*deltaSeries
+}
+
+func newDeltaConverter() *deltaConverter {
+	return &deltaConverter{series: make(map[string]*deltaSeries)}
+}
+
+// convert turns the sums and histograms with a name accepted by delta into
+// deltas, all of them if delta is nil. The others stay cumulative.
+func (c *deltaConverter) convert(metrics pmetric.Metrics, now time.Time, delta func(name string) bool) {
+	metrics.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		rKey := attributesToKey(rm.Resource().Attributes().Sort())
+		rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+			sKey := rKey + "|" + sm.Scope().Name() + ":" + sm.Scope().Version()
+			sm.Metrics().RemoveIf(func(metric pmetric.Metric) bool {
+				if delta != nil && !delta(metric.Name()) {
+					return false
+				}
+				mKey := sKey + "|" + metric.Name() + "|"
+				switch metric.DataType() {
+				case pmetric.MetricDataTypeSum:
+					sum := metric.Sum()
+					if sum.AggregationTemporality() != pmetric.MetricAggregationTemporalityCumulative {
+						return false
+					}
+					sum.SetAggregationTemporality(pmetric.MetricAggregationTemporalityDelta)
+					sum.DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool {
+						return !c.convertNumber(mKey+attributesToKey(dp.Attributes().Sort()), dp, now)
+					})
+					return sum.DataPoints().Len() == 0
+				case pmetric.MetricDataTypeHistogram:
+					histogram := metric.Histogram()
+					if histogram.AggregationTemporality() != pmetric.MetricAggregationTemporalityCumulative {
+						return false
+					}
+					histogram.SetAggregationTemporality(pmetric.MetricAggregationTemporalityDelta)
+					histogram.DataPoints().RemoveIf(func(dp pmetric.HistogramDataPoint) bool {
+						return !c.convertHistogram(mKey+attributesToKey(dp.Attributes().Sort()), dp, now)
+					})
+					return histogram.DataPoints().Len() == 0
+				}
+				return false
+			})
+			return sm.Metrics().Len() == 0
+		})
+		return rm.ScopeMetrics().Len() == 0
+	})
+
+	for key, s := range c.series {
+		if now.Sub(s.lastSeen) > deltaStaleness {
+			delete(c.series, key)
+		}
+	}
+}
+
+// convertNumber replaces the cumulative value of the data point by the delta
+// and reports whether the data point should be sent.
+func (c *deltaConverter) convertNumber(key string, dp pmetric.NumberDataPoint, now time.Time) bool {
+	// A stale data point ends the series.
+	if isStale(dp) {
+		delete(c.series, key)
+		return true
+	}
+	prev, found := c.series[key]
+	c.series[key] = &deltaSeries{
+		timestamp:   dp.Timestamp(),
+		intValue:    dp.IntVal(),
+		doubleValue: dp.DoubleVal(),
+		lastSeen:    now,
+	}
+	if !found {
+		return false
+	}
+
+	switch dp.ValueType() {
+	case pmetric.NumberDataPointValueTypeInt:
+		if dp.IntVal() < prev.intValue {
+			return true
+		}
+		dp.SetIntVal(dp.IntVal() - prev.intValue)
+	case pmetric.NumberDataPointValueTypeDouble:
+		if dp.DoubleVal() < prev.doubleValue {
+			return true
+		}
+		dp.SetDoubleVal(dp.DoubleVal() - prev.doubleValue)
+	}
+	dp.SetStartTimestamp(prev.timestamp)
+	return true
+}
+
+// convertHistogram replaces the cumulative count, sum and bucket counts of
+// the data point by their deltas and reports whether the data point should
+// be sent.
+func (c *deltaConverter) convertHistogram(key string, dp pmetric.HistogramDataPoint, now time.Time) bool {
+	bucketCounts := dp.BucketCounts().AsRaw()
+	prev, found := c.series[key]
+	c.series[key] = &deltaSeries{
+		timestamp:    dp.Timestamp(),
+		count:        dp.Count(),
+		sum:          dp.Sum(),
+		bucketCounts: bucketCounts,
+		lastSeen:     now,
+	}
+	if !found {
+		return false
+	}
+	if dp.Count() < prev.count || len(bucketCounts) != len(prev.bucketCounts) {
+		return true
+	}
+
+	deltas := make([]uint64, len(bucketCounts))
+	for i, count := range bucketCounts {
+		if count < prev.bucketCounts[i] {
+			return true
+		}
+		deltas[i] = count - prev.bucketCounts[i]
+	}
+	dp.SetCount(dp.Count() - prev.count)
+	dp.SetSum(dp.Sum() - prev.sum)
+	dp.SetBucketCounts(pcommon.NewImmutableUInt64Slice(deltas))
+	dp.SetStartTimestamp(prev.timestamp)
+	return true
+}
+
+// temporalityOverride sets the temporality of the measurements matching the
+// pattern.
+type temporalityOverride struct {
+	filter      filter.Filter
+	temporality string
+}
+
+// compileTemporality compiles the temporality table. Longer patterns take
+// precedence.
+func (o *OpenTelemetry) compileTemporality() error {
+	patterns := sortedPatterns(o.Temporality)
+	o.temporalities = make([]temporalityOverride, 0, len(patterns))
+	for _, pattern := range patterns {
+		temporality := o.Temporality[pattern]
+		switch temporality {
+		case temporalityCumulative, temporalityDelta:
+		default:
+			return fmt.Errorf("unsupported temporality %q for %q in temporality", temporality, pattern)
+		}
+		f, err := filter.Compile([]string{pattern})
+		if err != nil {
+			return fmt.Errorf("invalid temporality pattern %q: %w", pattern, err)
+		}
+		o.temporalities = append(o.temporalities, temporalityOverride{filter: f, temporality: temporality})
+	}
+	return nil
+}
+
+// temporalityOf returns the temporality of the first pattern matching the
+// measurement if it differs from the aggregation_temporality.
+func (o *OpenTelemetry) temporalityOf(measurement string) string {
+	for _, override := range o.temporalities {
+		if override.filter.Match(measurement) {
+			if override.temporality == o.AggregationTemporality {
+				return ""
+			}
+			return override.temporality
+		}
+	}
+	return ""
+}
+
+// usesTemporality reports whether any metric may be sent with the
+// temporality.
+func (o *OpenTelemetry) usesTemporality(temporality string) bool {
+	if o.AggregationTemporality == temporality {
+		return true
+	}
+	for _, override := range o.temporalities {
+		if override.temporality == temporality {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package opentelemetry
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/influxdata/telegraf/filter"
+)
+
+// descriptionMapping sets the description of the fields matching the
+// pattern.
+type descriptionMapping struct {
+	filter      filter.Filter
+	description string
+}
+
+// compileDescriptions compiles the descriptions table. As for units, longer
+// patterns take precedence.
+func (o *OpenTelemetry) compileDescriptions() error {
+	patterns := sortedPatterns(o.Descriptions)
+	o.descriptions = make([]descriptionMapping, 0, len(patterns))
+	for _, pattern := range patterns {
+		f, err := filter.Compile([]string{pattern})
+		if err != nil {
+			return fmt.Errorf("invalid descriptions pattern %q: %w", pattern, err)
+		}
+		o.descriptions = append(o.descriptions, descriptionMapping{filter: f, description: o.Descriptions[pattern]})
+	}
+	return nil
+}
+
+// descriptionOf returns the description of the field from the descriptions
+// table, falling back to the description tag of the metric.
+func (o *OpenTelemetry) descriptionOf(measurement, field, descriptionTag string) string {
+	for _, mapping := range o.descriptions {
+		if mapping.filter.Match(measurement+"."+field) || mapping.filter.Match(measurement) {
+			return mapping.description
+		}
+	}
+	return descriptionTag
+}
+
+// setDescriptions sets the description of the metrics with the given names.
+func setDescriptions(metrics pmetric.Metrics, descriptions map[string]string) {
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+				if description, ok := descriptions[metric.Name()]; ok {
+					metric.SetDescription(description)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package opentelemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+const (
+	signalMetrics = "metrics"
+	signalTraces  = "traces"
+	signalLogs    = "logs"
+
+	defaultDiskQueueMaxSize = config.Size(100 * 1024 * 1024)
+
+	diskQueueTempSuffix    = ".tmp"
+	diskQueueHeadersSuffix = ".headers"
+)
+
+// diskQueueFile is a request persisted to the disk queue. The name orders
+// the files by the time they were added and ends in the signal. The headers
+// of the request, if any, are stored next to it as JSON in a file with the
+// same name ending in ".headers".
+type diskQueueFile struct {
+	name string
+	size int64
+}
+
+// diskQueue persists export requests that failed with a transient error, so
+// they survive collector outages and restarts. Every request is stored in a
+// file of its own as serialized OTLP protobuf. When the size cap is reached
+// the oldest requests are dropped.
+type diskQueue struct {
+	dir     string
+	maxSize int64
+
+	mu    sync.Mutex
+	files []diskQueueFile
+	size  int64
+	seq   uint64
+
+	// replayMu ensures only one replay runs at a time.
+	replayMu sync.Mutex
+}
+
+// openDiskQueue creates the directory if needed and loads the requests
+// persisted by earlier runs.
+func openDiskQueue(dir string, maxSize int64) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating disk queue directory failed: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading disk queue directory failed: %w", err)
+	}
+
+	q := &diskQueue{dir: dir, maxSize: maxSize}
+	// The entries are sorted by name and thereby by the time they were added.
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := entry.Name()
+		if strings.HasSuffix(name, diskQueueTempSuffix) {
+			// Left over from an interrupted write.
+			_ = os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if strings.HasSuffix(name, diskQueueHeadersSuffix) {
+			request := strings.TrimSuffix(name, diskQueueHeadersSuffix)
+			if _, err := os.Stat(filepath.Join(dir, request)); errors.Is(err, os.ErrNotExist) {
+				// Left over from an interrupted write.
+				_ = os.Remove(filepath.Join(dir, name))
+			}
+			continue
+		}
+		switch signalOf(name) {
+		case signalMetrics, signalTraces, signalLogs:
+		default:
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("reading disk queue file failed: %w", err)
+		}
+		q.files = append(q.files, diskQueueFile{name: name, size: info.Size()})
+		q.size += info.Size()
+	}
+	return q, nil
+}
+
+// push persists the request and its headers and returns the number of older
+// requests dropped to stay within the size cap.
+func (q *diskQueue) push(signal string, data []byte, headers map[string]string) (int, error) {
+	size := int64(len(data))
+	if size > q.maxSize {
+		return 0, fmt.Errorf("request of %d bytes exceeds the disk_queue_max_size of %d bytes", size, q.maxSize)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	name := fmt.Sprintf("%020d-%010d.%s", time.Now().UnixNano(), q.seq, signal)
+	path := filepath.Join(q.dir, name)
+	if len(headers) > 0 {
+		buf, err := json.Marshal(headers)
+		if err != nil {
+			return 0, fmt.Errorf("serializing headers failed: %w", err)
+		}
+		if err := os.WriteFile(path+diskQueueHeadersSuffix, buf, 0o600); err != nil {
+			return 0, fmt.Errorf("writing to disk queue failed: %w", err)
+		}
+	}
+	// Write to a temporary file first, so an interrupted write never leaves a
+	// truncated request behind.
+	if err := os.WriteFile(path+diskQueueTempSuffix, data, 0o600); err != nil {
+		return 0, fmt.Errorf("writing to disk queue failed: %w", err)
+	}
+	if err := os.Rename(path+diskQueueTempSuffix, path); err != nil {
+		return 0, fmt.Errorf("writing to disk queue failed: %w", err)
+	}
+
+	var dropped int
+	for len(q.files) > 0 && q.size+size > q.maxSize {
+		q.removeLocked(q.files[0].name)
+		dropped++
+	}
+	q.files = append(q.files, diskQueueFile{name: name, size: size})
+	q.size += size
+	return dropped, nil
+}
+
+// oldest returns the oldest persisted request.
+func (q *diskQueue) oldest() (diskQueueFile, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.files) == 0 {
+		return diskQueueFile{}, false
+	}
+	return q.files[0], true
+}
+
+// len returns the number of persisted requests.
+func (q *diskQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.files)
+}
+
+// load reads the persisted request.
+func (q *diskQueue) load(f diskQueueFile) (exportCall, error) {
+	data, err := os.ReadFile(filepath.Join(q.dir, f.name))
+	if err != nil {
+		return exportCall{}, err
+	}
+	call, err := requestFromDisk(signalOf(f.name), data)
+	if err != nil {
+		return exportCall{}, err
+	}
+	buf, err := os.ReadFile(filepath.Join(q.dir, f.name+diskQueueHeadersSuffix))
+	if errors.Is(err, os.ErrNotExist) {
+		return call, nil
+	}
+	if err != nil {
+		return exportCall{}, err
+	}
+	if err := json.Unmarshal(buf, &call.headers); err != nil {
+		return exportCall{}, fmt.Errorf("reading headers failed: %w", err)
+	}
+	return call, nil
+}
+
+func (q *diskQueue) remove(f diskQueueFile) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.removeLocked(f.name)
+}
+
+func (q *diskQueue) removeLocked(name string) {
+	for i, f := range q.files {
+		if f.name == name {
+			q.files = append(q.files[:i], q.files[i+1:]...)
+			q.size -= f.size
+			break
+		}
+	}
+	_ = os.Remove(filepath.Join(q.dir, name))
+	_ = os.Remove(filepath.Join(q.dir, name+diskQueueHeadersSuffix))
+}
+
+func signalOf(name string) string {
+	return strings.TrimPrefix(filepath.Ext(name), ".")
+}
+
+// requestFromDisk decodes a persisted request of the signal.
+func requestFromDisk(signal string, data []byte) (exportCall, error) {
+	switch signal {
+	case signalMetrics:
+		md := pmetricotlp.NewRequest()
+		if err := md.UnmarshalProto(data); err != nil {
+			return exportCall{}, err
+		}
+		return newMetricsCall(md), nil
+	case signalTraces:
+		td := ptraceotlp.NewRequest()
+		if err := td.UnmarshalProto(data); err != nil {
+			return exportCall{}, err
+		}
+		return newTracesCall(td), nil
+	case signalLogs:
+		ld := plogotlp.NewRequest()
+		if err := ld.UnmarshalProto(data); err != nil {
+			return exportCall{}, err
+		}
+		return newLogsCall(ld), nil
+	}
+	return exportCall{}, fmt.Errorf("unknown signal %q", signal)
+}
+
+// persist adds the request that failed to export to the disk queue. It
+// reports whether the request was persisted.
+func (o *OpenTelemetry) persist(call exportCall, exportErr error) bool {
+	// Exports cancelled on closing are persisted whatever the error.
+	if !shouldPersist(exportErr) && !o.shuttingDown() {
+		return false
+	}
+	data, err := call.request.MarshalProto()
+	if err != nil {
+		o.Log.Errorf("Serializing %d %s for the disk queue failed: %v", call.count, call.items, err)
+		return false
+	}
+	dropped, err := o.diskQueue.push(call.signal, data, call.headers)
+	if err != nil {
+		o.Log.Errorf("Persisting %d %s failed: %v", call.count, call.items, err)
+		return false
+	}
+	if dropped > 0 {
+		o.stats.diskQueueDropped.Incr(int64(dropped))
+		o.Log.Warnf("Disk queue full, dropped the %d oldest requests", dropped)
+	}
+	o.Log.Warnf("Exporting %d %s failed, persisted to the disk queue: %v", call.count, call.items, exportErr)
+	return true
+}
+
+// replayDiskQueue exports the persisted requests, oldest first, until the
+// queue is empty or an export fails with a transient error. Requests failing
+// with a permanent error are dropped.
+func (o *OpenTelemetry) replayDiskQueue() {
+	q := o.diskQueue
+	if !q.replayMu.TryLock() {
+		return
+	}
+	defer q.replayMu.Unlock()
+
+	for {
+		f, ok := q.oldest()
+		if !ok {
+			return
+		}
+		call, err := q.load(f)
+		if err != nil {
+			// The file was dropped meanwhile unless it cannot be read.
+			if !errors.Is(err, os.ErrNotExist) {
+				o.Log.Errorf("Dropping unreadable disk queue file %q: %v", f.name, err)
+			}
+			q.remove(f)
+			continue
+		}
+		if err := o.export(call); err != nil {
+			if shouldPersist(err) || o.shuttingDown() {
+				return
+			}
+			o.Log.Errorf("Dropping %d %s from the disk queue: %v", call.count, call.items, err)
+		}
+		q.remove(f)
+	}
+}
+
+// shouldPersist reports whether the export may succeed later, in which case
+// the request is worth persisting.
+func shouldPersist(err error) bool {
+	var tokenErr *tokenError
+	return errors.Is(err, errCircuitOpen) || errors.As(err, &tokenErr) || shouldFailover(err)
+}
@@ -0,0 +1,59 @@
+package opentelemetry
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"google.golang.org/grpc/resolver"
+)
+
+// minDNSRefreshInterval is the rate limit of re-resolutions in the gRPC DNS
+// resolver; refreshing more often has no effect.
+const minDNSRefreshInterval = config.Duration(30 * time.Second)
+
+// refreshingDNSBuilder builds DNS resolvers that periodically re-resolve the
+// target. The gRPC DNS resolver only resolves again when a connection fails,
+// so clients keep using the addresses of replaced backends as long as they
+// accept connections.
+type refreshingDNSBuilder struct {
+	resolver.Builder
+	interval time.Duration
+}
+
+func newRefreshingDNSBuilder(interval time.Duration) *refreshingDNSBuilder {
+	return &refreshingDNSBuilder{Builder: resolver.Get("dns"), interval: interval}
+}
+
+func (b *refreshingDNSBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	r, err := b.Builder.Build(target, cc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rr := &refreshingResolver{Resolver: r, done: make(chan struct{})}
+	go rr.refresh(b.interval)
+	return rr, nil
+}
+
+type refreshingResolver struct {
+	resolver.Resolver
+	done chan struct{}
+}
+
+func (r *refreshingResolver) refresh(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.ResolveNow(resolver.ResolveNowOptions{})
+		}
+	}
+}
+
+func (r *refreshingResolver) Close() {
+	close(r.done)
+	r.Resolver.Close()
+}
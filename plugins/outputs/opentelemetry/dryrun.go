@@ -0,0 +1,34 @@
+package opentelemetry
+
+import (
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+)
+
+// logDryRun logs what the request would export instead of exporting it.
+func (o *OpenTelemetry) logDryRun(call exportCall) {
+	var resources, scopes int
+	switch request := call.request.(type) {
+	case pmetricotlp.Request:
+		rms := request.Metrics().ResourceMetrics()
+		resources = rms.Len()
+		for i := 0; i < rms.Len(); i++ {
+			scopes += rms.At(i).ScopeMetrics().Len()
+		}
+	case ptraceotlp.Request:
+		rss := request.Traces().ResourceSpans()
+		resources = rss.Len()
+		for i := 0; i < rss.Len(); i++ {
+			scopes += rss.At(i).ScopeSpans().Len()
+		}
+	case plogotlp.Request:
+		rls := request.Logs().ResourceLogs()
+		resources = rls.Len()
+		for i := 0; i < rls.Len(); i++ {
+			scopes += rls.At(i).ScopeLogs().Len()
+		}
+	}
+	o.Log.Infof("Dry run, not exporting %d %s in %d resources and %d scopes (%d bytes)",
+		call.count, call.items, resources, scopes, call.size())
+}
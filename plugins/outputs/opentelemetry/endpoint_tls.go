@@ -0,0 +1,75 @@
+package opentelemetry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EndpointTLS overrides the TLS settings for one of the endpoints, for
+// collectors behind different certificates.
+type EndpointTLS struct {
+	ServerName string `toml:"tls_server_name"`
+	TLSCA      string `toml:"tls_ca"`
+}
+
+// checkEndpointTLS validates the endpoint_tls tables. An endpoint with its
+// own TLS settings always uses TLS, so settings selecting plaintext for it
+// are rejected instead of ignored.
+func (o *OpenTelemetry) checkEndpointTLS() error {
+	addresses := make(map[string]bool)
+	for _, address := range o.addresses() {
+		addresses[address] = true
+	}
+	for address, settings := range o.EndpointTLS {
+		if !addresses[address] {
+			return fmt.Errorf("endpoint_tls %q is not one of the endpoints", address)
+		}
+		if settings.ServerName == "" && settings.TLSCA == "" {
+			return fmt.Errorf("endpoint_tls %q sets neither tls_server_name nor tls_ca", address)
+		}
+		if o.Insecure != nil && *o.Insecure {
+			return errors.New("endpoint_tls cannot be combined with insecure = true")
+		}
+		if strings.HasPrefix(address, httpScheme) {
+			return fmt.Errorf("endpoint_tls %q requires an %q address", address, httpsScheme)
+		}
+		// The authority would be checked against the certificate otherwise.
+		if settings.ServerName != "" && o.Authority != "" && settings.ServerName != authorityHost(o.Authority) {
+			return fmt.Errorf("tls_server_name %q of endpoint_tls %q does not match authority %q", settings.ServerName, address, o.Authority)
+		}
+	}
+	return nil
+}
+
+// endpointTLSConfig returns the TLS configuration of the endpoint, which is
+// the client TLS configuration unless the endpoint has its own settings.
+func (o *OpenTelemetry) endpointTLSConfig(address string, base *tls.Config) (*tls.Config, error) {
+	settings, ok := o.EndpointTLS[address]
+	if !ok {
+		return base, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if base != nil {
+		tlsConfig = base.Clone()
+	}
+	if settings.ServerName != "" {
+		tlsConfig.ServerName = settings.ServerName
+	}
+	if settings.TLSCA != "" {
+		pem, err := os.ReadFile(settings.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading tls_ca of endpoint_tls %q failed: %w", address, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls_ca %q of endpoint_tls %q contains no PEM certificates", settings.TLSCA, address)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,120 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+)
+
+// endpoint is one of the configured collectors. The gRPC connection to it is
+// only established once it is used.
+type endpoint struct {
+	address string
+	conn    *grpc.ClientConn
+	// watched is closed once the state changes of conn are no longer
+	// watched after it was closed.
+	watched chan struct{}
+	// dialOptions replace the dial options of the plugin for endpoints with
+	// their own TLS settings.
+	dialOptions []grpc.DialOption
+
+	baseURL    string
+	httpClient *http.Client
+}
+
+// addresses returns the configured endpoints in order of priority.
+func (o *OpenTelemetry) addresses() []string {
+	if len(o.Endpoints) > 0 {
+		return o.Endpoints
+	}
+	return []string{o.ServiceAddress}
+}
+
+// useEndpoint makes the i-th endpoint the one exported to.
+func (o *OpenTelemetry) useEndpoint(i int) error {
+	e := o.endpoints[i]
+	if o.httpClient != nil {
+		o.baseURL = e.baseURL
+		o.httpClient = e.httpClient
+		o.currentEndpoint = i
+		return nil
+	}
+
+	if e.conn == nil {
+		ctx := context.Background()
+		if o.WaitForReady {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(o.ConnectTimeout))
+			defer cancel()
+		}
+		dialed := time.Now()
+		dialOptions := o.dialOptions
+		if e.dialOptions != nil {
+			dialOptions = e.dialOptions
+		}
+		conn, err := grpc.DialContext(ctx, e.address, dialOptions...)
+		if err != nil {
+			return fmt.Errorf("connecting to %q failed: %w", e.address, err)
+		}
+		e.conn = conn
+		e.watched = make(chan struct{})
+		connected := func(d time.Duration) { o.stats.connectDuration.Incr(d.Nanoseconds()) }
+		go watchConnectivity(conn, e.address, o.Log, dialed, connected, e.watched)
+	}
+
+	o.grpcClientConn = e.conn
+	o.metricsServiceClient = pmetricotlp.NewClient(e.conn)
+	o.tracesServiceClient = ptraceotlp.NewClient(e.conn)
+	o.logsServiceClient = plogotlp.NewClient(e.conn)
+	o.currentEndpoint = i
+	return nil
+}
+
+// failover switches from the endpoint with the given index to the next one
+// if the error indicates that it is unavailable. The new endpoint stays the current one until
+// it fails itself.
+func (o *OpenTelemetry) failover(from int, err error) bool {
+	if len(o.endpoints) < 2 || !shouldFailover(err) {
+		return false
+	}
+
+	o.endpointMu.Lock()
+	defer o.endpointMu.Unlock()
+
+	// Another export already failed over from the endpoint.
+	if o.currentEndpoint != from {
+		return true
+	}
+
+	current := o.endpoints[o.currentEndpoint].address
+	next := (o.currentEndpoint + 1) % len(o.endpoints)
+	o.Log.Warnf("Export to %q failed, failing over to %q: %v", current, o.endpoints[next].address, err)
+	if err := o.useEndpoint(next); err != nil {
+		o.Log.Errorf("Failing over failed: %v", err)
+		return false
+	}
+	return true
+}
+
+// shouldFailover reports whether the error is transient for the endpoint,
+// including errors connecting to it over HTTP. Failing to obtain a token does
+// not depend on the endpoint.
+func shouldFailover(err error) bool {
+	var tokenErr *tokenError
+	if errors.As(err, &tokenErr) {
+		return false
+	}
+	if isRetryable(err) {
+		return true
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
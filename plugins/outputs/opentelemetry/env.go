@@ -0,0 +1,65 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+)
+
+const (
+	undefinedEnvError = "error"
+	undefinedEnvEmpty = "empty"
+)
+
+var envVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnvVars replaces "${VAR}" references in the header and attribute
+// values with the value of the environment variable.
+func (o *OpenTelemetry) expandEnvVars() error {
+	switch o.UndefinedEnvBehavior {
+	case "":
+		o.UndefinedEnvBehavior = undefinedEnvError
+	case undefinedEnvError, undefinedEnvEmpty:
+	default:
+		return fmt.Errorf("unsupported undefined_env_behavior %q", o.UndefinedEnvBehavior)
+	}
+
+	var err error
+	if o.Headers, err = o.expandValues("headers", o.Headers); err != nil {
+		return err
+	}
+	o.Attributes, err = o.expandValues("attributes", o.Attributes)
+	return err
+}
+
+// expandValues returns a copy of the values with the environment variables
+// expanded, leaving the configured map untouched.
+func (o *OpenTelemetry) expandValues(option string, values map[string]string) (map[string]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	expanded := make(map[string]string, len(values))
+	for _, k := range keys {
+		var undefined string
+		expanded[k] = envVarPattern.ReplaceAllStringFunc(values[k], func(ref string) string {
+			name := envVarPattern.FindStringSubmatch(ref)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok && undefined == "" {
+				undefined = name
+			}
+			return value
+		})
+		if undefined != "" && o.UndefinedEnvBehavior == undefinedEnvError {
+			return nil, fmt.Errorf("environment variable %q in %s %q is not defined", undefined, option, k)
+		}
+	}
+	return expanded, nil
+}
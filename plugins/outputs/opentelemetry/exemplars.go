@@ -0,0 +1,176 @@
+package opentelemetry
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+const (
+	exemplarTraceIDTag      = "exemplar_trace_id"
+	exemplarSpanIDTag       = "exemplar_span_id"
+	exemplarAttributePrefix = "exemplar_attribute_"
+	exemplarValueField      = "exemplar_value"
+	exemplarTimeField       = "exemplar_time"
+
+	// exemplarMarker is the attribute carrying the exemplar of the data
+	// points on their way through the conversion, encoded as JSON.
+	exemplarMarker = "\x00exemplar"
+)
+
+// exemplar is an exemplar taken from the tags and fields of a metric.
+type exemplar struct {
+	TraceID    string            `json:"trace_id,omitempty"`
+	SpanID     string            `json:"span_id,omitempty"`
+	Time       int64             `json:"time"`
+	IsInt      bool              `json:"is_int,omitempty"`
+	IntValue   int64             `json:"int_value,omitempty"`
+	Value      float64           `json:"value,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// extractExemplar removes the exemplar tags and fields of the metric and
+// returns the exemplar encoded for the exemplar marker. It returns an empty
+// string if the metric has no valid exemplar.
+func (o *OpenTelemetry) extractExemplar(tags map[string]string, fields map[string]interface{}, ts time.Time) string {
+	if !o.EnableExemplars {
+		return ""
+	}
+
+	e := exemplar{
+		TraceID: tags[exemplarTraceIDTag],
+		SpanID:  tags[exemplarSpanIDTag],
+		Time:    ts.UnixNano(),
+	}
+	delete(tags, exemplarTraceIDTag)
+	delete(tags, exemplarSpanIDTag)
+	for k, v := range tags {
+		if strings.HasPrefix(k, exemplarAttributePrefix) {
+			if e.Attributes == nil {
+				e.Attributes = make(map[string]string)
+			}
+			e.Attributes[strings.TrimPrefix(k, exemplarAttributePrefix)] = v
+			delete(tags, k)
+		}
+	}
+	value, hasValue := fields[exemplarValueField]
+	delete(fields, exemplarValueField)
+	if t, ok := fields[exemplarTimeField]; ok {
+		delete(fields, exemplarTimeField)
+		if t, ok := t.(int64); ok {
+			e.Time = t
+		}
+	}
+
+	if !hasValue {
+		return ""
+	}
+	switch v := value.(type) {
+	case int64:
+		e.IsInt, e.IntValue = true, v
+	case uint64:
+		e.IsInt, e.IntValue = true, int64(v)
+	case float64:
+		e.Value = v
+	default:
+		o.Log.Debugf("Ignoring exemplar with a value of type %T", value)
+		return ""
+	}
+	if e.TraceID != "" {
+		if _, err := parseTraceID(e.TraceID); err != nil {
+			o.Log.Debugf("Ignoring exemplar: %v", err)
+			return ""
+		}
+	}
+	if e.SpanID != "" {
+		if _, err := parseSpanID(e.SpanID); err != nil {
+			o.Log.Debugf("Ignoring exemplar: %v", err)
+			return ""
+		}
+	}
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return ""
+	}
+	return string(buf)
+}
+
+// withExemplarMarker returns a copy of the tags with the exemplar marker.
+func withExemplarMarker(tags map[string]string, encoded string) map[string]string {
+	result := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		result[k] = v
+	}
+	result[exemplarMarker] = encoded
+	return result
+}
+
+// attachExemplars adds the exemplars carried by the exemplar marker to the
+// data points of sums and histograms and removes the marker from all data
+// points.
+func attachExemplars(metrics pmetric.Metrics) {
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				switch metric := sm.Metrics().At(k); metric.DataType() {
+				case pmetric.MetricDataTypeSum:
+					dps := metric.Sum().DataPoints()
+					for n := 0; n < dps.Len(); n++ {
+						addExemplar(dps.At(n).Attributes(), dps.At(n).Exemplars())
+					}
+				case pmetric.MetricDataTypeHistogram:
+					dps := metric.Histogram().DataPoints()
+					for n := 0; n < dps.Len(); n++ {
+						addExemplar(dps.At(n).Attributes(), dps.At(n).Exemplars())
+					}
+				default:
+					for _, attributes := range dataPointAttributes(metric) {
+						attributes.Remove(exemplarMarker)
+					}
+				}
+			}
+		}
+	}
+}
+
+// addExemplar moves the exemplar of the marker in the attributes to the
+// exemplars.
+func addExemplar(attributes pcommon.Map, exemplars pmetric.ExemplarSlice) {
+	marker, ok := attributes.Get(exemplarMarker)
+	if !ok {
+		return
+	}
+	// The value refers to the storage of the map, so it must be read before
+	// the marker is removed.
+	encoded := marker.StringVal()
+	attributes.Remove(exemplarMarker)
+
+	var e exemplar
+	if err := json.Unmarshal([]byte(encoded), &e); err != nil {
+		return
+	}
+	ex := exemplars.AppendEmpty()
+	ex.SetTimestamp(pcommon.Timestamp(e.Time))
+	if e.IsInt {
+		ex.SetIntVal(e.IntValue)
+	} else {
+		ex.SetDoubleVal(e.Value)
+	}
+	// The IDs were validated when the exemplar was extracted.
+	if e.TraceID != "" {
+		id, _ := parseTraceID(e.TraceID)
+		ex.SetTraceID(id)
+	}
+	if e.SpanID != "" {
+		id, _ := parseSpanID(e.SpanID)
+		ex.SetSpanID(id)
+	}
+	for k, v := range e.Attributes {
+		ex.FilteredAttributes().UpsertString(k, v)
+	}
+}
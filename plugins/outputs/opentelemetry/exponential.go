@@ -0,0 +1,218 @@
+package opentelemetry
+
+import (
+	"math"
+	"sort"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+const (
+	histogramTypeExplicit    = "explicit"
+	histogramTypeExponential = "exponential"
+
+	// Limits of the exponential histogram resolution, the maximum number of
+	// buckets matches the default of the OpenTelemetry SDKs.
+	maxExponentialScale   = 20
+	minExponentialScale   = -10
+	maxExponentialBuckets = 160
+)
+
+// convertToExponentialHistograms replaces all explicit bucket histograms by
+// exponential histograms. The count of each explicit bucket is assigned to
+// the exponential bucket containing its upper bound, or just above the
+// highest finite bound for the overflow bucket.
+func convertToExponentialHistograms(metrics pmetric.Metrics) {
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+				if metric.DataType() != pmetric.MetricDataTypeHistogram {
+					continue
+				}
+
+				histogram := pmetric.NewHistogram()
+				metric.Histogram().CopyTo(histogram)
+				metric.SetDataType(pmetric.MetricDataTypeExponentialHistogram)
+				exponential := metric.ExponentialHistogram()
+				exponential.SetAggregationTemporality(histogram.AggregationTemporality())
+				for n := 0; n < histogram.DataPoints().Len(); n++ {
+					toExponentialDataPoint(histogram.DataPoints().At(n), exponential.DataPoints().AppendEmpty())
+				}
+			}
+		}
+	}
+}
+
+func toExponentialDataPoint(dp pmetric.HistogramDataPoint, dst pmetric.ExponentialHistogramDataPoint) {
+	dp.Attributes().CopyTo(dst.Attributes())
+	dst.SetStartTimestamp(dp.StartTimestamp())
+	dst.SetTimestamp(dp.Timestamp())
+	dst.SetFlags(dp.Flags())
+	dst.SetCount(dp.Count())
+	if dp.HasSum() {
+		dst.SetSum(dp.Sum())
+	}
+	if dp.HasMin() {
+		dst.SetMin(dp.Min())
+	}
+	if dp.HasMax() {
+		dst.SetMax(dp.Max())
+	}
+	dp.Exemplars().CopyTo(dst.Exemplars())
+
+	values, valueCounts := bucketValues(dp)
+
+	scale := exponentialScale(values)
+	dst.SetScale(scale)
+
+	positiveOffset, positive := exponentialBuckets(values, valueCounts, scale, 1)
+	negativeOffset, negative := exponentialBuckets(values, valueCounts, scale, -1)
+	dst.Positive().SetOffset(positiveOffset)
+	dst.Positive().SetBucketCounts(pcommon.NewImmutableUInt64Slice(positive))
+	dst.Negative().SetOffset(negativeOffset)
+	dst.Negative().SetBucketCounts(pcommon.NewImmutableUInt64Slice(negative))
+
+	var zeroCount uint64
+	for i, value := range values {
+		if value == 0 || math.IsNaN(value) {
+			zeroCount += valueCounts[i]
+		}
+	}
+	dst.SetZeroCount(zeroCount)
+}
+
+// bucketValues returns a value representing each non-empty bucket of the
+// histogram and the number of values in the bucket. The converter keeps the
+// bucket counts of Telegraf histograms as they are, that is cumulative, in
+// the order of the fields and with a "+Inf" bound if there is one, so the
+// counts are sorted by their bound and differenced. Infinite bounds are
+// clamped to the finite ones, values above the highest finite bound are
+// represented by a value just above it.
+func bucketValues(dp pmetric.HistogramDataPoint) ([]float64, []uint64) {
+	type bucket struct {
+		bound      float64
+		cumulative uint64
+	}
+
+	bounds := dp.ExplicitBounds().AsRaw()
+	counts := dp.BucketCounts().AsRaw()
+	buckets := make([]bucket, 0, len(counts))
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	for i, count := range counts {
+		// The last count is that of all values.
+		bound := math.Inf(1)
+		if i < len(bounds) {
+			bound = bounds[i]
+		}
+		if math.IsNaN(bound) {
+			continue
+		}
+		if !math.IsInf(bound, 0) {
+			lowest, highest = math.Min(lowest, bound), math.Max(highest, bound)
+		}
+		buckets = append(buckets, bucket{bound: bound, cumulative: count})
+	}
+	sort.SliceStable(buckets, func(i, j int) bool { return buckets[i].bound < buckets[j].bound })
+
+	// Without finite bounds all values are represented by their mean.
+	overflow := dp.Sum() / float64(dp.Count())
+	if !math.IsInf(highest, -1) {
+		overflow = math.Nextafter(highest, math.Inf(1))
+	} else {
+		lowest = overflow
+	}
+
+	values := make([]float64, 0, len(buckets))
+	valueCounts := make([]uint64, 0, len(buckets))
+	var previous uint64
+	for _, b := range buckets {
+		if b.cumulative <= previous {
+			continue
+		}
+		value := b.bound
+		switch {
+		case math.IsInf(value, 1):
+			value = overflow
+		case math.IsInf(value, -1):
+			value = lowest
+		}
+		// The mean is infinite for infinite sums.
+		if math.IsInf(value, 0) {
+			value = math.Copysign(math.MaxFloat64, value)
+		}
+		values = append(values, value)
+		valueCounts = append(valueCounts, b.cumulative-previous)
+		previous = b.cumulative
+	}
+	return values, valueCounts
+}
+
+// exponentialScale returns the highest scale at which the values fit into
+// the maximum number of positive and negative buckets.
+func exponentialScale(values []float64) int32 {
+	for scale := int32(maxExponentialScale); scale > minExponentialScale; scale-- {
+		if bucketSpan(values, scale, 1) <= maxExponentialBuckets && bucketSpan(values, scale, -1) <= maxExponentialBuckets {
+			return scale
+		}
+	}
+	return minExponentialScale
+}
+
+// bucketSpan returns the number of buckets between the lowest and highest
+// index of the values with the given sign.
+func bucketSpan(values []float64, scale int32, sign float64) int32 {
+	var lowest, highest int32
+	var found bool
+	for _, value := range values {
+		value *= sign
+		if !(value > 0) {
+			continue
+		}
+		index := exponentialIndex(value, scale)
+		if !found || index < lowest {
+			lowest = index
+		}
+		if !found || index > highest {
+			highest = index
+		}
+		found = true
+	}
+	if !found {
+		return 0
+	}
+	return highest - lowest + 1
+}
+
+func exponentialBuckets(values []float64, counts []uint64, scale int32, sign float64) (int32, []uint64) {
+	span := bucketSpan(values, scale, sign)
+	if span == 0 {
+		return 0, nil
+	}
+
+	offset := int32(math.MaxInt32)
+	for _, value := range values {
+		if value *= sign; value > 0 {
+			if index := exponentialIndex(value, scale); index < offset {
+				offset = index
+			}
+		}
+	}
+
+	buckets := make([]uint64, span)
+	for i, value := range values {
+		if value *= sign; value > 0 {
+			buckets[exponentialIndex(value, scale)-offset] += counts[i]
+		}
+	}
+	return offset, buckets
+}
+
+// exponentialIndex returns the index of the bucket (base^index, base^(index+1)]
+// containing the value, where base is 2^(2^-scale).
+func exponentialIndex(value float64, scale int32) int32 {
+	return int32(math.Ceil(math.Ldexp(math.Log2(value), int(scale)))) - 1
+}
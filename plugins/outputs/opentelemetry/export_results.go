@@ -0,0 +1,111 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// statusClientClosedRequest is the non-standard HTTP status of requests
+// cancelled by the client.
+const statusClientClosedRequest = 499
+
+// httpStatusCodes are the gRPC status codes of HTTP statuses, following the
+// HTTP mapping of google.rpc.Code.
+var httpStatusCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.Aborted,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	statusClientClosedRequest:      codes.Canceled,
+	http.StatusInternalServerError: codes.Internal,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusBadGateway:          codes.Unavailable,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+}
+
+// exportResults counts export attempts by their gRPC status code. Every code
+// is a field named by the code in snake case, for example "ok" or
+// "deadline_exceeded".
+type exportResults struct {
+	counts []selfstat.Stat
+}
+
+func newExportResults(measurement string, tags map[string]string) *exportResults {
+	r := &exportResults{counts: make([]selfstat.Stat, 0, codes.Unauthenticated+1)}
+	for code := codes.OK; code <= codes.Unauthenticated; code++ {
+		r.counts = append(r.counts, selfstat.Register(measurement, codeFieldName(code), tags))
+	}
+	return r
+}
+
+// observe counts the result of an export attempt.
+func (r *exportResults) observe(err error) {
+	r.counts[exportResultCode(err)].Incr(1)
+}
+
+// exportResultCode returns the gRPC status code of the result of an export
+// attempt. HTTP statuses are mapped to the corresponding code, failures to
+// reach the collector count as unavailable like they do with gRPC.
+func exportResultCode(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		if code, ok := httpStatusCodes[statusErr.StatusCode]; ok {
+			return code
+		}
+		if statusErr.StatusCode >= 500 {
+			return codes.Internal
+		}
+		return codes.Unknown
+	}
+	if s, ok := status.FromError(err); ok {
+		// Collectors and proxies may send codes gRPC does not define.
+		if s.Code() > codes.Unauthenticated {
+			return codes.Unknown
+		}
+		return s.Code()
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return codes.Unavailable
+	}
+	return codes.Unknown
+}
+
+// codeFieldName returns the name of the code in snake case.
+func codeFieldName(code codes.Code) string {
+	if code == codes.OK {
+		return "ok"
+	}
+	var name strings.Builder
+	for i, r := range code.String() {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				name.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		name.WriteRune(r)
+	}
+	return name.String()
+}
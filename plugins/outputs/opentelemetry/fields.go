@@ -0,0 +1,192 @@
+package opentelemetry
+
+import (
+	"fmt"
+
+	"github.com/influxdata/influxdb-observability/common"
+
+	"github.com/influxdata/telegraf/filter"
+)
+
+const (
+	stringFieldsDrop      = "drop"
+	stringFieldsAttribute = "attribute"
+
+	stringOnlyMetricsDrop = "drop"
+	stringOnlyMetricsInfo = "info"
+
+	booleanFieldsDrop  = "drop"
+	booleanFieldsGauge = "gauge"
+
+	nonNumericMetricsDrop     = "drop"
+	nonNumericMetricsPresence = "presence"
+
+	// infoField is the field of the gauges standing in for metrics with only
+	// string fields, named "<measurement>_info" by the converter.
+	infoField = "info"
+)
+
+// fieldSelection tells what became of the fields of a metric.
+type fieldSelection int
+
+const (
+	// selectedNumeric metrics keep their numeric fields.
+	selectedNumeric fieldSelection = iota
+	// selectedWithBooleans metrics keep their numeric fields and have boolean
+	// fields converted to gauges.
+	selectedWithBooleans
+	// selectedBooleans metrics only have boolean fields converted to gauges.
+	selectedBooleans
+	// selectedPresence metrics without numeric fields are replaced by a gauge
+	// of value 1.
+	selectedPresence
+	// selectedNone metrics are left without fields and dropped.
+	selectedNone
+)
+
+// compileFieldFilter compiles field_include and field_exclude and checks
+// string_fields, string_only_metrics, boolean_fields, bool_fields_as_gauge
+// and non_numeric_metrics.
+func (o *OpenTelemetry) compileFieldFilter() error {
+	switch o.StringFields {
+	case "":
+		o.StringFields = stringFieldsDrop
+	case stringFieldsDrop, stringFieldsAttribute:
+	default:
+		return fmt.Errorf("unsupported string_fields %q", o.StringFields)
+	}
+	switch o.StringOnlyMetrics {
+	case "":
+		o.StringOnlyMetrics = stringOnlyMetricsDrop
+	case stringOnlyMetricsDrop:
+	case stringOnlyMetricsInfo:
+		if o.StringFields != stringFieldsAttribute {
+			return fmt.Errorf("string_only_metrics %q requires string_fields %q", o.StringOnlyMetrics, stringFieldsAttribute)
+		}
+	default:
+		return fmt.Errorf("unsupported string_only_metrics %q", o.StringOnlyMetrics)
+	}
+	if o.BoolFieldsAsGauge {
+		if o.BooleanFields == booleanFieldsDrop {
+			return fmt.Errorf("bool_fields_as_gauge conflicts with boolean_fields %q", o.BooleanFields)
+		}
+		o.BooleanFields = booleanFieldsGauge
+	}
+	switch o.BooleanFields {
+	case "":
+		o.BooleanFields = booleanFieldsDrop
+	case booleanFieldsDrop, booleanFieldsGauge:
+	default:
+		return fmt.Errorf("unsupported boolean_fields %q", o.BooleanFields)
+	}
+	o.boolTrue, o.boolFalse = 1, 0
+	if o.BoolTrueValue != nil {
+		o.boolTrue = *o.BoolTrueValue
+	}
+	if o.BoolFalseValue != nil {
+		o.boolFalse = *o.BoolFalseValue
+	}
+	if o.boolTrue == o.boolFalse {
+		return fmt.Errorf("bool_true_value and bool_false_value must differ, both are %v", o.boolTrue)
+	}
+	switch o.NonNumericMetrics {
+	case "":
+		o.NonNumericMetrics = nonNumericMetricsDrop
+	case nonNumericMetricsDrop, nonNumericMetricsPresence:
+	default:
+		return fmt.Errorf("unsupported non_numeric_metrics %q", o.NonNumericMetrics)
+	}
+
+	o.fieldFilter = nil
+	if len(o.FieldInclude) == 0 && len(o.FieldExclude) == 0 {
+		return nil
+	}
+	var err error
+	if o.fieldFilter, err = filter.NewIncludeExcludeFilter(o.FieldInclude, o.FieldExclude); err != nil {
+		return fmt.Errorf("invalid field_include or field_exclude: %w", err)
+	}
+	return nil
+}
+
+// selectFields returns the fields passing field_include and field_exclude.
+// String fields cannot become data points; with string_fields set to
+// "attribute" they are added to the tags unless a tag of the same name
+// exists, and they are dropped otherwise. Boolean fields are dropped too
+// unless boolean_fields is "gauge", which converts them to the
+// bool_true_value or bool_false_value. A metric
+// left without numeric fields is replaced by an info gauge of value 1 with
+// string_only_metrics set to "info" if it has string fields, or with
+// non_numeric_metrics set to "presence".
+func (o *OpenTelemetry) selectFields(fields map[string]interface{}, tags map[string]string) (map[string]interface{}, fieldSelection) {
+	result := make(map[string]interface{}, len(fields))
+	var hasNumbers, hasBooleans, hasStrings bool
+	for k, v := range fields {
+		if o.fieldFilter != nil && !o.fieldFilter.Match(k) {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			if _, exists := tags[k]; !exists && o.StringFields == stringFieldsAttribute {
+				tags[k] = v
+			}
+			hasStrings = true
+		case bool:
+			hasBooleans = true
+			if o.BooleanFields == booleanFieldsGauge {
+				result[k] = o.boolFalse
+				if v {
+					result[k] = o.boolTrue
+				}
+			}
+		default:
+			result[k] = v
+			hasNumbers = true
+		}
+	}
+
+	switch {
+	case hasNumbers && hasBooleans && o.BooleanFields == booleanFieldsGauge:
+		return result, selectedWithBooleans
+	case hasNumbers:
+		return result, selectedNumeric
+	case hasBooleans && o.BooleanFields == booleanFieldsGauge:
+		return result, selectedBooleans
+	case hasStrings && o.StringOnlyMetrics == stringOnlyMetricsInfo,
+		(hasStrings || hasBooleans) && o.NonNumericMetrics == nonNumericMetricsPresence:
+		result[infoField] = int64(1)
+		return result, selectedPresence
+	}
+	return result, selectedNone
+}
+
+// splitBooleans moves the converted boolean fields out of the groups of other
+// types into a group of their own, so they are always exported as gauges.
+func splitBooleans(groups []fieldGroup, fields map[string]interface{}) []fieldGroup {
+	var booleans *fieldGroup
+	result := make([]fieldGroup, 0, len(groups)+1)
+	for _, group := range groups {
+		if group.vType == common.InfluxMetricValueTypeGauge {
+			result = append(result, group)
+			continue
+		}
+		others := make(map[string]interface{}, len(group.fields))
+		for k, v := range group.fields {
+			if _, ok := fields[k].(bool); !ok {
+				others[k] = v
+				continue
+			}
+			if booleans == nil {
+				booleans = &fieldGroup{fields: make(map[string]interface{}), vType: common.InfluxMetricValueTypeGauge}
+			}
+			booleans.fields[k] = v
+		}
+		if len(others) > 0 {
+			group.fields = others
+			result = append(result, group)
+		}
+	}
+	if booleans != nil {
+		result = append(result, *booleans)
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+package opentelemetry
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+const (
+	futureDriftDrop  = "drop"
+	futureDriftClamp = "clamp"
+)
+
+// maxLoggedFutureMetrics is the number of metrics with future timestamps
+// logged.
+const maxLoggedFutureMetrics = 10
+
+// handleFutureTimestamp drops the metric or clamps its timestamp to now if it
+// is more than max_future_drift ahead of now, depending on
+// future_drift_handling. It reports whether the metric is kept.
+func (o *OpenTelemetry) handleFutureTimestamp(metric telegraf.Metric, now time.Time) (telegraf.Metric, bool) {
+	if o.MaxFutureDrift <= 0 {
+		return metric, true
+	}
+	drift := metric.Time().Sub(now)
+	if drift <= time.Duration(o.MaxFutureDrift) {
+		return metric, true
+	}
+
+	if o.loggedFutureMetrics < maxLoggedFutureMetrics {
+		o.Log.Warnf("Metric %q with tags %v is %s ahead of the clock, the clock of its host may be skewed", metric.Name(), metric.Tags(), drift)
+		o.loggedFutureMetrics++
+	}
+	if o.FutureDriftHandling != futureDriftClamp {
+		return nil, false
+	}
+	// The metric may be shared with other outputs.
+	metric = metric.Copy()
+	metric.SetTime(now)
+	return metric, true
+}
@@ -0,0 +1,142 @@
+package opentelemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+const (
+	defaultGRPCRetryMaxAttempts       = 3
+	defaultGRPCRetryInitialBackoff    = config.Duration(100 * time.Millisecond)
+	defaultGRPCRetryMaxBackoff        = config.Duration(5 * time.Second)
+	defaultGRPCRetryBackoffMultiplier = 2.0
+
+	// maxGRPCRetryAttempts is the limit gRPC applies to maxAttempts.
+	maxGRPCRetryAttempts = 5
+)
+
+// grpcRetryServices are the services the retry policy applies to.
+var grpcRetryServices = []string{
+	"opentelemetry.proto.collector.metrics.v1.MetricsService",
+	"opentelemetry.proto.collector.trace.v1.TraceService",
+	"opentelemetry.proto.collector.logs.v1.LogsService",
+}
+
+// GRPCRetryPolicy configures the retries done by gRPC itself, installed as
+// the retry policy of the service config.
+type GRPCRetryPolicy struct {
+	MaxAttempts          int             `toml:"max_attempts"`
+	InitialBackoff       config.Duration `toml:"initial_backoff"`
+	MaxBackoff           config.Duration `toml:"max_backoff"`
+	BackoffMultiplier    float64         `toml:"backoff_multiplier"`
+	RetryableStatusCodes []string        `toml:"retryable_status_codes"`
+}
+
+// checkGRPCRetryPolicy validates the grpc_retry_policy table and sets the
+// defaults.
+func (o *OpenTelemetry) checkGRPCRetryPolicy() error {
+	p := o.GRPCRetryPolicy
+	if p == nil {
+		return nil
+	}
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = defaultGRPCRetryMaxAttempts
+	}
+	if p.MaxAttempts < 2 || p.MaxAttempts > maxGRPCRetryAttempts {
+		return fmt.Errorf("max_attempts of grpc_retry_policy must be between 2 and %d", maxGRPCRetryAttempts)
+	}
+	if p.InitialBackoff < 0 {
+		return fmt.Errorf("initial_backoff of grpc_retry_policy must not be negative")
+	}
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = defaultGRPCRetryInitialBackoff
+	}
+	if p.MaxBackoff < 0 {
+		return fmt.Errorf("max_backoff of grpc_retry_policy must not be negative")
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = defaultGRPCRetryMaxBackoff
+	}
+	if p.BackoffMultiplier < 0 {
+		return fmt.Errorf("backoff_multiplier of grpc_retry_policy must not be negative")
+	}
+	if p.BackoffMultiplier == 0 {
+		p.BackoffMultiplier = defaultGRPCRetryBackoffMultiplier
+	}
+	if len(p.RetryableStatusCodes) == 0 {
+		p.RetryableStatusCodes = []string{"UNAVAILABLE"}
+	}
+	for _, name := range p.RetryableStatusCodes {
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(strconv.Quote(name))); err != nil {
+			return fmt.Errorf("unsupported status code %q in retryable_status_codes of grpc_retry_policy", name)
+		}
+	}
+	return nil
+}
+
+type serviceConfig struct {
+	LoadBalancingConfig []map[string]struct{} `json:"loadBalancingConfig,omitempty"`
+	MethodConfig        []methodConfig        `json:"methodConfig,omitempty"`
+}
+
+type methodConfig struct {
+	Name        []methodName `json:"name"`
+	RetryPolicy retryPolicy  `json:"retryPolicy"`
+}
+
+type methodName struct {
+	Service string `json:"service"`
+}
+
+type retryPolicy struct {
+	MaxAttempts          int      `json:"maxAttempts"`
+	InitialBackoff       string   `json:"initialBackoff"`
+	MaxBackoff           string   `json:"maxBackoff"`
+	BackoffMultiplier    float64  `json:"backoffMultiplier"`
+	RetryableStatusCodes []string `json:"retryableStatusCodes"`
+}
+
+// serviceConfig returns the default service config of the connection in its
+// JSON form, or an empty string if the defaults of gRPC apply.
+func (o *OpenTelemetry) serviceConfig() (string, error) {
+	var sc serviceConfig
+	if o.BalancerPolicy == balancerRoundRobin {
+		sc.LoadBalancingConfig = []map[string]struct{}{{balancerRoundRobin: {}}}
+	}
+	if p := o.GRPCRetryPolicy; p != nil {
+		mc := methodConfig{
+			RetryPolicy: retryPolicy{
+				MaxAttempts:          p.MaxAttempts,
+				InitialBackoff:       protoDuration(time.Duration(p.InitialBackoff)),
+				MaxBackoff:           protoDuration(time.Duration(p.MaxBackoff)),
+				BackoffMultiplier:    p.BackoffMultiplier,
+				RetryableStatusCodes: p.RetryableStatusCodes,
+			},
+		}
+		for _, service := range grpcRetryServices {
+			mc.Name = append(mc.Name, methodName{Service: service})
+		}
+		sc.MethodConfig = append(sc.MethodConfig, mc)
+	}
+	if sc.LoadBalancingConfig == nil && sc.MethodConfig == nil {
+		return "", nil
+	}
+	buf, err := json.Marshal(sc)
+	if err != nil {
+		return "", fmt.Errorf("encoding service config failed: %w", err)
+	}
+	return string(buf), nil
+}
+
+// protoDuration formats the duration in the JSON form of a protobuf
+// Duration, that is in seconds with the "s" suffix.
+func protoDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
@@ -0,0 +1,97 @@
+package opentelemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthChecker probes the current endpoint periodically with the standard
+// gRPC health service and logs changes of its health.
+type healthChecker struct {
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	// last is the serving status of the previous probe, or empty if it
+	// failed, to log changes only.
+	last    string
+	checked bool
+}
+
+func (o *OpenTelemetry) startHealthCheck() {
+	h := &healthChecker{stop: make(chan struct{})}
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ticker := time.NewTicker(time.Duration(o.HealthCheckInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+				if !o.checkHealth(h) {
+					return
+				}
+			}
+		}
+	}()
+	o.healthCheck = h
+}
+
+// stopHealthCheck waits for a running probe to finish.
+func (o *OpenTelemetry) stopHealthCheck() {
+	if o.healthCheck == nil {
+		return
+	}
+	close(o.healthCheck.stop)
+	o.healthCheck.wg.Wait()
+	o.healthCheck = nil
+}
+
+// checkHealth probes the current endpoint. It reports false if the collector
+// does not implement the health service, stopping further probes.
+func (o *OpenTelemetry) checkHealth(h *healthChecker) bool {
+	o.endpointMu.Lock()
+	conn := o.grpcClientConn
+	address := o.ServiceAddress
+	if len(o.endpoints) > 0 {
+		address = o.endpoints[o.currentEndpoint].address
+	}
+	o.endpointMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(o.Timeout))
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: o.HealthCheckService})
+	if status.Code(err) == codes.Unimplemented {
+		o.Log.Infof("Collector %q does not implement the gRPC health service, stopping health checks", address)
+		return false
+	}
+
+	var state string
+	if err == nil {
+		state = resp.Status.String()
+	}
+	if state == healthpb.HealthCheckResponse_SERVING.String() {
+		o.stats.collectorHealthy.Set(1)
+	} else {
+		o.stats.collectorHealthy.Set(0)
+	}
+	if !h.checked || state != h.last {
+		switch {
+		case err != nil:
+			o.Log.Warnf("Health check of %q failed: %v", address, err)
+		case resp.Status != healthpb.HealthCheckResponse_SERVING:
+			o.Log.Warnf("Collector %q reports %s", address, resp.Status)
+		case h.checked:
+			o.Log.Infof("Collector %q is serving again", address)
+		}
+	}
+	h.last = state
+	h.checked = true
+	return true
+}
@@ -0,0 +1,87 @@
+package opentelemetry
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+const defaultHeartbeatName = "telegraf_heartbeat"
+
+// heartbeat exports a gauge whenever no metrics were exported for the
+// heartbeat interval, so the backend can tell an idle agent from a gone one.
+type heartbeat struct {
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	// lastSent is the time metrics were last exported, in nanoseconds since
+	// the epoch. It is accessed atomically.
+	lastSent int64
+}
+
+func (o *OpenTelemetry) startHeartbeat() {
+	h := &heartbeat{stop: make(chan struct{}), lastSent: time.Now().UnixNano()}
+	interval := time.Duration(o.HeartbeatInterval)
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		for {
+			wait := time.Until(time.Unix(0, atomic.LoadInt64(&h.lastSent)).Add(interval))
+			if wait <= 0 {
+				o.sendHeartbeat()
+				h.sent()
+				continue
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-h.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+	o.heartbeat = h
+}
+
+// stopHeartbeat waits for a heartbeat being sent to finish.
+func (o *OpenTelemetry) stopHeartbeat() {
+	if o.heartbeat == nil {
+		return
+	}
+	close(o.heartbeat.stop)
+	o.heartbeat.wg.Wait()
+	o.heartbeat = nil
+}
+
+// sent records that metrics were exported.
+func (h *heartbeat) sent() {
+	atomic.StoreInt64(&h.lastSent, time.Now().UnixNano())
+}
+
+// sendHeartbeat exports the heartbeat gauge with the resource attributes of
+// the metrics.
+func (o *OpenTelemetry) sendHeartbeat() {
+	metrics := pmetric.NewMetrics()
+	rm := metrics.ResourceMetrics().AppendEmpty()
+	o.setResourceAttributes(rm.Resource())
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName(o.ScopeName)
+	sm.Scope().SetVersion(o.ScopeVersion)
+	if o.SchemaURL != "" {
+		setSchemaURL(rm, o.SchemaURL)
+	}
+	metric := sm.Metrics().AppendEmpty()
+	metric.SetName(o.HeartbeatName)
+	metric.SetDataType(pmetric.MetricDataTypeGauge)
+	dp := metric.Gauge().DataPoints().AppendEmpty()
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+	dp.SetIntVal(1)
+
+	if err := o.signalOutput(o.metricsOutput).exportMetrics(metrics, nil); err != nil {
+		o.Log.Warnf("Sending heartbeat failed: %v", err)
+	}
+}
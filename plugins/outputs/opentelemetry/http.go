@@ -0,0 +1,224 @@
+package opentelemetry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	maxErrMsgLen      = 1024
+	metricsURLPath    = "/v1/metrics"
+	tracesURLPath     = "/v1/traces"
+	logsURLPath       = "/v1/logs"
+	protobufMediaType = "application/x-protobuf"
+	jsonMediaType     = "application/json"
+	httpScheme        = "http://"
+	httpsScheme       = "https://"
+	unixScheme        = "unix://"
+)
+
+// httpStatusError is returned when the server answers with a non-2xx status.
+type httpStatusError struct {
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("when writing to [%s] received status code: %d. body: %s", e.URL, e.StatusCode, e.Body)
+}
+
+func (o *OpenTelemetry) connectHTTP() error {
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+	if o.proxyDialer != nil {
+		transport.Proxy = nil
+		transport.DialContext = o.proxyDialer.DialContext
+	}
+	client := &http.Client{Transport: transport}
+
+	o.endpoints = o.endpoints[:0]
+	for _, address := range o.addresses() {
+		e := &endpoint{address: address, httpClient: client}
+		endpointTLS, err := o.endpointTLSConfig(address, tlsConfig)
+		if err != nil {
+			return err
+		}
+		if endpointTLS != tlsConfig {
+			endpointTransport := transport.Clone()
+			endpointTransport.TLSClientConfig = endpointTLS
+			e.httpClient = &http.Client{Transport: endpointTransport}
+		}
+		switch {
+		case strings.HasPrefix(address, unixScheme):
+			// The host is irrelevant as every connection goes to the socket.
+			e.baseURL = httpScheme + "localhost"
+			if endpointTLS != nil {
+				e.baseURL = httpsScheme + "localhost"
+			}
+			e.httpClient = newUnixHTTPClient(strings.TrimPrefix(address, unixScheme), endpointTLS)
+		case strings.HasPrefix(address, httpScheme), strings.HasPrefix(address, httpsScheme):
+			e.baseURL = strings.TrimSuffix(address, "/")
+		case endpointTLS != nil:
+			e.baseURL = httpsScheme + strings.TrimSuffix(address, "/")
+		default:
+			e.baseURL = httpScheme + strings.TrimSuffix(address, "/")
+		}
+		o.endpoints = append(o.endpoints, e)
+	}
+
+	o.httpClient = client
+	return o.useEndpoint(0)
+}
+
+// newUnixHTTPClient returns a client connecting to the Unix domain socket at
+// the given path for all requests.
+func newUnixHTTPClient(path string, tlsConfig *tls.Config) *http.Client {
+	var dialer net.Dialer
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", path)
+			},
+			TLSClientConfig: tlsConfig,
+		},
+	}
+}
+
+// timeoutUnits are the units of the gRPC timeout encoding, smallest first.
+var timeoutUnits = []struct {
+	unit   time.Duration
+	suffix string
+}{
+	{time.Millisecond, "m"},
+	{time.Second, "S"},
+	{time.Minute, "M"},
+	{time.Hour, "H"},
+}
+
+// encodeTimeout encodes the remaining time like the "grpc-timeout" header,
+// as at most eight digits followed by the unit, for example "4998m". The
+// time is rounded down, so the server never waits longer than the client.
+func encodeTimeout(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	u := timeoutUnits[0]
+	for _, u = range timeoutUnits {
+		if d/u.unit <= 99999999 {
+			break
+		}
+	}
+	return strconv.FormatInt(int64(d/u.unit), 10) + u.suffix
+}
+
+// postHTTP sends the request to the given URL and returns the partial
+// success reported in the response, if any.
+func (o *OpenTelemetry) postHTTP(ctx context.Context, client *http.Client, url string, request requestMarshaler) (partialSuccess, error) {
+	marshal, mediaType := request.MarshalProto, protobufMediaType
+	if o.Encoding == encodingJSON {
+		marshal, mediaType = request.MarshalJSON, jsonMediaType
+	}
+	body, err := marshal()
+	if err != nil {
+		return partialSuccess{}, err
+	}
+
+	compression := o.Compression
+	if len(body) < int(o.CompressionMinSize) {
+		compression = "none"
+	}
+	encoder, err := newContentEncoder(compression, o.CompressionLevel)
+	if err != nil {
+		return partialSuccess{}, err
+	}
+	if body, err = encoder.Encode(body); err != nil {
+		return partialSuccess{}, err
+	}
+
+	// Time the establishment of new connections, including the TLS handshake.
+	var getConn time.Time
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) { getConn = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused {
+				o.stats.connectDuration.Incr(time.Since(getConn).Nanoseconds())
+			}
+		},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return partialSuccess{}, err
+	}
+	req.Header.Set("Content-Type", mediaType)
+	if o.Authority != "" {
+		req.Host = o.Authority
+	}
+	if compression != "none" {
+		req.Header.Set("Content-Encoding", compression)
+	}
+	// The configured headers and the authorization are part of the
+	// outgoing gRPC metadata.
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for k, values := range md {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", o.UserAgent)
+	}
+	if deadline, ok := ctx.Deadline(); ok && o.TimeoutHeader != "" {
+		req.Header.Set(o.TimeoutHeader, encodeTimeout(time.Until(deadline)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return partialSuccess{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errorLine := ""
+		scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxErrMsgLen))
+		if scanner.Scan() {
+			errorLine = scanner.Text()
+		}
+		return partialSuccess{}, &httpStatusError{URL: url, StatusCode: resp.StatusCode, Body: errorLine}
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return partialSuccess{}, fmt.Errorf("when writing to [%s] received error: %v", url, err)
+	}
+	var ps partialSuccess
+	switch contentType := resp.Header.Get("Content-Type"); {
+	case contentType == protobufMediaType:
+		ps, err = parsePartialSuccess(respBody)
+	case strings.HasPrefix(contentType, jsonMediaType):
+		ps, err = parsePartialSuccessJSON(respBody)
+	}
+	if err != nil {
+		return partialSuccess{}, fmt.Errorf("when writing to [%s] received invalid response: %v", url, err)
+	}
+	return ps, nil
+}
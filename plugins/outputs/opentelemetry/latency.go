@@ -0,0 +1,76 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// defaultExportLatencyBuckets are the upper bounds of the export latency
+// histogram, covering fast local collectors as well as slow remote ones.
+var defaultExportLatencyBuckets = []config.Duration{
+	config.Duration(5 * time.Millisecond),
+	config.Duration(10 * time.Millisecond),
+	config.Duration(25 * time.Millisecond),
+	config.Duration(50 * time.Millisecond),
+	config.Duration(100 * time.Millisecond),
+	config.Duration(250 * time.Millisecond),
+	config.Duration(500 * time.Millisecond),
+	config.Duration(time.Second),
+	config.Duration(2500 * time.Millisecond),
+	config.Duration(5 * time.Second),
+	config.Duration(10 * time.Second),
+}
+
+// checkLatencyBuckets validates export_latency_buckets and sets the defaults.
+func (o *OpenTelemetry) checkLatencyBuckets() error {
+	if len(o.ExportLatencyBuckets) == 0 {
+		o.ExportLatencyBuckets = defaultExportLatencyBuckets
+		return nil
+	}
+	for i, bound := range o.ExportLatencyBuckets {
+		if bound <= 0 {
+			return fmt.Errorf("export_latency_buckets must be positive")
+		}
+		if i > 0 && bound <= o.ExportLatencyBuckets[i-1] {
+			return fmt.Errorf("export_latency_buckets must be ascending")
+		}
+	}
+	return nil
+}
+
+// latencyHistogram counts durations in cumulative buckets, like a Prometheus
+// histogram. Every bucket is a field named "le_" followed by its upper bound,
+// "le_inf" counts all durations, and "sum_ns" is their total.
+type latencyHistogram struct {
+	bounds  []time.Duration
+	buckets []selfstat.Stat
+	sum     selfstat.Stat
+}
+
+func newLatencyHistogram(measurement string, bounds []config.Duration, tags map[string]string) *latencyHistogram {
+	h := &latencyHistogram{
+		bounds:  make([]time.Duration, 0, len(bounds)),
+		buckets: make([]selfstat.Stat, 0, len(bounds)+1),
+		sum:     selfstat.Register(measurement, "sum_ns", tags),
+	}
+	for _, bound := range bounds {
+		h.bounds = append(h.bounds, time.Duration(bound))
+		h.buckets = append(h.buckets, selfstat.Register(measurement, "le_"+time.Duration(bound).String(), tags))
+	}
+	h.buckets = append(h.buckets, selfstat.Register(measurement, "le_inf", tags))
+	return h
+}
+
+// observe adds the duration to the buckets it falls into.
+func (h *latencyHistogram) observe(d time.Duration) {
+	for i, bound := range h.bounds {
+		if d <= bound {
+			h.buckets[i].Incr(1)
+		}
+	}
+	h.buckets[len(h.buckets)-1].Incr(1)
+	h.sum.Incr(d.Nanoseconds())
+}
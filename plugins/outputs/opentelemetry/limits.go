@@ -0,0 +1,93 @@
+package opentelemetry
+
+import (
+	"sort"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// limitAttributes applies max_attributes_per_datapoint and
+// max_attribute_value_length to the data point attributes. Attributes beyond
+// the limit are dropped in the order of their keys, so the same attributes
+// survive on every data point.
+func (o *OpenTelemetry) limitAttributes(metrics pmetric.Metrics) {
+	if o.MaxAttributesPerDatapoint <= 0 && o.MaxAttributeValueLength <= 0 {
+		return
+	}
+
+	var dropped, truncated int
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				for _, attributes := range dataPointAttributes(sm.Metrics().At(k)) {
+					if o.MaxAttributesPerDatapoint > 0 {
+						dropped += dropAttributes(attributes, o.MaxAttributesPerDatapoint)
+					}
+					if o.MaxAttributeValueLength > 0 {
+						truncated += truncateAttributes(attributes, o.MaxAttributeValueLength)
+					}
+				}
+			}
+		}
+	}
+	if dropped > 0 {
+		o.Log.Debugf("Dropped %d data point attributes exceeding max_attributes_per_datapoint", dropped)
+	}
+	if truncated > 0 {
+		o.Log.Debugf("Truncated %d data point attribute values exceeding max_attribute_value_length", truncated)
+	}
+}
+
+// dropAttributes removes all but the first max attributes by key and returns
+// the number removed.
+func dropAttributes(attributes pcommon.Map, max int) int {
+	if attributes.Len() <= max {
+		return 0
+	}
+	keys := make([]string, 0, attributes.Len())
+	attributes.Range(func(k string, _ pcommon.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Strings(keys)
+	for _, k := range keys[max:] {
+		attributes.Remove(k)
+	}
+	return len(keys) - max
+}
+
+// truncateAttributes shortens string values to max characters and returns
+// the number of values truncated.
+func truncateAttributes(attributes pcommon.Map, max int) int {
+	var truncated int
+	attributes.Range(func(_ string, v pcommon.Value) bool {
+		if v.Type() != pcommon.ValueTypeString {
+			return true
+		}
+		if s, ok := truncateString(v.StringVal(), max); ok {
+			v.SetStringVal(s)
+			truncated++
+		}
+		return true
+	})
+	return truncated
+}
+
+// truncateString shortens s to max characters and reports whether it was
+// longer.
+func truncateString(s string, max int) (string, bool) {
+	if len(s) <= max {
+		return s, false
+	}
+	var n int
+	for i := range s {
+		if n == max {
+			return s[:i], true
+		}
+		n++
+	}
+	return s, false
+}
@@ -0,0 +1,203 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb-observability/common"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+const (
+	logMessageField       = "message"
+	logSeverityKey        = "severity"
+	logSeverityCodeField  = "severity_code"
+	defaultLogMeasurement = common.MeasurementLogs
+)
+
+// severityNumbers maps the usual severity names, including the syslog
+// keywords, to OTLP severity numbers.
+var severityNumbers = map[string]plog.SeverityNumber{
+	"trace":         plog.SeverityNumberTRACE,
+	"debug":         plog.SeverityNumberDEBUG,
+	"info":          plog.SeverityNumberINFO,
+	"informational": plog.SeverityNumberINFO,
+	"notice":        plog.SeverityNumberINFO2,
+	"warn":          plog.SeverityNumberWARN,
+	"warning":       plog.SeverityNumberWARN,
+	"err":           plog.SeverityNumberERROR,
+	"error":         plog.SeverityNumberERROR,
+	"crit":          plog.SeverityNumberFATAL2,
+	"critical":      plog.SeverityNumberFATAL2,
+	"fatal":         plog.SeverityNumberFATAL,
+	"alert":         plog.SeverityNumberFATAL3,
+	"emerg":         plog.SeverityNumberFATAL4,
+	"emergency":     plog.SeverityNumberFATAL4,
+}
+
+// syslogSeverityNumbers maps syslog severity codes 0 (emergency) to
+// 7 (debug) to OTLP severity numbers.
+var syslogSeverityNumbers = []plog.SeverityNumber{
+	plog.SeverityNumberFATAL4,
+	plog.SeverityNumberFATAL3,
+	plog.SeverityNumberFATAL2,
+	plog.SeverityNumberERROR,
+	plog.SeverityNumberWARN,
+	plog.SeverityNumberINFO2,
+	plog.SeverityNumberINFO,
+	plog.SeverityNumberDEBUG,
+}
+
+// logsBatch groups log records by resource and instrumentation scope.
+type logsBatch struct {
+	logs         plog.Logs
+	rlByResource map[string]plog.ResourceLogs
+	slByScope    map[string]map[string]plog.ScopeLogs
+	resourceTags []string
+	logger       common.Logger
+}
+
+func newLogsBatch(resourceTags []string, logger common.Logger) *logsBatch {
+	return &logsBatch{
+		logs:         plog.NewLogs(),
+		rlByResource: make(map[string]plog.ResourceLogs),
+		slByScope:    make(map[string]map[string]plog.ScopeLogs),
+		resourceTags: resourceTags,
+		logger:       logger,
+	}
+}
+
+func (b *logsBatch) GetLogs() plog.Logs {
+	return b.logs
+}
+
+func (b *logsBatch) AddLogRecord(tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	var scopeName, scopeVersion string
+	resourceAttributes := pcommon.NewMap()
+	record := plog.NewLogRecord()
+
+	bodyField := common.AttributeBody
+	if _, found := fields[bodyField]; !found {
+		bodyField = logMessageField
+	}
+	body, found := fields[bodyField]
+	if !found {
+		return fmt.Errorf("log record has no %q or %q field", common.AttributeBody, logMessageField)
+	}
+	record.Body().SetStringVal(fmt.Sprint(body))
+
+	values := make(map[string]interface{}, len(tags)+len(fields))
+	for k, v := range fields {
+		values[k] = v
+	}
+	for k, v := range tags {
+		values[k] = v
+	}
+
+	for k, v := range values {
+		switch {
+		case k == bodyField:
+			continue
+		case k == common.AttributeTraceID:
+			traceID, err := parseTraceID(v)
+			if err != nil {
+				return err
+			}
+			record.SetTraceID(traceID)
+		case k == common.AttributeSpanID:
+			spanID, err := parseSpanID(v)
+			if err != nil {
+				return err
+			}
+			record.SetSpanID(spanID)
+		case k == common.AttributeSeverityNumber:
+			if number, ok := v.(int64); ok {
+				record.SetSeverityNumber(plog.SeverityNumber(number))
+			}
+		case k == common.AttributeSeverityText:
+			record.SetSeverityText(fmt.Sprint(v))
+		case k == common.AttributeInstrumentationLibraryName:
+			scopeName = fmt.Sprint(v)
+		case k == common.AttributeInstrumentationLibraryVersion:
+			scopeVersion = fmt.Sprint(v)
+		case common.ResourceNamespace.MatchString(k) || isResourceTag(k, tags, b.resourceTags):
+			insertAttribute(resourceAttributes, k, v, b.logger)
+		default:
+			insertAttribute(record.Attributes(), k, v, b.logger)
+		}
+	}
+
+	if record.SeverityNumber() == plog.SeverityNumberUNDEFINED {
+		record.SetSeverityNumber(severityNumber(values))
+	}
+	if record.SeverityText() == "" {
+		if severity, found := values[logSeverityKey]; found {
+			record.SetSeverityText(fmt.Sprint(severity))
+		}
+	}
+
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	record.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+	record.SetObservedTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+
+	record.Attributes().Sort()
+	resourceAttributes.Sort()
+	record.MoveTo(b.lookupScopeLogs(resourceAttributes, scopeName, scopeVersion).LogRecords().AppendEmpty())
+	return nil
+}
+
+func (b *logsBatch) lookupScopeLogs(resourceAttributes pcommon.Map, scopeName, scopeVersion string) plog.ScopeLogs {
+	rKey := attributesToKey(resourceAttributes)
+	resourceLogs, found := b.rlByResource[rKey]
+	if !found {
+		resourceLogs = b.logs.ResourceLogs().AppendEmpty()
+		resourceAttributes.CopyTo(resourceLogs.Resource().Attributes())
+		b.rlByResource[rKey] = resourceLogs
+		b.slByScope[rKey] = make(map[string]plog.ScopeLogs)
+	}
+
+	sKey := scopeName + ":" + scopeVersion
+	scopeLogs, found := b.slByScope[rKey][sKey]
+	if !found {
+		scopeLogs = resourceLogs.ScopeLogs().AppendEmpty()
+		scopeLogs.Scope().SetName(scopeName)
+		scopeLogs.Scope().SetVersion(scopeVersion)
+		b.slByScope[rKey][sKey] = scopeLogs
+	}
+	return scopeLogs
+}
+
+// severityNumber derives the OTLP severity from either a syslog style
+// severity code or a severity name.
+func severityNumber(values map[string]interface{}) plog.SeverityNumber {
+	if code, found := values[logSeverityCodeField]; found {
+		var n int64
+		switch v := code.(type) {
+		case int64:
+			n = v
+		case uint64:
+			n = int64(v)
+		case string:
+			var err error
+			if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+				n = -1
+			}
+		default:
+			n = -1
+		}
+		if n >= 0 && n < int64(len(syslogSeverityNumbers)) {
+			return syslogSeverityNumbers[n]
+		}
+	}
+	if severity, found := values[logSeverityKey]; found {
+		if number, found := severityNumbers[strings.ToLower(fmt.Sprint(severity))]; found {
+			return number
+		}
+	}
+	return plog.SeverityNumberUNDEFINED
+}
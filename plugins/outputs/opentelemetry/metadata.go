@@ -0,0 +1,92 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+// metadataGroup are metrics sharing the values of the metadata_tags, sent
+// with the headers taken from them.
+type metadataGroup struct {
+	headers map[string]string
+	metrics []telegraf.Metric
+}
+
+// checkMetadataTags validates the metadata_tags table.
+func (o *OpenTelemetry) checkMetadataTags() error {
+	for tag, name := range o.MetadataTags {
+		if name == "" {
+			return fmt.Errorf("metadata name for tag %q in metadata_tags must not be empty", tag)
+		}
+	}
+	return nil
+}
+
+// groupByMetadata groups the metrics by the values of the metadata_tags and
+// baggage_tags, in the order the groups are first seen. Tags missing from a
+// metric add no header, so the metrics without any of the tags are sent with
+// the static headers only.
+func (o *OpenTelemetry) groupByMetadata(metrics []telegraf.Metric) []metadataGroup {
+	tags := make([]string, 0, len(o.MetadataTags))
+	for tag := range o.MetadataTags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	groups := make(map[string]*metadataGroup)
+	var order []string
+	for _, metric := range metrics {
+		var key strings.Builder
+		var headers map[string]string
+		for _, tag := range tags {
+			value, ok := metric.GetTag(tag)
+			if !ok {
+				continue
+			}
+			if headers == nil {
+				headers = make(map[string]string, len(tags))
+			}
+			headers[o.MetadataTags[tag]] = value
+			fmt.Fprintf(&key, "%q=%q,", tag, value)
+		}
+		if baggage := o.tagBaggage(metric); baggage != "" {
+			if headers == nil {
+				headers = make(map[string]string, 1)
+			}
+			headers[baggageHeader] = baggage
+			fmt.Fprintf(&key, "%s=%q", baggageHeader, baggage)
+		}
+		group, ok := groups[key.String()]
+		if !ok {
+			group = &metadataGroup{headers: headers}
+			groups[key.String()] = group
+			order = append(order, key.String())
+		}
+		group.metrics = append(group.metrics, metric)
+	}
+
+	result := make([]metadataGroup, 0, len(order))
+	for _, key := range order {
+		result = append(result, *groups[key])
+	}
+	return result
+}
+
+// mergeHeaders returns the static headers overridden by the headers of the
+// request. The names are compared case-insensitively, like gRPC metadata keys.
+func mergeHeaders(static, request map[string]string) map[string]string {
+	if len(request) == 0 {
+		return static
+	}
+	merged := make(map[string]string, len(static)+len(request))
+	for k, v := range static {
+		merged[strings.ToLower(k)] = v
+	}
+	for k, v := range request {
+		merged[strings.ToLower(k)] = v
+	}
+	return merged
+}
@@ -0,0 +1,214 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/influxdata/influxdb-observability/common"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+)
+
+// valueTypes are the Telegraf metric types by their name in include_types and
+// exclude_types.
+var valueTypes = map[string]telegraf.ValueType{
+	"counter":   telegraf.Counter,
+	"gauge":     telegraf.Gauge,
+	"untyped":   telegraf.Untyped,
+	"summary":   telegraf.Summary,
+	"histogram": telegraf.Histogram,
+}
+
+const (
+	metricTypeGauge           = "gauge"
+	metricTypeSum             = "sum"
+	metricTypeNonMonotonicSum = "non_monotonic_sum"
+	metricTypeHistogram       = "histogram"
+)
+
+// compileTypeFilter determines the Telegraf metric types to drop according to
+// include_types and exclude_types.
+func (o *OpenTelemetry) compileTypeFilter() error {
+	o.excludedTypes = nil
+	if len(o.IncludeTypes) == 0 && len(o.ExcludeTypes) == 0 {
+		return nil
+	}
+
+	included := make(map[telegraf.ValueType]bool, len(valueTypes))
+	for _, t := range valueTypes {
+		included[t] = len(o.IncludeTypes) == 0
+	}
+	for _, name := range o.IncludeTypes {
+		t, ok := valueTypes[name]
+		if !ok {
+			return fmt.Errorf("unsupported type %q in include_types", name)
+		}
+		included[t] = true
+	}
+	for _, name := range o.ExcludeTypes {
+		t, ok := valueTypes[name]
+		if !ok {
+			return fmt.Errorf("unsupported type %q in exclude_types", name)
+		}
+		included[t] = false
+	}
+
+	o.excludedTypes = make(map[telegraf.ValueType]bool)
+	for t, ok := range included {
+		if !ok {
+			o.excludedTypes[t] = true
+		}
+	}
+	return nil
+}
+
+// metricTypeOverride sets the type of the fields matching the pattern.
+type metricTypeOverride struct {
+	pattern      string
+	filter       filter.Filter
+	vType        common.InfluxMetricValueType
+	nonMonotonic bool
+}
+
+// fieldGroup are fields of a metric converted with the same type.
+type fieldGroup struct {
+	fields       map[string]interface{}
+	vType        common.InfluxMetricValueType
+	nonMonotonic bool
+	stale        bool
+}
+
+// compileMetricTypes compiles the metric_types table. Longer patterns take
+// precedence, so specific field patterns win over measurement patterns.
+func (o *OpenTelemetry) compileMetricTypes() error {
+	patterns := sortedPatterns(o.MetricTypes)
+	o.metricTypes = make([]metricTypeOverride, 0, len(patterns))
+	for _, pattern := range patterns {
+		override := metricTypeOverride{pattern: pattern}
+		switch o.MetricTypes[pattern] {
+		case metricTypeGauge:
+			override.vType = common.InfluxMetricValueTypeGauge
+		case metricTypeSum:
+			override.vType = common.InfluxMetricValueTypeSum
+		case metricTypeNonMonotonicSum:
+			override.vType = common.InfluxMetricValueTypeSum
+			override.nonMonotonic = true
+		case metricTypeHistogram:
+			override.vType = common.InfluxMetricValueTypeHistogram
+		default:
+			return fmt.Errorf("unsupported metric type %q for %q in metric_types", o.MetricTypes[pattern], pattern)
+		}
+		f, err := filter.Compile([]string{pattern})
+		if err != nil {
+			return fmt.Errorf("invalid metric_types pattern %q: %w", pattern, err)
+		}
+		override.filter = f
+		o.metricTypes = append(o.metricTypes, override)
+	}
+	return nil
+}
+
+// groupFieldsByType splits the fields of the metric by their type. A field
+// takes the type of the first pattern matching either "<measurement>.<field>"
+// or the measurement, and keeps the type of the metric otherwise.
+func (o *OpenTelemetry) groupFieldsByType(measurement string, fields map[string]interface{}, vType common.InfluxMetricValueType) []fieldGroup {
+	if len(o.metricTypes) == 0 {
+		return []fieldGroup{{fields: fields, vType: vType}}
+	}
+
+	groups := make(map[int]*fieldGroup)
+	var order []int
+	for k, v := range fields {
+		index := -1
+		for i, override := range o.metricTypes {
+			if override.filter.Match(measurement+"."+k) || override.filter.Match(measurement) {
+				index = i
+				break
+			}
+		}
+		group, ok := groups[index]
+		if !ok {
+			group = &fieldGroup{fields: make(map[string]interface{}), vType: vType}
+			if index >= 0 {
+				group.vType = o.metricTypes[index].vType
+				group.nonMonotonic = o.metricTypes[index].nonMonotonic
+			}
+			groups[index] = group
+			order = append(order, index)
+		}
+		group.fields[k] = v
+	}
+
+	sort.Ints(order)
+	result := make([]fieldGroup, 0, len(order))
+	for _, index := range order {
+		result = append(result, *groups[index])
+	}
+	return result
+}
+
+// isNonMonotonicCounter reports whether the field of the counter is listed in
+// non_monotonic_counters, either as "<measurement>.<field>" or by the
+// measurement.
+func (o *OpenTelemetry) isNonMonotonicCounter(measurement, field string) bool {
+	if o.nonMonotonicCounters == nil {
+		return false
+	}
+	return o.nonMonotonicCounters.Match(measurement+"."+field) || o.nonMonotonicCounters.Match(measurement)
+}
+
+// sortedPatterns returns the keys of the pattern table, longest first.
+func sortedPatterns(table map[string]string) []string {
+	patterns := make([]string, 0, len(table))
+	for pattern := range table {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	return patterns
+}
+
+// convertedNames returns the names of the metrics the converter creates for
+// the fields, by field. Histograms and summaries become a single metric.
+func convertedNames(name string, fields map[string]interface{}, vType common.InfluxMetricValueType) map[string]string {
+	names := make(map[string]string, len(fields))
+	switch vType {
+	case common.InfluxMetricValueTypeHistogram, common.InfluxMetricValueTypeSummary:
+		for k := range fields {
+			names[k] = name
+		}
+		return names
+	}
+	for _, single := range []string{common.MetricGaugeFieldKey, common.MetricCounterFieldKey} {
+		if _, ok := fields[single]; ok {
+			names[single] = name
+			return names
+		}
+	}
+	for k := range fields {
+		names[k] = name + "_" + k
+	}
+	return names
+}
+
+// setNonMonotonic marks the sums with the given names as non-monotonic.
+func setNonMonotonic(metrics pmetric.Metrics, names map[string]bool) {
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+				if metric.DataType() == pmetric.MetricDataTypeSum && names[metric.Name()] {
+					metric.Sum().SetIsMonotonic(false)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package opentelemetry
+
+import (
+	"math"
+)
+
+const (
+	nonFiniteDrop  = "drop"
+	nonFiniteZero  = "zero"
+	nonFinitePass  = "pass"
+	nonFiniteStale = "stale"
+)
+
+// handleNonFinite drops or zeroes the NaN and infinite values of the fields
+// depending on non_finite_handling.
+func (o *OpenTelemetry) handleNonFinite(name string, fields map[string]interface{}) map[string]interface{} {
+	for key, value := range fields {
+		v, ok := value.(float64)
+		if !ok || !math.IsNaN(v) && !math.IsInf(v, 0) {
+			continue
+		}
+		if o.NonFiniteHandling == nonFiniteZero {
+			fields[key] = float64(0)
+			continue
+		}
+		o.Log.Debugf("Dropped non-finite value %v of field %q of metric %q", v, key, name)
+		delete(fields, key)
+	}
+	return fields
+}
@@ -0,0 +1,68 @@
+package opentelemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// tokenError is returned when no OAuth2 token could be obtained. It is
+// retried like a transient export failure.
+type tokenError struct {
+	err error
+}
+
+func (e *tokenError) Error() string {
+	return fmt.Sprintf("failed to obtain OAuth2 token: %v", e.err)
+}
+
+func (e *tokenError) Unwrap() error {
+	return e.err
+}
+
+func (o *OpenTelemetry) checkOAuth2() error {
+	if o.OAuth2.TokenURL == "" && o.OAuth2.ClientID == "" && o.OAuth2.ClientSecret == "" {
+		return nil
+	}
+	if o.OAuth2.TokenURL == "" || o.OAuth2.ClientID == "" || o.OAuth2.ClientSecret == "" {
+		return fmt.Errorf("oauth2 requires token_url, client_id and client_secret")
+	}
+	return nil
+}
+
+// connectOAuth2 sets up the token source if OAuth2 is configured. Tokens are
+// cached and refreshed once they expire.
+func (o *OpenTelemetry) connectOAuth2() {
+	if o.OAuth2.TokenURL == "" {
+		return
+	}
+	cfg := clientcredentials.Config{
+		ClientID:     o.OAuth2.ClientID,
+		ClientSecret: o.OAuth2.ClientSecret,
+		TokenURL:     o.OAuth2.TokenURL,
+		Scopes:       o.OAuth2.Scopes,
+	}
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+		Timeout:   time.Duration(o.Timeout),
+	}
+	o.tokenSource = cfg.TokenSource(context.WithValue(context.Background(), oauth2.HTTPClient, client))
+}
+
+// authorize adds the authorization metadata for the current OAuth2 token to
+// the outgoing context.
+func (o *OpenTelemetry) authorize(ctx context.Context) (context.Context, error) {
+	if o.tokenSource == nil {
+		return ctx, nil
+	}
+	token, err := o.tokenSource.Token()
+	if err != nil {
+		return ctx, &tokenError{err: err}
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", token.Type()+" "+token.AccessToken), nil
+}
@@ -2,113 +2,1028 @@
 package opentelemetry
 
 import (
+	"compress/gzip"
 	"context"
 	_ "embed"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/influxdb-observability/common"
 	"github.com/influxdata/influxdb-observability/influx2otel"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/oauth2"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/common/oauth"
+	"github.com/influxdata/telegraf/plugins/common/proxy"
 	"github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/outputs"
 )
 
 // DO NOT REMOVE THE NEXT TWO LINES! This is required to embed the sampleConfig data.
+//
 //go:embed sample.conf
 var sampleConfig string
 
 type OpenTelemetry struct {
-	ServiceAddress string `toml:"service_address"`
+	ServiceAddress string   `toml:"service_address"`
+	Endpoints      []string `toml:"endpoints"`
+	Protocol       string   `toml:"protocol"`
+	Encoding       string   `toml:"encoding"`
+	Authority      string   `toml:"authority"`
+	UserAgent      string   `toml:"user_agent"`
 
 	tls.ClientConfig
-	Timeout     config.Duration   `toml:"timeout"`
-	Compression string            `toml:"compression"`
-	Headers     map[string]string `toml:"headers"`
-	Attributes  map[string]string `toml:"attributes"`
+	TLSReloadInterval config.Duration        `toml:"tls_reload_interval"`
+	Insecure          *bool                  `toml:"insecure"`
+	EndpointTLS       map[string]EndpointTLS `toml:"endpoint_tls"`
+	proxy.TCPProxy
+
+	Timeout                 config.Duration   `toml:"timeout"`
+	TimeoutPer1kPoints      config.Duration   `toml:"timeout_per_1k_points"`
+	MaxTimeout              config.Duration   `toml:"max_timeout"`
+	TimeoutHeader           string            `toml:"timeout_header"`
+	Compression             string            `toml:"compression"`
+	CompressionLevel        int               `toml:"compression_level"`
+	CompressionMinSize      config.Size       `toml:"compression_min_size"`
+	CompressionFallback     bool              `toml:"compression_fallback"`
+	MaxMsgSize              config.Size       `toml:"max_msg_size"`
+	MaxPayloadSize          config.Size       `toml:"max_payload_size"`
+	MaxDatapointsPerRequest int               `toml:"max_datapoints_per_request"`
+	MaxConcurrentExports    int               `toml:"max_concurrent_exports"`
+	PreferredBatchSize      int               `toml:"preferred_batch_size"`
+	MaxBatchHold            config.Duration   `toml:"max_batch_hold"`
+	SortOutput              bool              `toml:"sort_output"`
+	Headers                 map[string]string `toml:"headers"`
+	Attributes              map[string]string `toml:"attributes"`
+
+	MetadataTags map[string]string `toml:"metadata_tags"`
+	Baggage      map[string]string `toml:"baggage"`
+	BaggageTags  []string          `toml:"baggage_tags"`
+
+	AttributeRules []AttributeRule `toml:"attribute_rule"`
+
+	UndefinedEnvBehavior string `toml:"undefined_env_behavior"`
+
+	ResourceAttributesFile           string          `toml:"resource_attributes_file"`
+	ResourceAttributesReloadInterval config.Duration `toml:"resource_attributes_reload_interval"`
+
+	HeadersFile           string          `toml:"headers_file"`
+	HeadersReloadInterval config.Duration `toml:"headers_reload_interval"`
+
+	ServiceName string `toml:"service_name"`
+
+	ResourceDetection bool     `toml:"resource_detection"`
+	ResourceDetectors []string `toml:"resource_detectors"`
+
+	IncludeAgentMetadata bool `toml:"include_agent_metadata"`
+
+	Metrics *SignalConfig `toml:"metrics"`
+	Traces  *SignalConfig `toml:"traces"`
+	Logs    *SignalConfig `toml:"logs"`
+
+	OAuth2 oauth.OAuth2Config `toml:"oauth2"`
+
+	GRPCRetryPolicy *GRPCRetryPolicy `toml:"grpc_retry_policy"`
+
+	BalancerPolicy     string          `toml:"balancer_policy"`
+	DNSRefreshInterval config.Duration `toml:"dns_refresh_interval"`
+	WaitForReady       bool            `toml:"wait_for_ready"`
+	ConnectTimeout     config.Duration `toml:"connect_timeout"`
+
+	HealthCheckInterval config.Duration `toml:"health_check_interval"`
+	HealthCheckService  string          `toml:"health_check_service"`
+
+	KeepaliveTime                config.Duration `toml:"keepalive_time"`
+	KeepaliveTimeout             config.Duration `toml:"keepalive_timeout"`
+	KeepalivePermitWithoutStream bool            `toml:"keepalive_permit_without_stream"`
+
+	GRPCInitialWindowSize     config.Size `toml:"grpc_initial_window_size"`
+	GRPCInitialConnWindowSize config.Size `toml:"grpc_initial_conn_window_size"`
+
+	ResourceTags []string `toml:"resource_tags"`
+
+	MaxAttributeValueLength   int    `toml:"max_attribute_value_length"`
+	MaxAttributesPerDatapoint int    `toml:"max_attributes_per_datapoint"`
+	ScopeName                 string `toml:"scope_name"`
+	ScopeVersion              string `toml:"scope_version"`
+	SchemaURL                 string `toml:"schema_url"`
+
+	MaxSeries                 int             `toml:"max_series"`
+	MaxSeriesWindow           config.Duration `toml:"max_series_window"`
+	HighCardinalityAttributes []string        `toml:"high_cardinality_attributes"`
+
+	Namespace                 string `toml:"namespace"`
+	NamespaceSeparator        string `toml:"namespace_separator"`
+	SanitizeNames             bool   `toml:"sanitize_names"`
+	PrometheusCompatibleNames bool   `toml:"prometheus_compatible_names"`
+	NonFiniteHandling         string `toml:"non_finite_handling"`
+	StaleTag                  string `toml:"stale_tag"`
+	UntypedAs                 string `toml:"untyped_as"`
+	StrictTypes               bool   `toml:"strict_types"`
+	EnableExemplars           bool   `toml:"enable_exemplars"`
+
+	MaxMetricAge        config.Duration `toml:"max_metric_age"`
+	MaxFutureDrift      config.Duration `toml:"max_future_drift"`
+	FutureDriftHandling string          `toml:"future_drift_handling"`
+
+	QuantileFieldPattern string            `toml:"quantile_field_pattern"`
+	MetricTypes          map[string]string `toml:"metric_types"`
+	NonMonotonicCounters []string          `toml:"non_monotonic_counters"`
+	MetricInclude        []string          `toml:"metric_include"`
+	MetricExclude        []string          `toml:"metric_exclude"`
+	FieldInclude         []string          `toml:"field_include"`
+	FieldExclude         []string          `toml:"field_exclude"`
+	StringFields         string            `toml:"string_fields"`
+	StringOnlyMetrics    string            `toml:"string_only_metrics"`
+	BooleanFields        string            `toml:"boolean_fields"`
+	BoolFieldsAsGauge    bool              `toml:"bool_fields_as_gauge"`
+	BoolTrueValue        *float64          `toml:"bool_true_value"`
+	BoolFalseValue       *float64          `toml:"bool_false_value"`
+	NonNumericMetrics    string            `toml:"non_numeric_metrics"`
+	HashAttributes       []string          `toml:"hash_attributes"`
+	HashSalt             string            `toml:"hash_salt"`
+	DropAttributes       []string          `toml:"drop_attributes"`
+	IncludeTypes         []string          `toml:"include_types"`
+	ExcludeTypes         []string          `toml:"exclude_types"`
+	Units                map[string]string `toml:"units"`
+	UnitTag              string            `toml:"unit_tag"`
+	Descriptions         map[string]string `toml:"descriptions"`
+	DescriptionTag       string            `toml:"description_tag"`
+
+	AggregationTemporality string            `toml:"aggregation_temporality"`
+	Temporality            map[string]string `toml:"temporality"`
+	HistogramType          string            `toml:"histogram_type"`
+
+	LogMeasurements []string `toml:"log_measurements"`
+
+	QueueSize    int    `toml:"queue_size"`
+	NumConsumers int    `toml:"num_consumers"`
+	OnFull       string `toml:"on_full"`
+
+	DiskQueuePath    string      `toml:"disk_queue_path"`
+	DiskQueueMaxSize config.Size `toml:"disk_queue_max_size"`
+
+	ShutdownTimeout config.Duration `toml:"shutdown_timeout"`
+
+	HeartbeatInterval config.Duration `toml:"heartbeat_interval"`
+	HeartbeatName     string          `toml:"heartbeat_name"`
+
+	DryRun bool `toml:"dry_run"`
+
+	LogPayloads        bool        `toml:"log_payloads"`
+	LogPayloadsMaxSize config.Size `toml:"log_payloads_max_size"`
+	RedactKeys         []string    `toml:"redact_keys"`
+
+	MaxRetries           int             `toml:"max_retries"`
+	RetryInitialInterval config.Duration `toml:"retry_initial_interval"`
+	RetryMaxInterval     config.Duration `toml:"retry_max_interval"`
+	RetryJitter          float64         `toml:"retry_jitter"`
+
+	CircuitBreakerThreshold int             `toml:"circuit_breaker_threshold"`
+	CircuitBreakerCooldown  config.Duration `toml:"circuit_breaker_cooldown"`
+
+	ExportLatencyBuckets []config.Duration `toml:"export_latency_buckets"`
 
 	Log telegraf.Logger `toml:"-"`
 
+	// metricsConverter holds no state but the logger, so it is safe to
+	// create batches concurrently. A batch must only be used by the
+	// goroutine that created it.
 	metricsConverter     *influx2otel.LineProtocolToOtelMetrics
 	grpcClientConn       *grpc.ClientConn
 	metricsServiceClient pmetricotlp.Client
+	tracesServiceClient  ptraceotlp.Client
+	logsServiceClient    plogotlp.Client
 	callOptions          []grpc.CallOption
+	tokenSource          oauth2.TokenSource
+	deltaConverter       *deltaConverter
+	seriesLimiter        *seriesLimiter
+	cardinalityFilter    filter.Filter
+	startTimes           *startTimeTracker
+	stats                exportStats
+	loggedRenames        int
+	loggedFutureMetrics  int
+	quantilePattern      *regexp.Regexp
+	metricTypes          []metricTypeOverride
+	temporalities        []temporalityOverride
+	nonMonotonicCounters filter.Filter
+	excludedTypes        map[telegraf.ValueType]bool
+	metricFilter         filter.Filter
+	fieldFilter          filter.Filter
+	boolTrue             float64
+	boolFalse            float64
+	hashFilter           filter.Filter
+	dropFilter           filter.Filter
+	attributeRules       []attributeRule
+	ruleTags             []string
+	units                []unitMapping
+	descriptions         []descriptionMapping
+	detectedAttributes   map[string]string
+	agentAttributes      map[string]string
+	attributesFile       *valuesFile
+	headersFile          *valuesFile
+	healthCheck          *healthChecker
+	batcher              *batcher
+	heartbeat            *heartbeat
+
+	// uncompressed is set atomically once the collector rejected the
+	// compression and compression_fallback is enabled.
+	uncompressed int32
+
+	httpClient *http.Client
+	baseURL    string
+
+	// The current endpoint is guarded by endpointMu as the send queue
+	// exports concurrently.
+	endpointMu      *sync.Mutex
+	drainer         *drainer
+	endpoints       []*endpoint
+	currentEndpoint int
+	dialOptions     []grpc.DialOption
+
+	metricsOutput *OpenTelemetry
+	tracesOutput  *OpenTelemetry
+	logsOutput    *OpenTelemetry
+	// derived is set for the outputs of the signals, which inherit the
+	// expanded headers, attributes and agent metadata of the plugin.
+	derived bool
+
+	proxyDialer *proxy.ProxiedDialer
+	queue       *sendQueue
+	diskQueue   *diskQueue
+	breaker     *circuitBreaker
 }
 
 func (*OpenTelemetry) SampleConfig() string {
 	return sampleConfig
 }
 
-func (o *OpenTelemetry) Connect() error {
-	logger := &otelLogger{o.Log}
+func (o *OpenTelemetry) Init() error {
+	if !o.derived {
+		if err := o.collectAgentMetadata(); err != nil {
+			return err
+		}
+		if err := o.expandEnvVars(); err != nil {
+			return err
+		}
+		if err := o.initSignals(); err != nil {
+			return err
+		}
+	}
 
+	if o.Protocol == "" {
+		o.Protocol = defaultProtocol
+	}
+	if o.ServiceAddress != "" && len(o.Endpoints) > 0 {
+		return fmt.Errorf("service_address and endpoints are mutually exclusive")
+	}
+	if len(o.Endpoints) > 0 {
+		o.ServiceAddress = o.Endpoints[0]
+	}
 	if o.ServiceAddress == "" {
-		o.ServiceAddress = defaultServiceAddress
+		if o.Protocol == protocolHTTPProtobuf {
+			o.ServiceAddress = defaultHTTPServiceAddress
+		} else {
+			o.ServiceAddress = defaultServiceAddress
+		}
+	}
+
+	for _, address := range o.addresses() {
+		switch o.Protocol {
+		case protocolGRPC:
+			if err := checkGRPCAddress(address); err != nil {
+				return err
+			}
+		case protocolHTTPProtobuf:
+			if err := checkHTTPAddress(address); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported protocol %q", o.Protocol)
+		}
+	}
+
+	switch o.Encoding {
+	case "":
+		o.Encoding = encodingProtobuf
+	case encodingProtobuf:
+	case encodingJSON:
+		if o.Protocol != protocolHTTPProtobuf {
+			return fmt.Errorf("encoding %q is only supported with the %q protocol", o.Encoding, protocolHTTPProtobuf)
+		}
+	default:
+		return fmt.Errorf("unsupported encoding %q", o.Encoding)
+	}
+
+	if o.TimeoutHeader != "" {
+		if o.Protocol != protocolHTTPProtobuf {
+			return fmt.Errorf("timeout_header is only supported with the %q protocol", protocolHTTPProtobuf)
+		}
+		if !httpguts.ValidHeaderFieldName(o.TimeoutHeader) {
+			return fmt.Errorf("invalid timeout_header %q", o.TimeoutHeader)
+		}
+	}
+
+	if o.UserAgent == "" {
+		o.UserAgent = internal.ProductToken()
+	}
+
+	if err := o.checkInsecure(); err != nil {
+		return err
+	}
+	if err := o.checkEndpointTLS(); err != nil {
+		return err
+	}
+
+	if o.Authority != "" {
+		if strings.Contains(o.Authority, "/") {
+			return fmt.Errorf("invalid authority %q", o.Authority)
+		}
+		// A different server name would fail the handshake with servers
+		// selecting the certificate by the name.
+		if o.ServerName != "" && o.ServerName != authorityHost(o.Authority) {
+			return fmt.Errorf("authority %q does not match tls_server_name %q", o.Authority, o.ServerName)
+		}
+	}
+
+	if o.UseProxy {
+		for _, address := range o.addresses() {
+			if strings.HasPrefix(address, "unix:") {
+				return fmt.Errorf("use_proxy is not supported with Unix domain sockets")
+			}
+		}
+		dialer, err := o.TCPProxy.Proxy()
+		if err != nil {
+			return fmt.Errorf("creating proxy failed: %w", err)
+		}
+		o.proxyDialer = dialer
+	}
+
+	if o.TLSReloadInterval < 0 {
+		return fmt.Errorf("tls_reload_interval must not be negative")
 	}
+
 	if o.Timeout <= 0 {
 		o.Timeout = defaultTimeout
 	}
+	if o.MaxMetricAge < 0 || o.MaxFutureDrift < 0 {
+		return fmt.Errorf("max_metric_age and max_future_drift must not be negative")
+	}
+	switch o.FutureDriftHandling {
+	case "":
+		o.FutureDriftHandling = futureDriftDrop
+	case futureDriftDrop, futureDriftClamp:
+	default:
+		return fmt.Errorf("unsupported future_drift_handling %q", o.FutureDriftHandling)
+	}
+	if o.MaxDatapointsPerRequest < 0 {
+		return fmt.Errorf("max_datapoints_per_request must not be negative")
+	}
+	if o.MaxConcurrentExports < 0 {
+		return fmt.Errorf("max_concurrent_exports must not be negative")
+	}
+	if o.PreferredBatchSize < 0 {
+		return fmt.Errorf("preferred_batch_size must not be negative")
+	}
+	if o.MaxBatchHold < 0 {
+		return fmt.Errorf("max_batch_hold must not be negative")
+	}
+	if o.MaxBatchHold == 0 {
+		o.MaxBatchHold = defaultMaxBatchHold
+	}
+	if o.MaxConcurrentExports == 0 {
+		o.MaxConcurrentExports = 1
+	}
+	if o.MaxAttributeValueLength < 0 || o.MaxAttributesPerDatapoint < 0 {
+		return fmt.Errorf("max_attribute_value_length and max_attributes_per_datapoint must not be negative")
+	}
+	if o.TimeoutPer1kPoints < 0 {
+		return fmt.Errorf("timeout_per_1k_points must not be negative")
+	}
+	if err := o.compileSeriesLimit(); err != nil {
+		return err
+	}
+	if o.MaxTimeout > 0 && o.MaxTimeout < o.Timeout {
+		return fmt.Errorf("max_timeout must not be less than timeout")
+	}
 	if o.Compression == "" {
 		o.Compression = defaultCompression
 	}
+	if err := checkCompression(o.Compression); err != nil {
+		return err
+	}
+	if o.Protocol == protocolGRPC {
+		registerCompressor(o.Compression)
+	}
+	if o.CompressionLevel != 0 {
+		if o.Compression != "gzip" {
+			return fmt.Errorf("compression_level is only supported with gzip compression")
+		}
+		if o.CompressionLevel < gzip.BestSpeed || o.CompressionLevel > gzip.BestCompression {
+			return fmt.Errorf("compression_level must be between %d and %d", gzip.BestSpeed, gzip.BestCompression)
+		}
+	}
+
+	if o.CompressionMinSize < 0 {
+		return fmt.Errorf("compression_min_size must not be negative")
+	}
+
+	switch o.BalancerPolicy {
+	case "":
+		o.BalancerPolicy = balancerPickFirst
+	case balancerPickFirst, balancerRoundRobin:
+	default:
+		return fmt.Errorf("unsupported balancer_policy %q", o.BalancerPolicy)
+	}
+	if err := o.checkGRPCRetryPolicy(); err != nil {
+		return err
+	}
+	if o.DNSRefreshInterval != 0 && o.DNSRefreshInterval < minDNSRefreshInterval {
+		return fmt.Errorf("dns_refresh_interval must be at least %s", time.Duration(minDNSRefreshInterval))
+	}
+
+	if o.ConnectTimeout <= 0 {
+		o.ConnectTimeout = o.Timeout
+	}
+	if o.HealthCheckInterval < 0 {
+		return fmt.Errorf("health_check_interval must not be negative")
+	}
+
+	if o.KeepaliveTime != 0 && o.KeepaliveTime < minKeepaliveTime {
+		return fmt.Errorf("keepalive_time must be at least %s", time.Duration(minKeepaliveTime))
+	}
+	if o.KeepaliveTimeout < 0 {
+		return fmt.Errorf("keepalive_timeout must not be negative")
+	}
+
+	if s := o.GRPCInitialWindowSize; s != 0 && (s < minWindowSize || s > math.MaxInt32) {
+		return fmt.Errorf("grpc_initial_window_size must be between %d and %d bytes", minWindowSize, math.MaxInt32)
+	}
+	if s := o.GRPCInitialConnWindowSize; s != 0 && (s < minWindowSize || s > math.MaxInt32) {
+		return fmt.Errorf("grpc_initial_conn_window_size must be between %d and %d bytes", minWindowSize, math.MaxInt32)
+	}
+
+	if o.MaxMsgSize < 0 || o.MaxMsgSize > math.MaxInt32 {
+		return fmt.Errorf("max_msg_size must be between 0 and %d bytes", math.MaxInt32)
+	}
+
+	switch o.AggregationTemporality {
+	case "":
+		o.AggregationTemporality = temporalityCumulative
+	case temporalityCumulative, temporalityDelta:
+	default:
+		return fmt.Errorf("unsupported aggregation_temporality %q", o.AggregationTemporality)
+	}
+	if err := o.compileTemporality(); err != nil {
+		return err
+	}
+
+	switch o.HistogramType {
+	case "":
+		o.HistogramType = histogramTypeExplicit
+	case histogramTypeExplicit, histogramTypeExponential:
+	default:
+		return fmt.Errorf("unsupported histogram_type %q", o.HistogramType)
+	}
+
+	if o.Namespace != "" && o.NamespaceSeparator == "" {
+		o.NamespaceSeparator = defaultNamespaceSeparator
+	}
+
+	switch o.NonFiniteHandling {
+	case "":
+		o.NonFiniteHandling = nonFinitePass
+	case nonFiniteDrop, nonFiniteZero, nonFinitePass, nonFiniteStale:
+	default:
+		return fmt.Errorf("unsupported non_finite_handling %q", o.NonFiniteHandling)
+	}
+
+	switch o.UntypedAs {
+	case "":
+		o.UntypedAs = untypedAsUntyped
+	case untypedAsUntyped, untypedAsGauge, untypedAsSum:
+	default:
+		return fmt.Errorf("unsupported untyped_as %q", o.UntypedAs)
+	}
+
+	if o.QuantileFieldPattern == "" {
+		o.QuantileFieldPattern = defaultQuantileFieldPattern
+	}
+	quantilePattern, err := regexp.Compile(o.QuantileFieldPattern)
+	if err != nil {
+		return fmt.Errorf("invalid quantile_field_pattern: %w", err)
+	}
+	if quantilePattern.NumSubexp() < 1 {
+		return fmt.Errorf("quantile_field_pattern needs a capturing group for the percentile")
+	}
+	o.quantilePattern = quantilePattern
+	if err := o.compileMetricTypes(); err != nil {
+		return err
+	}
+	if o.nonMonotonicCounters, err = filter.Compile(o.NonMonotonicCounters); err != nil {
+		return fmt.Errorf("invalid non_monotonic_counters: %w", err)
+	}
+	if err := o.compileTypeFilter(); err != nil {
+		return err
+	}
+	o.metricFilter = nil
+	if len(o.MetricInclude) > 0 || len(o.MetricExclude) > 0 {
+		if o.metricFilter, err = filter.NewIncludeExcludeFilter(o.MetricInclude, o.MetricExclude); err != nil {
+			return fmt.Errorf("invalid metric_include or metric_exclude: %w", err)
+		}
+	}
+	if err := o.compileFieldFilter(); err != nil {
+		return err
+	}
+	if err := o.compileAttributePrivacy(); err != nil {
+		return err
+	}
+	if err := o.compileUnits(); err != nil {
+		return err
+	}
+	if err := o.compileDescriptions(); err != nil {
+		return err
+	}
+	if err := o.checkMetadataTags(); err != nil {
+		return err
+	}
+	if err := o.compileBaggage(); err != nil {
+		return err
+	}
+	if err := o.compileAttributeRules(); err != nil {
+		return err
+	}
+	if err := o.detectResources(); err != nil {
+		return err
+	}
+	if o.ServiceName == "" {
+		o.ServiceName = defaultServiceName
+	}
+
+	if o.ScopeName == "" {
+		o.ScopeName = defaultScopeName
+	}
+	if o.ScopeVersion == "" {
+		o.ScopeVersion = internal.Version()
+	}
+
+	if o.SchemaURL != "" {
+		if u, err := url.Parse(o.SchemaURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid schema_url %q", o.SchemaURL)
+		}
+	}
+
+	if o.MaxPayloadSize < 0 {
+		return fmt.Errorf("max_payload_size must not be negative")
+	}
+
+	if err := o.checkOAuth2(); err != nil {
+		return err
+	}
+
+	if o.QueueSize < 0 {
+		return fmt.Errorf("queue_size must not be negative")
+	}
+	if o.NumConsumers < 0 {
+		return fmt.Errorf("num_consumers must not be negative")
+	}
+	if o.NumConsumers == 0 {
+		o.NumConsumers = defaultNumConsumers
+	}
+	switch o.OnFull {
+	case "":
+		o.OnFull = onFullBlock
+	case onFullBlock, onFullDropOldest:
+	default:
+		return fmt.Errorf("unsupported on_full %q", o.OnFull)
+	}
+	if o.DiskQueueMaxSize < 0 {
+		return fmt.Errorf("disk_queue_max_size must not be negative")
+	}
+	if o.DiskQueueMaxSize == 0 {
+		o.DiskQueueMaxSize = defaultDiskQueueMaxSize
+	}
+
+	if o.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must not be negative")
+	}
+	if o.RetryInitialInterval <= 0 {
+		o.RetryInitialInterval = defaultRetryInitialInterval
+	}
+	if o.RetryMaxInterval <= 0 {
+		o.RetryMaxInterval = defaultRetryMaxInterval
+	}
+	if o.RetryInitialInterval > o.RetryMaxInterval {
+		return fmt.Errorf("retry_initial_interval must not exceed retry_max_interval")
+	}
+	if o.RetryJitter < 0 || o.RetryJitter > 1 {
+		return fmt.Errorf("retry_jitter must be between 0 and 1")
+	}
+
+	if o.CircuitBreakerThreshold < 0 {
+		return fmt.Errorf("circuit_breaker_threshold must not be negative")
+	}
+	if o.CircuitBreakerCooldown <= 0 {
+		o.CircuitBreakerCooldown = defaultCircuitBreakerCooldown
+	}
+	if o.CircuitBreakerThreshold > 0 {
+		o.breaker = newCircuitBreaker(o.CircuitBreakerThreshold, time.Duration(o.CircuitBreakerCooldown), o.Log)
+	}
+
+	if o.ShutdownTimeout <= 0 {
+		o.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if o.HeartbeatInterval < 0 {
+		return fmt.Errorf("heartbeat_interval must not be negative")
+	}
+	if o.HeartbeatName == "" {
+		o.HeartbeatName = defaultHeartbeatName
+	}
+	if o.LogPayloadsMaxSize <= 0 {
+		o.LogPayloadsMaxSize = defaultLogPayloadsMaxSize
+	}
+
+	if err := o.checkLatencyBuckets(); err != nil {
+		return err
+	}
+
+	o.endpointMu = &sync.Mutex{}
+	o.drainer = newDrainer()
+	o.registerStats()
+	return nil
+}
+
+func (o *OpenTelemetry) Connect() error {
+	for _, output := range o.signalOutputs() {
+		if err := output.Connect(); err != nil {
+			return err
+		}
+	}
+
+	logger := &otelLogger{o.Log}
 
 	metricsConverter, err := influx2otel.NewLineProtocolToOtelMetrics(logger)
 	if err != nil {
 		return err
 	}
 
-	var grpcTLSDialOption grpc.DialOption
-	if tlsConfig, err := o.ClientConfig.TLSConfig(); err != nil {
+	o.metricsConverter = metricsConverter
+	if o.usesTemporality(temporalityDelta) {
+		o.deltaConverter = newDeltaConverter()
+	}
+	if o.MaxSeries > 0 {
+		o.seriesLimiter = newSeriesLimiter(o.MaxSeries, time.Duration(o.MaxSeriesWindow), o.cardinalityFilter)
+	}
+	if o.usesTemporality(temporalityCumulative) {
+		o.startTimes = newStartTimeTracker()
+	}
+	if o.ResourceAttributesFile != "" {
+		o.attributesFile, err = newValuesFile("resource_attributes_file", o.ResourceAttributesFile, time.Duration(o.ResourceAttributesReloadInterval), o.Log)
+		if err != nil {
+			return err
+		}
+	}
+	if o.HeadersFile != "" {
+		o.headersFile, err = newValuesFile("headers_file", o.HeadersFile, time.Duration(o.HeadersReloadInterval), o.Log)
+		if err != nil {
+			return err
+		}
+	}
+	if o.DryRun {
+		o.Log.Info("Dry run, converted requests are logged instead of exported")
+		return nil
+	}
+	o.connectOAuth2()
+
+	if o.Protocol == protocolHTTPProtobuf {
+		err = o.connectHTTP()
+	} else {
+		err = o.connectGRPC()
+	}
+	if err != nil {
 		return err
-	} else if tlsConfig != nil {
+	}
+
+	if o.DiskQueuePath != "" {
+		if o.diskQueue, err = openDiskQueue(o.DiskQueuePath, int64(o.DiskQueueMaxSize)); err != nil {
+			return err
+		}
+		// Replay the requests persisted before a restart in the background,
+		// the collector might not be reachable yet.
+		if o.diskQueue.len() > 0 {
+			go o.replayDiskQueue()
+		}
+	}
+	if o.QueueSize > 0 {
+		o.startQueue()
+	}
+	if o.PreferredBatchSize > 0 {
+		o.batcher = newBatcher(o.PreferredBatchSize, time.Duration(o.MaxBatchHold), o.exportBatch, o.Log)
+	}
+	if o.HealthCheckInterval > 0 && o.Protocol == protocolGRPC {
+		o.startHealthCheck()
+	}
+	if o.HeartbeatInterval > 0 {
+		o.startHeartbeat()
+	}
+	return nil
+}
+
+// checkGRPCAddress accepts either a host:port pair or a gRPC target URI such
+// as "dns:///collector:4317".
+func checkGRPCAddress(address string) error {
+	if strings.Contains(address, "://") {
+		if _, err := url.Parse(address); err != nil {
+			return fmt.Errorf("invalid service_address %q: %w", address, err)
+		}
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return fmt.Errorf("invalid service_address %q: %w", address, err)
+	}
+	return nil
+}
+
+// authorityHost returns the host of the authority without the port.
+func authorityHost(authority string) string {
+	if host, _, err := net.SplitHostPort(authority); err == nil {
+		return host
+	}
+	return authority
+}
+
+func checkHTTPAddress(address string) error {
+	if strings.HasPrefix(address, unixScheme) {
+		if strings.TrimPrefix(address, unixScheme) == "" {
+			return fmt.Errorf("invalid service_address %q: missing socket path", address)
+		}
+		return nil
+	}
+	if !strings.HasPrefix(address, httpScheme) && !strings.HasPrefix(address, httpsScheme) {
+		address = httpScheme + address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return fmt.Errorf("invalid service_address %q: %w", address, err)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid service_address %q: missing host", address)
+	}
+	return nil
+}
+
+func (o *OpenTelemetry) connectGRPC() error {
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return err
+	}
+	grpcTLSDialOption := grpc.WithTransportCredentials(insecure.NewCredentials())
+	if tlsConfig != nil {
 		grpcTLSDialOption = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
-	} else {
-		grpcTLSDialOption = grpc.WithTransportCredentials(insecure.NewCredentials())
 	}
 
-	grpcClientConn, err := grpc.Dial(o.ServiceAddress, grpcTLSDialOption)
+	dialOptions := []grpc.DialOption{grpcTLSDialOption, grpc.WithUserAgent(o.UserAgent)}
+	if o.KeepaliveTime > 0 {
+		dialOptions = append(dialOptions, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                time.Duration(o.KeepaliveTime),
+			Timeout:             time.Duration(o.KeepaliveTimeout),
+			PermitWithoutStream: o.KeepalivePermitWithoutStream,
+		}))
+	}
+
+	// Fixed windows disable the window estimation of gRPC.
+	if o.GRPCInitialWindowSize > 0 {
+		dialOptions = append(dialOptions, grpc.WithInitialWindowSize(int32(o.GRPCInitialWindowSize)))
+	}
+	if o.GRPCInitialConnWindowSize > 0 {
+		dialOptions = append(dialOptions, grpc.WithInitialConnWindowSize(int32(o.GRPCInitialConnWindowSize)))
+	}
+
+	serviceConfig, err := o.serviceConfig()
 	if err != nil {
 		return err
 	}
+	if serviceConfig != "" {
+		dialOptions = append(dialOptions, grpc.WithDefaultServiceConfig(serviceConfig))
+	}
+	if o.Authority != "" {
+		dialOptions = append(dialOptions, grpc.WithAuthority(o.Authority))
+	}
+	if o.DNSRefreshInterval > 0 {
+		dialOptions = append(dialOptions, grpc.WithResolvers(newRefreshingDNSBuilder(time.Duration(o.DNSRefreshInterval))))
+	}
 
-	metricsServiceClient := pmetricotlp.NewClient(grpcClientConn)
+	if o.WaitForReady {
+		dialOptions = append(dialOptions, grpc.WithBlock(), grpc.WithReturnConnectionError())
+	}
 
-	o.metricsConverter = metricsConverter
-	o.grpcClientConn = grpcClientConn
-	o.metricsServiceClient = metricsServiceClient
+	if o.proxyDialer != nil {
+		dialOptions = append(dialOptions, grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+			return o.proxyDialer.DialContext(ctx, "tcp", address)
+		}))
+	}
+
+	// The level of registered compressors is global, so a compressor of the
+	// connection is used instead.
+	if o.CompressionLevel != 0 {
+		compressor, err := grpc.NewGZIPCompressorWithLevel(o.CompressionLevel) //nolint:staticcheck // Only way to set the level per connection
+		if err != nil {
+			return err
+		}
+		dialOptions = append(dialOptions, grpc.WithCompressor(compressor)) //nolint:staticcheck // Only way to set the level per connection
+	}
+	o.dialOptions = dialOptions
 
-	if o.Compression != "" && o.Compression != "none" {
+	o.endpoints = o.endpoints[:0]
+	for _, address := range o.addresses() {
+		// Balancing and refreshing need the resolver to return all
+		// addresses of the name, the default passthrough resolver only
+		// returns the name.
+		resolve := o.BalancerPolicy == balancerRoundRobin || o.DNSRefreshInterval > 0
+		e := &endpoint{address: address}
+		if resolve && !strings.Contains(address, "://") {
+			e.address = "dns:///" + address
+		}
+		endpointTLS, err := o.endpointTLSConfig(address, tlsConfig)
+		if err != nil {
+			return err
+		}
+		if endpointTLS != tlsConfig {
+			// The later transport credentials replace the ones of the plugin.
+			e.dialOptions = append(append([]grpc.DialOption(nil), dialOptions...), grpc.WithTransportCredentials(credentials.NewTLS(endpointTLS)))
+		}
+		o.endpoints = append(o.endpoints, e)
+	}
+	if err := o.useEndpoint(0); err != nil {
+		return err
+	}
+
+	o.callOptions = nil
+	if o.Compression != "none" && o.CompressionLevel == 0 {
 		o.callOptions = append(o.callOptions, grpc.UseCompressor(o.Compression))
 	}
+	if o.MaxMsgSize > 0 {
+		o.callOptions = append(o.callOptions,
+			grpc.MaxCallSendMsgSize(int(o.MaxMsgSize)),
+			grpc.MaxCallRecvMsgSize(int(o.MaxMsgSize)),
+		)
+	}
 
 	return nil
 }
 
 func (o *OpenTelemetry) Close() error {
-	if o.grpcClientConn != nil {
-		err := o.grpcClientConn.Close()
-		o.grpcClientConn = nil
-		return err
+	o.stopHealthCheck()
+	o.stopHeartbeat()
+	if o.batcher != nil {
+		o.batcher.flushAll()
+		o.batcher = nil
 	}
-	return nil
+	o.drain()
+	o.diskQueue = nil
+
+	var err error
+	for _, output := range o.signalOutputs() {
+		if closeErr := output.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	if o.httpClient != nil {
+		for _, e := range o.endpoints {
+			e.httpClient.CloseIdleConnections()
+		}
+		o.httpClient.CloseIdleConnections()
+		o.httpClient = nil
+	}
+	for _, e := range o.endpoints {
+		if e.conn != nil {
+			if closeErr := e.conn.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+			<-e.watched
+			e.conn = nil
+		}
+	}
+	if o.grpcClientConn != nil && len(o.endpoints) == 0 {
+		if closeErr := o.grpcClientConn.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	o.grpcClientConn = nil
+	return err
 }
 
+// Write converts and exports the metrics. Telegraf never calls Write
+// concurrently, which the state kept across writes, such as that of the
+// delta converter and the start time tracker, relies on. The conversion
+// itself is safe to run concurrently, and so are the exports.
 func (o *OpenTelemetry) Write(metrics []telegraf.Metric) error {
+	if len(o.MetadataTags) == 0 && len(o.BaggageTags) == 0 {
+		return o.write(metrics, nil)
+	}
+	for _, group := range o.groupByMetadata(metrics) {
+		if err := o.write(group.metrics, group.headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write converts and exports the metrics, sending the headers with every
+// request in addition to the static headers.
+func (o *OpenTelemetry) write(metrics []telegraf.Metric, headers map[string]string) error {
 	batch := o.metricsConverter.NewBatch()
+	var summaries prometheusSummaries
+	nonMonotonic := make(map[string]bool)
+	units := make(map[string]string)
+	descriptions := make(map[string]string)
+	temporalities := make(map[string]string)
+	excluded := make(map[telegraf.ValueType]int)
+	selections := make(map[fieldSelection]int)
+	var filtered, stale, future int
+	now := time.Now()
+	oldest := now.Add(-time.Duration(o.MaxMetricAge))
+	var traces *tracesBatch
+	var logs *logsBatch
 	for _, metric := range metrics {
+		if o.MaxMetricAge > 0 && metric.Time().Before(oldest) {
+			stale++
+			continue
+		}
+		metric, ok := o.handleFutureTimestamp(metric, now)
+		if !ok {
+			future++
+			continue
+		}
+		if isSpan(metric) {
+			if traces == nil {
+				traces = newTracesBatch(o.ResourceTags, &otelLogger{o.Log})
+			}
+			if err := traces.AddSpan(metric.Tags(), metric.Fields(), metric.Time()); err != nil {
+				o.Log.Warnf("failed to add span: %s", err)
+			}
+			continue
+		}
+		if o.isLog(metric) {
+			if logs == nil {
+				logs = newLogsBatch(o.ResourceTags, &otelLogger{o.Log})
+			}
+			if err := logs.AddLogRecord(metric.Tags(), metric.Fields(), metric.Time()); err != nil {
+				o.Log.Warnf("failed to add log record: %s", err)
+			}
+			continue
+		}
+
+		if o.metricFilter != nil && !o.metricFilter.Match(metric.Name()) {
+			filtered++
+			continue
+		}
+		if o.excludedTypes[metric.Type()] {
+			excluded[metric.Type()]++
+			continue
+		}
+
 		var vType common.InfluxMetricValueType
 		switch metric.Type() {
 		case telegraf.Gauge:
 			vType = common.InfluxMetricValueTypeGauge
 		case telegraf.Untyped:
-			vType = common.InfluxMetricValueTypeUntyped
+			switch o.UntypedAs {
+			case untypedAsGauge:
+				vType = common.InfluxMetricValueTypeGauge
+			case untypedAsSum:
+				vType = common.InfluxMetricValueTypeSum
+			default:
+				vType = common.InfluxMetricValueTypeUntyped
+			}
 		case telegraf.Counter:
 			vType = common.InfluxMetricValueTypeSum
 		case telegraf.Histogram:
@@ -116,51 +1031,663 @@ func (o *OpenTelemetry) Write(metrics []telegraf.Metric) error {
 		case telegraf.Summary:
 			vType = common.InfluxMetricValueTypeSummary
 		default:
+			if o.StrictTypes {
+				return fmt.Errorf("unrecognized type %d of metric %q", metric.Type(), metric.Name())
+			}
 			o.Log.Warnf("unrecognized metric type %Q", metric.Type())
 			continue
 		}
-		err := batch.AddPoint(metric.Name(), metric.Tags(), metric.Fields(), metric.Time(), vType)
-		if err != nil {
-			o.Log.Warnf("failed to add point: %s", err)
+		tags, unitTag := splitTag(metric.Tags(), o.UnitTag)
+		tags, descriptionTag := splitTag(tags, o.DescriptionTag)
+		o.addRuleAttributes(metric.Name(), tags)
+		fields := metric.Fields()
+		exemplar := o.extractExemplar(tags, fields, metric.Time())
+		selected, selection := o.selectFields(fields, tags)
+		selections[selection]++
+		switch selection {
+		case selectedNone:
 			continue
+		case selectedBooleans, selectedPresence:
+			vType = common.InfluxMetricValueTypeGauge
+		}
+		staleMarker := o.isStaleMetric(tags)
+		groups := o.groupFieldsByType(metric.Name(), selected, vType)
+		if selection == selectedWithBooleans {
+			groups = splitBooleans(groups, fields)
+		}
+		for _, group := range o.splitStale(groups, staleMarker) {
+			fields := group.fields
+			if o.NonFiniteHandling != nonFinitePass && o.NonFiniteHandling != nonFiniteStale {
+				fields = o.handleNonFinite(metric.Name(), fields)
+				if len(fields) == 0 {
+					continue
+				}
+			}
+			if group.vType == common.InfluxMetricValueTypeSummary {
+				if metric.Name() == common.MeasurementPrometheus && summaries.add(tags, fields, metric.Time()) {
+					continue
+				}
+				fields = o.summaryFields(fields)
+			}
+			name := metric.Name()
+			if o.Namespace != "" {
+				name = o.Namespace + o.NamespaceSeparator + name
+			}
+			pointTags := tags
+			if group.stale {
+				pointTags = withStaleMarker(tags)
+			}
+			if exemplar != "" {
+				pointTags = withExemplarMarker(pointTags, exemplar)
+			}
+			err := batch.AddPoint(name, pointTags, fields, metric.Time(), group.vType)
+			if err != nil {
+				o.Log.Warnf("failed to add point: %s", err)
+				continue
+			}
+			names := convertedNames(name, fields, group.vType)
+			if t := o.temporalityOf(metric.Name()); t != "" {
+				for _, n := range names {
+					if o.SanitizeNames {
+						n = sanitizeName(n)
+					}
+					temporalities[n] = t
+				}
+			}
+			for field, n := range names {
+				if group.nonMonotonic || (metric.Type() == telegraf.Counter && o.isNonMonotonicCounter(metric.Name(), field)) {
+					nonMonotonic[n] = true
+				}
+				if unit := o.unitOf(metric.Name(), field, unitTag); unit != "" {
+					units[n] = unit
+				}
+				if description := o.descriptionOf(metric.Name(), field, descriptionTag); description != "" {
+					descriptions[n] = description
+				}
+			}
+		}
+	}
+	summaries.each(func(summary *prometheusSummary) {
+		name := summary.name
+		if o.Namespace != "" {
+			name = o.Namespace + o.NamespaceSeparator + name
+		}
+		err := batch.AddPoint(name, summary.tags, summary.fields, summary.ts, common.InfluxMetricValueTypeSummary)
+		if err != nil {
+			o.Log.Warnf("failed to add summary %q: %s", summary.name, err)
+		}
+	})
+
+	if stale > 0 {
+		o.Log.Debugf("Dropped %d metrics older than the max_metric_age of %s", stale, o.MaxMetricAge)
+	}
+	if future > 0 {
+		o.Log.Debugf("Dropped %d metrics more than the max_future_drift of %s ahead", future, o.MaxFutureDrift)
+	}
+	if filtered > 0 {
+		o.Log.Debugf("Dropped %d metrics not passing metric_include and metric_exclude", filtered)
+	}
+	for name, t := range valueTypes {
+		if excluded[t] > 0 {
+			o.Log.Debugf("Dropped %d metrics of the excluded type %q", excluded[t], name)
+		}
+	}
+	if n := selections[selectedNone]; n > 0 {
+		o.Log.Debugf("Dropped %d metrics without numeric fields", n)
+	}
+	if n := selections[selectedPresence]; n > 0 {
+		o.Log.Debugf("Replaced %d metrics without numeric fields by info gauges", n)
+	}
+	if n := selections[selectedWithBooleans] + selections[selectedBooleans]; n > 0 {
+		o.Log.Debugf("Converted the boolean fields of %d metrics to gauges", n)
+	}
+
+	otelMetrics := batch.GetMetrics()
+	if len(nonMonotonic) > 0 {
+		setNonMonotonic(otelMetrics, nonMonotonic)
+	}
+	if len(units) > 0 {
+		setUnits(otelMetrics, units)
+	}
+	if len(descriptions) > 0 {
+		setDescriptions(otelMetrics, descriptions)
+	}
+	count := otelMetrics.DataPointCount()
+	if err := o.signalOutput(o.metricsOutput).writeMetrics(otelMetrics, temporalities, headers); err != nil {
+		return err
+	}
+	if o.heartbeat != nil && count > 0 {
+		o.heartbeat.sent()
+	}
+	if traces != nil {
+		if err := o.signalOutput(o.tracesOutput).writeTraces(traces.GetTraces(), headers); err != nil {
+			return err
 		}
 	}
+	if logs != nil {
+		return o.signalOutput(o.logsOutput).writeLogs(logs.GetLogs(), headers)
+	}
+	return nil
+}
+
+// isLog reports whether the metric is a log record, that is it belongs to
+// one of the configured log measurements and carries a message.
+func (o *OpenTelemetry) isLog(metric telegraf.Metric) bool {
+	if !choice.Contains(metric.Name(), o.LogMeasurements) {
+		return false
+	}
+	return metric.HasField(common.AttributeBody) || metric.HasField(logMessageField)
+}
 
-	md := pmetricotlp.NewRequestFromMetrics(batch.GetMetrics())
-	if md.Metrics().ResourceMetrics().Len() == 0 {
+// writeMetrics exports the converted metrics. The temporalities override the
+// aggregation_temporality by the exported name of the metric.
+func (o *OpenTelemetry) writeMetrics(metrics pmetric.Metrics, temporalities map[string]string, headers map[string]string) error {
+	promoted := o.ResourceTags
+	if len(o.ruleTags) > 0 {
+		promoted = append(append([]string(nil), o.ResourceTags...), o.ruleTags...)
+	}
+	markStale(metrics)
+	if o.EnableExemplars {
+		attachExemplars(metrics)
+	}
+	metrics = promoteResourceTags(metrics, promoted)
+	o.protectDataPoints(metrics)
+	o.limitAttributes(metrics)
+	if o.seriesLimiter != nil {
+		o.limitSeries(metrics)
+	}
+	o.setScopes(metrics)
+	if o.SanitizeNames {
+		o.sanitizeMetricNames(metrics)
+	}
+	if o.deltaConverter != nil {
+		o.deltaConverter.convert(metrics, time.Now(), func(name string) bool {
+			t, ok := temporalities[name]
+			return t == temporalityDelta || !ok && o.AggregationTemporality == temporalityDelta
+		})
+	}
+	if o.startTimes != nil {
+		o.startTimes.track(metrics, time.Now())
+	}
+	if o.HistogramType == histogramTypeExponential {
+		convertToExponentialHistograms(metrics)
+	}
+	// Renamed last, so temporality and start times were looked up by the
+	// names the metrics were converted with.
+	if o.PrometheusCompatibleNames {
+		o.prometheusMetricNames(metrics)
+	}
+	if metrics.ResourceMetrics().Len() == 0 {
 		return nil
 	}
 
-	if len(o.Attributes) > 0 {
-		for i := 0; i < md.Metrics().ResourceMetrics().Len(); i++ {
-			for k, v := range o.Attributes {
-				md.Metrics().ResourceMetrics().At(i).Resource().Attributes().UpsertString(k, v)
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		o.setResourceAttributes(rm.Resource())
+		if o.SchemaURL != "" {
+			setSchemaURL(rm, o.SchemaURL)
+		}
+	}
+
+	if o.batcher != nil {
+		return o.batcher.add(metrics, headers)
+	}
+	return o.exportBatch(metrics, headers)
+}
+
+// exportBatch sorts and splits the converted metrics and exports the
+// requests.
+func (o *OpenTelemetry) exportBatch(metrics pmetric.Metrics, headers map[string]string) error {
+	if o.SortOutput {
+		sortMetrics(metrics)
+	}
+
+	chunks := []pmetric.Metrics{metrics}
+	if o.MaxDatapointsPerRequest > 0 {
+		chunks = splitMetricsByCount(metrics, o.MaxDatapointsPerRequest)
+	}
+	if o.MaxPayloadSize > 0 {
+		var sized []pmetric.Metrics
+		var dropped int
+		for _, chunk := range chunks {
+			pieces, n := splitMetrics(chunk, int(o.MaxPayloadSize))
+			sized = append(sized, pieces...)
+			dropped += n
+		}
+		if dropped > 0 {
+			o.Log.Errorf("Dropped %d data points exceeding the max_payload_size of %d bytes", dropped, o.MaxPayloadSize)
+		}
+		chunks = sized
+	}
+	return o.exportChunks(chunks, headers)
+}
+
+// exportChunks exports the requests of a split batch, up to
+// max_concurrent_exports at a time. Concurrent exports all run to completion,
+// and the number of failed requests is reported with the first error.
+func (o *OpenTelemetry) exportChunks(chunks []pmetric.Metrics, headers map[string]string) error {
+	if o.MaxConcurrentExports <= 1 || len(chunks) < 2 {
+		for _, chunk := range chunks {
+			if err := o.exportMetrics(chunk, headers); err != nil {
+				return err
 			}
 		}
+		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(o.Timeout))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed int
+	var first error
+	workers := make(chan struct{}, o.MaxConcurrentExports)
+	for _, chunk := range chunks {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(chunk pmetric.Metrics) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			if err := o.exportMetrics(chunk, headers); err != nil {
+				mu.Lock()
+				failed++
+				if first == nil {
+					first = err
+				}
+				mu.Unlock()
+			}
+		}(chunk)
+	}
+	wg.Wait()
+
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("exporting %d of %d requests failed: %w", failed, len(chunks), first)
+}
+
+func (o *OpenTelemetry) exportMetrics(metrics pmetric.Metrics, headers map[string]string) error {
+	call := newMetricsCall(pmetricotlp.NewRequestFromMetrics(metrics))
+	call.headers = headers
+	return o.send(call)
+}
+
+func (o *OpenTelemetry) writeTraces(traces ptrace.Traces, headers map[string]string) error {
+	td := ptraceotlp.NewRequestFromTraces(traces)
+	if td.Traces().ResourceSpans().Len() == 0 {
+		return nil
+	}
+
+	for i := 0; i < td.Traces().ResourceSpans().Len(); i++ {
+		o.setResourceAttributes(td.Traces().ResourceSpans().At(i).Resource())
+	}
+
+	call := newTracesCall(td)
+	call.headers = headers
+	return o.send(call)
+}
+
+func (o *OpenTelemetry) writeLogs(logs plog.Logs, headers map[string]string) error {
+	ld := plogotlp.NewRequestFromLogs(logs)
+	if ld.Logs().ResourceLogs().Len() == 0 {
+		return nil
+	}
+
+	for i := 0; i < ld.Logs().ResourceLogs().Len(); i++ {
+		o.setResourceAttributes(ld.Logs().ResourceLogs().At(i).Resource())
+	}
+
+	call := newLogsCall(ld)
+	call.headers = headers
+	return o.send(call)
+}
+
+// exportCall describes a single OTLP export request for either transport.
+type exportCall struct {
+	signal  string
+	items   string // what the request carries, for example "spans"
+	count   int    // number of items in the request
+	path    string
+	request requestMarshaler
+	size    func() int // serialized size of the request in bytes
+	grpc    func(ctx context.Context, clients serviceClients, opts ...grpc.CallOption) error
+	headers map[string]string // sent in addition to the static headers
+}
+
+func newMetricsCall(md pmetricotlp.Request) exportCall {
+	return exportCall{
+		signal:  signalMetrics,
+		items:   "data points",
+		count:   md.Metrics().DataPointCount(),
+		path:    metricsURLPath,
+		request: md,
+		size:    func() int { return metricsSizer.MetricsSize(md.Metrics()) },
+		grpc: func(ctx context.Context, clients serviceClients, opts ...grpc.CallOption) error {
+			_, err := clients.metrics.Export(ctx, md, opts...)
+			return err
+		},
+	}
+}
+
+func newTracesCall(td ptraceotlp.Request) exportCall {
+	return exportCall{
+		signal:  signalTraces,
+		items:   "spans",
+		count:   td.Traces().SpanCount(),
+		path:    tracesURLPath,
+		request: td,
+		size:    func() int { return tracesSizer.TracesSize(td.Traces()) },
+		grpc: func(ctx context.Context, clients serviceClients, opts ...grpc.CallOption) error {
+			_, err := clients.traces.Export(ctx, td, opts...)
+			return err
+		},
+	}
+}
+
+func newLogsCall(ld plogotlp.Request) exportCall {
+	return exportCall{
+		signal:  signalLogs,
+		items:   "log records",
+		count:   ld.Logs().LogRecordCount(),
+		path:    logsURLPath,
+		request: ld,
+		size:    func() int { return logsSizer.LogsSize(ld.Logs()) },
+		grpc: func(ctx context.Context, clients serviceClients, opts ...grpc.CallOption) error {
+			_, err := clients.logs.Export(ctx, ld, opts...)
+			return err
+		},
+	}
+}
+
+// serviceClients are the gRPC clients of the current endpoint.
+type serviceClients struct {
+	metrics pmetricotlp.Client
+	traces  ptraceotlp.Client
+	logs    plogotlp.Client
+}
+
+type requestMarshaler interface {
+	MarshalProto() ([]byte, error)
+	MarshalJSON() ([]byte, error)
+}
+
+// send exports the request or, with a send queue, adds it to the queue.
+func (o *OpenTelemetry) send(call exportCall) error {
+	if o.DryRun {
+		o.logDryRun(call)
+		return nil
+	}
+	if o.queue != nil {
+		o.enqueue(call)
+		return nil
+	}
+	return o.deliver(call)
+}
+
+// deliver exports the request. With a disk queue, requests failing with a
+// transient error are persisted instead, and the persisted requests are
+// replayed once an export succeeds.
+func (o *OpenTelemetry) deliver(call exportCall) error {
+	err := o.export(call)
+	if o.diskQueue == nil {
+		return err
+	}
+	if err != nil {
+		if o.persist(call, err) {
+			return nil
+		}
+		return err
+	}
+	if o.diskQueue.len() > 0 {
+		o.replayDiskQueue()
+	}
+	return nil
+}
+
+// export sends the request to the current endpoint, failing over to the
+// next endpoints if it is unavailable.
+func (o *OpenTelemetry) export(call exportCall) error {
+	o.drainer.inflight.Add(1)
+	defer o.drainer.inflight.Done()
 
-	if len(o.Headers) > 0 {
-		ctx = metadata.NewOutgoingContext(ctx, metadata.New(o.Headers))
+	start := time.Now()
+	if o.breaker != nil && !o.breaker.allow(start) {
+		return errCircuitOpen
+	}
+	if o.LogPayloads {
+		o.logPayload(call)
+	}
+	current, err := o.exportToCurrent(call)
+	for i := 1; i < len(o.endpoints) && err != nil; i++ {
+		if !o.failover(current, err) {
+			break
+		}
+		current, err = o.exportToCurrent(call)
+	}
+	if o.breaker != nil {
+		o.breaker.record(err, time.Now())
+	}
+	o.stats.exportDuration.Incr(time.Since(start).Nanoseconds())
+	if err != nil {
+		o.stats.exportErrors.Incr(1)
+		return err
 	}
+	o.stats.batchesSent.Incr(1)
+	o.stats.metricsSent.Incr(int64(call.count))
+	return nil
+}
+
+// exportToCurrent sends the request to the current endpoint and returns its
+// index.
+func (o *OpenTelemetry) exportToCurrent(call exportCall) (int, error) {
+	o.endpointMu.Lock()
+	current := o.currentEndpoint
+	httpClient, baseURL := o.httpClient, o.baseURL
+	clients := serviceClients{
+		metrics: o.metricsServiceClient,
+		traces:  o.tracesServiceClient,
+		logs:    o.logsServiceClient,
+	}
+	o.endpointMu.Unlock()
+
+	ctx, cancel := o.exportContext(call.count, call.headers)
 	defer cancel()
-	_, err := o.metricsServiceClient.Export(ctx, md, o.callOptions...)
-	return err
+
+	return current, o.withRetry(ctx, func(ctx context.Context) error {
+		ctx, err := o.authorize(ctx)
+		if err != nil {
+			return err
+		}
+
+		start := time.Now()
+		defer func() { o.stats.exportLatency.observe(time.Since(start)) }()
+
+		var ps partialSuccess
+		if httpClient != nil {
+			ps, err = o.postHTTP(ctx, httpClient, baseURL+call.path, call.request)
+		} else {
+			codec := newPartialSuccessCodec()
+			opts := make([]grpc.CallOption, 0, len(o.callOptions)+2)
+			opts = append(opts, o.callOptions...)
+			opts = append(opts, grpc.ForceCodec(codec))
+			if atomic.LoadInt32(&o.uncompressed) == 1 ||
+				o.CompressionMinSize > 0 && call.size() < int(o.CompressionMinSize) {
+				opts = append(opts, uncompressedCallOption)
+			}
+			err = call.grpc(ctx, clients, opts...)
+			if err != nil && o.fallBackToUncompressed(err) {
+				err = call.grpc(ctx, clients, append(opts, uncompressedCallOption)...)
+			}
+			ps = codec.partialSuccess
+		}
+		o.stats.exportResults.observe(err)
+		if err != nil {
+			return err
+		}
+		o.logPartialSuccess(ps, call.items)
+		return nil
+	})
+}
+
+// setScopes sets the configured instrumentation scope on all scope metrics
+// without a name. Scopes taken from the otel.library.name tag are kept.
+func (o *OpenTelemetry) setScopes(metrics pmetric.Metrics) {
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			scope := rm.ScopeMetrics().At(j).Scope()
+			if scope.Name() == "" {
+				scope.SetName(o.ScopeName)
+				scope.SetVersion(o.ScopeVersion)
+			}
+		}
+	}
+}
+
+// setSchemaURL sets the schema URL on the resource metrics and all their
+// scope metrics.
+func setSchemaURL(rm pmetric.ResourceMetrics, schemaURL string) {
+	rm.SetSchemaUrl(schemaURL)
+	for i := 0; i < rm.ScopeMetrics().Len(); i++ {
+		rm.ScopeMetrics().At(i).SetSchemaUrl(schemaURL)
+	}
+}
+
+// setResourceAttributes applies the configured attributes to the resource.
+func (o *OpenTelemetry) setResourceAttributes(resource pcommon.Resource) {
+	if o.attributesFile != nil {
+		for k, v := range o.attributesFile.get() {
+			if _, ok := o.Attributes[k]; !ok {
+				upsertTypedAttribute(resource.Attributes(), k, v)
+			}
+		}
+	}
+	ruleKeys := applyRuleAttributes(resource)
+	for k, v := range o.Attributes {
+		if !ruleKeys[k] {
+			upsertTypedAttribute(resource.Attributes(), k, v)
+		}
+	}
+	o.setDetectedAttributes(resource)
+	o.setAgentAttributes(resource)
+	if o.ServiceName != "" {
+		resource.Attributes().InsertString(serviceNameAttribute, o.ServiceName)
+	}
+	o.protectAttributes(resource.Attributes())
+}
+
+// upsertTypedAttribute adds a configured attribute as bool, int or double if
+// the value is the canonical representation of one, for example "true", "42"
+// or "0.5", and as string otherwise. Values such as "042" or "1.10" stay
+// strings as they would not convert back to the same text.
+func upsertTypedAttribute(attributes pcommon.Map, key, value string) {
+	switch value {
+	case "true":
+		attributes.UpsertBool(key, true)
+		return
+	case "false":
+		attributes.UpsertBool(key, false)
+		return
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil && strconv.FormatInt(n, 10) == value {
+		attributes.UpsertInt(key, n)
+		return
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == value {
+		attributes.UpsertDouble(key, f)
+		return
+	}
+	attributes.UpsertString(key, value)
+}
+
+// exportContext returns the context for a single export request of count
+// items, bounded by the export timeout and carrying the configured headers
+// and the headers of the request.
+func (o *OpenTelemetry) exportContext(count int, headers map[string]string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(o.drainer.ctx, o.exportTimeout(count))
+	if merged := mergeHeaders(o.staticHeaders(), headers); len(merged) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(merged))
+	}
+	return ctx, cancel
+}
+
+// staticHeaders returns the configured headers overridden by the current
+// headers of the headers_file.
+func (o *OpenTelemetry) staticHeaders() map[string]string {
+	if o.headersFile == nil {
+		return o.Headers
+	}
+	return mergeHeaders(o.Headers, o.headersFile.get())
+}
+
+// exportTimeout scales the timeout with the number of items when
+// timeout_per_1k_points is set. The timeout is the lower bound, max_timeout
+// the upper bound.
+func (o *OpenTelemetry) exportTimeout(count int) time.Duration {
+	timeout := time.Duration(o.Timeout)
+	if o.TimeoutPer1kPoints <= 0 {
+		return timeout
+	}
+	if scaled := time.Duration(o.TimeoutPer1kPoints) * time.Duration(count) / 1000; scaled > timeout {
+		timeout = scaled
+	}
+	if o.MaxTimeout > 0 && timeout > time.Duration(o.MaxTimeout) {
+		timeout = time.Duration(o.MaxTimeout)
+	}
+	return timeout
 }
 
 const (
-	defaultServiceAddress = "localhost:4317"
-	defaultTimeout        = config.Duration(5 * time.Second)
-	defaultCompression    = "gzip"
+	protocolGRPC         = "grpc"
+	protocolHTTPProtobuf = "http/protobuf"
+)
+
+const (
+	encodingProtobuf = "protobuf"
+	encodingJSON     = "json"
+)
+
+const (
+	untypedAsUntyped = "untyped"
+	untypedAsGauge   = "gauge"
+	untypedAsSum     = "sum"
+)
+
+const (
+	balancerPickFirst  = "pick_first"
+	balancerRoundRobin = "round_robin"
+)
+
+const (
+	defaultServiceAddress     = "localhost:4317"
+	defaultHTTPServiceAddress = "http://localhost:4318"
+	defaultProtocol           = protocolGRPC
+	defaultTimeout            = config.Duration(5 * time.Second)
+	defaultCompression        = "gzip"
+	defaultServiceName        = "telegraf"
+	defaultScopeName          = "telegraf"
+	defaultNamespaceSeparator = "."
+
+	// minKeepaliveTime is the lowest keepalive interval gRPC clients
+	// support; servers usually enforce a higher one, by default 5m.
+	minKeepaliveTime = config.Duration(10 * time.Second)
+
+	// minWindowSize is the smallest flow control window gRPC accepts, it
+	// ignores smaller windows.
+	minWindowSize = config.Size(64 * 1024)
+
+	defaultRetryInitialInterval = config.Duration(time.Second)
+	defaultRetryMaxInterval     = config.Duration(30 * time.Second)
 )
 
 func init() {
 	outputs.Add("opentelemetry", func() telegraf.Output {
 		return &OpenTelemetry{
-			ServiceAddress: defaultServiceAddress,
-			Timeout:        defaultTimeout,
-			Compression:    defaultCompression,
+			Protocol:        defaultProtocol,
+			Timeout:         defaultTimeout,
+			Compression:     defaultCompression,
+			LogMeasurements: []string{defaultLogMeasurement},
+
+			RetryInitialInterval: defaultRetryInitialInterval,
+			RetryMaxInterval:     defaultRetryMaxInterval,
 		}
 	})
 }
@@ -1,25 +1,60 @@
 package opentelemetry
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"google.golang.org/grpc/credentials/insecure"
+	"io"
+	"math"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/golang/snappy"
 	"github.com/influxdata/influxdb-observability/common"
 	"github.com/influxdata/influxdb-observability/influx2otel"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/oauth"
+	"github.com/influxdata/telegraf/plugins/common/proxy"
+	"github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/testutil"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
 )
 
 func TestOpenTelemetry(t *testing.T) {
@@ -50,8 +85,11 @@ func TestOpenTelemetry(t *testing.T) {
 		Attributes:           map[string]string{"attr-key": "attr-val"},
 		metricsConverter:     metricsConverter,
 		grpcClientConn:       m.GrpcClient(),
+		endpointMu:           &sync.Mutex{},
+		drainer:              newDrainer(),
 		metricsServiceClient: pmetricotlp.NewClient(m.GrpcClient()),
 	}
+	plugin.registerStats()
 
 	input := testutil.MustMetric(
 		"cpu_temp",
@@ -85,7 +123,4234 @@ func TestOpenTelemetry(t *testing.T) {
 	assert.JSONEq(t, string(expectJSON), string(gotJSON))
 }
 
+func TestOpenTelemetryHTTP(t *testing.T) {
+	expect := pmetric.NewMetrics()
+	{
+		rm := expect.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().InsertString("host.name", "potato")
+		rm.Resource().Attributes().InsertString("attr-key", "attr-val")
+		rm.Resource().Attributes().InsertString("service.name", "telegraf")
+		ilm := rm.ScopeMetrics().AppendEmpty()
+		ilm.Scope().SetName("My Library Name")
+		m := ilm.Metrics().AppendEmpty()
+		m.SetName("cpu_temp")
+		m.SetDataType(pmetric.MetricDataTypeGauge)
+		dp := m.Gauge().DataPoints().AppendEmpty()
+		dp.Attributes().InsertString("foo", "bar")
+		dp.SetTimestamp(pcommon.Timestamp(1622848686000000000))
+		dp.SetDoubleVal(87.332)
+	}
+
+	var got pmetric.Metrics
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/metrics", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "header1", r.Header.Get("test"))
+
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(gz)
+		require.NoError(t, err)
+
+		request := pmetricotlp.NewRequest()
+		require.NoError(t, request.UnmarshalProto(body))
+		got = request.Metrics().Clone()
+
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Timeout:        config.Duration(time.Second),
+		Compression:    "gzip",
+		Headers:        map[string]string{"test": "header1"},
+		Attributes:     map[string]string{"attr-key": "attr-val"},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	input := testutil.MustMetric(
+		"cpu_temp",
+		map[string]string{
+			"foo":               "bar",
+			"otel.library.name": "My Library Name",
+			"host.name":         "potato",
+		},
+		map[string]interface{}{
+			"gauge": 87.332,
+		},
+		time.Unix(0, 1622848686000000000))
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{input}))
+
+	expectJSON, err := pmetric.NewJSONMarshaler().MarshalMetrics(expect)
+	require.NoError(t, err)
+
+	gotJSON, err := pmetric.NewJSONMarshaler().MarshalMetrics(got)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, string(expectJSON), string(gotJSON))
+}
+
+func TestOpenTelemetryHTTPErrorStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, err := w.Write([]byte("bad payload"))
+		assert.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: strings.TrimPrefix(ts.URL, "http://"),
+		Protocol:       "http/protobuf",
+		Compression:    "none",
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	input := testutil.MustMetric(
+		"cpu_temp",
+		map[string]string{},
+		map[string]interface{}{
+			"gauge": 87.332,
+		},
+		time.Unix(0, 1622848686000000000))
+
+	err := plugin.Write([]telegraf.Metric{input})
+	require.ErrorContains(t, err, "received status code: 400. body: bad payload")
+}
+
+func TestOpenTelemetryHTTPPartialSuccess(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write(partialSuccessResponse(1, "invalid data point"))
+		assert.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Compression:    "none",
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	input := testutil.MustMetric(
+		"cpu_temp",
+		map[string]string{},
+		map[string]interface{}{
+			"gauge": 87.332,
+		},
+		time.Unix(0, 1622848686000000000))
+
+	// Partially rejected requests must not be retried or reported as failed.
+	require.NoError(t, plugin.Write([]telegraf.Metric{input}))
+}
+
+func TestParsePartialSuccess(t *testing.T) {
+	ps, err := parsePartialSuccess(partialSuccessResponse(3, "out of range"))
+	require.NoError(t, err)
+	require.Equal(t, partialSuccess{Rejected: 3, ErrorMessage: "out of range"}, ps)
+
+	ps, err = parsePartialSuccess(nil)
+	require.NoError(t, err)
+	require.Equal(t, partialSuccess{}, ps)
+
+	_, err = parsePartialSuccess([]byte{0x0a, 0x05})
+	require.Error(t, err)
+}
+
+func TestParsePartialSuccessJSON(t *testing.T) {
+	ps, err := parsePartialSuccessJSON([]byte(`{"partialSuccess":{"rejectedDataPoints":"3","errorMessage":"out of range"}}`))
+	require.NoError(t, err)
+	require.Equal(t, partialSuccess{Rejected: 3, ErrorMessage: "out of range"}, ps)
+
+	ps, err = parsePartialSuccessJSON([]byte(`{"partialSuccess":{"rejectedSpans":2}}`))
+	require.NoError(t, err)
+	require.Equal(t, partialSuccess{Rejected: 2}, ps)
+
+	ps, err = parsePartialSuccessJSON([]byte(`{}`))
+	require.NoError(t, err)
+	require.Equal(t, partialSuccess{}, ps)
+
+	_, err = parsePartialSuccessJSON([]byte(`{"partialSuccess":{"rejectedLogRecords":"x"}}`))
+	require.Error(t, err)
+}
+
+func TestOpenTelemetryHTTPJSON(t *testing.T) {
+	var got pmetric.Metrics
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "header1", r.Header.Get("test"))
+
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(gz)
+		require.NoError(t, err)
+
+		request := pmetricotlp.NewRequest()
+		require.NoError(t, request.UnmarshalJSON(body))
+		got = request.Metrics().Clone()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write([]byte(`{}`))
+		assert.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Encoding:       "json",
+		Headers:        map[string]string{"test": "header1"},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, got.DataPointCount())
+}
+
+func TestOpenTelemetryZstd(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	registerCompressor("zstd")
+	plugin.callOptions = []grpc.CallOption{grpc.UseCompressor("zstd")}
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, m.GotMetrics().DataPointCount())
+}
+
+func TestOpenTelemetryHTTPZstd(t *testing.T) {
+	var got pmetric.Metrics
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "zstd", r.Header.Get("Content-Encoding"))
+
+		dec, err := zstd.NewReader(r.Body)
+		require.NoError(t, err)
+		defer dec.Close()
+		body, err := io.ReadAll(dec)
+		require.NoError(t, err)
+
+		request := pmetricotlp.NewRequest()
+		require.NoError(t, request.UnmarshalProto(body))
+		got = request.Metrics().Clone()
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Compression:    "zstd",
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	// The second export reuses the encoder of the first.
+	for i := 0; i < 2; i++ {
+		got = pmetric.NewMetrics()
+		require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+		require.Equal(t, 1, got.DataPointCount())
+	}
+}
+
+func TestOpenTelemetrySnappy(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	registerCompressor("snappy")
+	plugin.callOptions = []grpc.CallOption{grpc.UseCompressor("snappy")}
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, m.GotMetrics().DataPointCount())
+}
+
+func TestOpenTelemetryHTTPSnappy(t *testing.T) {
+	var got pmetric.Metrics
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+
+		compressed, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		body, err := snappy.Decode(nil, compressed)
+		require.NoError(t, err)
+
+		request := pmetricotlp.NewRequest()
+		require.NoError(t, request.UnmarshalProto(body))
+		got = request.Metrics().Clone()
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Compression:    "snappy",
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, got.DataPointCount())
+}
+
+func TestOpenTelemetryCompressionLevel(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress:   m.Address(),
+		CompressionLevel: gzip.BestCompression,
+		Headers:          map[string]string{"test": "header1"},
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, m.GotMetrics().DataPointCount())
+}
+
+func TestOpenTelemetryHTTPCompressionLevel(t *testing.T) {
+	var got pmetric.Metrics
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(gz)
+		require.NoError(t, err)
+
+		request := pmetricotlp.NewRequest()
+		require.NoError(t, request.UnmarshalProto(body))
+		got = request.Metrics().Clone()
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress:   ts.URL,
+		Protocol:         "http/protobuf",
+		CompressionLevel: gzip.BestSpeed,
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, got.DataPointCount())
+}
+
+func TestOpenTelemetryCompressionMinSize(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress:     m.Address(),
+		CompressionLevel:   gzip.BestCompression,
+		CompressionMinSize: config.Size(1024 * 1024),
+		Headers:            map[string]string{"test": "header1"},
+		Log:                testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, m.GotMetrics().DataPointCount())
+}
+
+func TestOpenTelemetryHTTPCompressionMinSize(t *testing.T) {
+	var encodings []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress:     ts.URL,
+		Protocol:           "http/protobuf",
+		CompressionMinSize: config.Size(1024 * 1024),
+		Log:                testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	plugin.CompressionMinSize = 1
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, []string{"", "gzip"}, encodings)
+}
+
+func TestOpenTelemetryUserAgent(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: m.Address(),
+		UserAgent:      "edge-agent/1.0",
+		Headers:        map[string]string{"test": "header1"},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	userAgent := m.Metadata().Get("user-agent")
+	require.Len(t, userAgent, 1)
+	require.True(t, strings.HasPrefix(userAgent[0], "edge-agent/1.0 grpc-go/"), userAgent[0])
+}
+
+func TestOpenTelemetryHTTPUserAgent(t *testing.T) {
+	var userAgent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, internal.ProductToken(), userAgent)
+}
+
+func TestOpenTelemetryAuthority(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: m.Address(),
+		Authority:      "collector.example.com",
+		Headers:        map[string]string{"test": "header1"},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, []string{"collector.example.com"}, m.Metadata().Get(":authority"))
+}
+
+func TestOpenTelemetryHTTPAuthority(t *testing.T) {
+	var host string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Authority:      "collector.example.com:4318",
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, "collector.example.com:4318", host)
+}
+
+func TestOpenTelemetryHTTPTimeoutHeader(t *testing.T) {
+	var timeout string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout = r.Header.Get("X-OTLP-Timeout")
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Timeout:        config.Duration(3 * time.Second),
+		TimeoutHeader:  "X-OTLP-Timeout",
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Regexp(t, `^\d+m$`, timeout)
+	ms, err := strconv.Atoi(strings.TrimSuffix(timeout, "m"))
+	require.NoError(t, err)
+	require.LessOrEqual(t, ms, 3000)
+	require.Greater(t, ms, 2000)
+}
+
+func TestEncodeTimeout(t *testing.T) {
+	require.Equal(t, "0m", encodeTimeout(-time.Second))
+	require.Equal(t, "1500m", encodeTimeout(1500*time.Millisecond+time.Microsecond))
+	require.Equal(t, "99999999m", encodeTimeout(99999999*time.Millisecond))
+	require.Equal(t, "100000S", encodeTimeout(100000*time.Second))
+	require.Equal(t, "1666666H", encodeTimeout(100000000*time.Minute))
+}
+
+func TestAuthorityServerName(t *testing.T) {
+	plugin := &OpenTelemetry{
+		ClientConfig: tls.ClientConfig{InsecureSkipVerify: true},
+		Authority:    "collector.example.com:443",
+	}
+	tlsConfig, err := plugin.tlsConfig()
+	require.NoError(t, err)
+	require.Equal(t, "collector.example.com", tlsConfig.ServerName)
+
+	plugin.ServerName = "other.example.com"
+	tlsConfig, err = plugin.tlsConfig()
+	require.NoError(t, err)
+	require.Equal(t, "other.example.com", tlsConfig.ServerName)
+}
+
+func TestTLSInsecure(t *testing.T) {
+	secure, insecure := false, true
+
+	// Without any TLS option the connection is plaintext unless insecure is
+	// false.
+	plugin := &OpenTelemetry{}
+	tlsConfig, err := plugin.tlsConfig()
+	require.NoError(t, err)
+	require.Nil(t, tlsConfig)
+
+	plugin.Insecure = &secure
+	plugin.Authority = "collector.example.com"
+	tlsConfig, err = plugin.tlsConfig()
+	require.NoError(t, err)
+	require.NotNil(t, tlsConfig)
+	require.Equal(t, "collector.example.com", tlsConfig.ServerName)
+
+	plugin = &OpenTelemetry{ClientConfig: tls.ClientConfig{ServerName: "collector.example.com"}, Insecure: &insecure}
+	tlsConfig, err = plugin.tlsConfig()
+	require.NoError(t, err)
+	require.Nil(t, tlsConfig)
+}
+
+func TestTLSVersionsAndCiphers(t *testing.T) {
+	plugin := &OpenTelemetry{
+		ClientConfig: tls.ClientConfig{
+			TLSMinVersion:   "TLS12",
+			TLSMaxVersion:   "TLS13",
+			TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+		},
+	}
+	tlsConfig, err := plugin.tlsConfig()
+	require.NoError(t, err)
+
+	minVersion, err := tls.ParseTLSVersion("TLS12")
+	require.NoError(t, err)
+	maxVersion, err := tls.ParseTLSVersion("TLS13")
+	require.NoError(t, err)
+	ciphers, err := tls.ParseCiphers([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"})
+	require.NoError(t, err)
+	require.Equal(t, minVersion, tlsConfig.MinVersion)
+	require.Equal(t, maxVersion, tlsConfig.MaxVersion)
+	require.Equal(t, ciphers, tlsConfig.CipherSuites)
+}
+
+func TestConnectivityLogging(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	var logger capturingLogger
+	plugin := &OpenTelemetry{
+		ServiceAddress: m.Address(),
+		Headers:        map[string]string{"test": "header1"},
+		Log:            &logger,
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Eventually(t, func() bool {
+		info, _ := logger.messages()
+		return len(info) > 0
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, plugin.Close())
+	info, _ := logger.messages()
+	require.Contains(t, info, fmt.Sprintf("Connected to %q", m.Address()))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	logger = capturingLogger{}
+	plugin = &OpenTelemetry{ServiceAddress: address, Log: &logger}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	require.Eventually(t, func() bool {
+		_, warn := logger.messages()
+		return len(warn) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, plugin.Close())
+	_, warn := logger.messages()
+	require.Equal(t, []string{fmt.Sprintf("Connection to %q failed, reconnecting", address)}, warn)
+}
+
+func TestConnectWaitForReady(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: address,
+		WaitForReady:   true,
+		ConnectTimeout: config.Duration(100 * time.Millisecond),
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.ErrorContains(t, plugin.Connect(), "connecting to")
+
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+	plugin.ServiceAddress = m.Address()
+	require.NoError(t, plugin.Connect())
+	require.NoError(t, plugin.Close())
+}
+
+func TestCertificateReload(t *testing.T) {
+	pki := testutil.NewPKI("../../../testutil/pki")
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	copyFile := func(src, dst string) {
+		data, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(dst, data, 0600))
+	}
+	copyFile(pki.ClientCertPath(), certFile)
+	copyFile(pki.ClientKeyPath(), keyFile)
+
+	plugin := &OpenTelemetry{
+		ClientConfig: tls.ClientConfig{
+			TLSCA:   pki.CACertPath(),
+			TLSCert: certFile,
+			TLSKey:  keyFile,
+		},
+		TLSReloadInterval: config.Duration(time.Nanosecond),
+		Log:               testutil.Logger{},
+	}
+	tlsConfig, err := plugin.tlsConfig()
+	require.NoError(t, err)
+	require.Empty(t, tlsConfig.Certificates)
+	client, err := tlsConfig.GetClientCertificate(nil)
+	require.NoError(t, err)
+
+	// Rotated certificates are picked up
+	copyFile(pki.ServerCertPath(), certFile)
+	copyFile(pki.ServerKeyPath(), keyFile)
+	server, err := tlsConfig.GetClientCertificate(nil)
+	require.NoError(t, err)
+	require.NotEqual(t, client.Certificate, server.Certificate)
+
+	// Broken files keep the previous certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0600))
+	cert, err := tlsConfig.GetClientCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, server.Certificate, cert.Certificate)
+}
+
+func TestOpenTelemetryEndpointTLS(t *testing.T) {
+	pki := testutil.NewPKI("../../../testutil/pki")
+	serverTLS, err := (&tls.ServerConfig{TLSCert: pki.ServerCertPath(), TLSKey: pki.ServerKeyPath()}).TLSConfig()
+	require.NoError(t, err)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = serverTLS
+	ts.StartTLS()
+	defer ts.Close()
+
+	// The certificate is valid for "localhost", the endpoint is verified with
+	// its own CA and server name rather than the dial host.
+	for _, tt := range []struct {
+		serverName string
+		expected   string
+	}{
+		{serverName: "localhost"},
+		{serverName: "collector.example.com", expected: "certificate is valid for localhost"},
+	} {
+		t.Run(tt.serverName, func(t *testing.T) {
+			plugin := &OpenTelemetry{
+				ServiceAddress: ts.URL,
+				Protocol:       "http/protobuf",
+				EndpointTLS:    map[string]EndpointTLS{ts.URL: {ServerName: tt.serverName, TLSCA: pki.CACertPath()}},
+				Log:            testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+			require.NoError(t, plugin.Connect())
+			defer plugin.Close()
+
+			err := plugin.Write([]telegraf.Metric{newTestMetric()})
+			if tt.expected == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *OpenTelemetry
+		expected string
+	}{
+		{
+			name:   "defaults",
+			plugin: &OpenTelemetry{},
+		},
+		{
+			name:   "grpc target",
+			plugin: &OpenTelemetry{ServiceAddress: "dns:///collector:4317"},
+		},
+		{
+			name:   "http without scheme",
+			plugin: &OpenTelemetry{ServiceAddress: "collector:4318", Protocol: "http/protobuf"},
+		},
+		{
+			name:   "http unix socket",
+			plugin: &OpenTelemetry{ServiceAddress: "unix:///var/run/otelcol.sock", Protocol: "http/protobuf"},
+		},
+		{
+			name:     "http unix socket without path",
+			plugin:   &OpenTelemetry{ServiceAddress: "unix://", Protocol: "http/protobuf"},
+			expected: `invalid service_address "unix://": missing socket path`,
+		},
+		{
+			name:   "schema url",
+			plugin: &OpenTelemetry{SchemaURL: "https://opentelemetry.io/schemas/1.9.0"},
+		},
+		{
+			name:     "unsupported string fields",
+			plugin:   &OpenTelemetry{StringFields: "tag"},
+			expected: `unsupported string_fields "tag"`,
+		},
+		{
+			name:     "string only metrics without string fields as attributes",
+			plugin:   &OpenTelemetry{StringOnlyMetrics: "info"},
+			expected: `string_only_metrics "info" requires string_fields "attribute"`,
+		},
+		{
+			name:     "unsupported boolean fields",
+			plugin:   &OpenTelemetry{BooleanFields: "attribute"},
+			expected: `unsupported boolean_fields "attribute"`,
+		},
+		{
+			name:     "bool fields as gauge with boolean fields dropped",
+			plugin:   &OpenTelemetry{BoolFieldsAsGauge: true, BooleanFields: "drop"},
+			expected: `bool_fields_as_gauge conflicts with boolean_fields "drop"`,
+		},
+		{
+			name:     "same bool values",
+			plugin:   &OpenTelemetry{BoolFieldsAsGauge: true, BoolTrueValue: new(float64)},
+			expected: `bool_true_value and bool_false_value must differ, both are 0`,
+		},
+		{
+			name:     "unsupported non numeric metrics",
+			plugin:   &OpenTelemetry{NonNumericMetrics: "info"},
+			expected: `unsupported non_numeric_metrics "info"`,
+		},
+		{
+			name:     "invalid baggage key",
+			plugin:   &OpenTelemetry{Baggage: map[string]string{"user id": "1"}},
+			expected: `invalid baggage key "user id"`,
+		},
+		{
+			name:     "baggage with baggage header",
+			plugin:   &OpenTelemetry{Baggage: map[string]string{"env": "prod"}, Headers: map[string]string{"Baggage": "env=dev"}},
+			expected: `baggage and baggage_tags cannot be combined with a "baggage" header`,
+		},
+		{
+			name:     "attribute rule without name filter",
+			plugin:   &OpenTelemetry{AttributeRules: []AttributeRule{{Attributes: map[string]string{"db.system": "mysql"}}}},
+			expected: "name_filter of attribute_rule 1 must not be empty",
+		},
+		{
+			name:     "empty metadata name",
+			plugin:   &OpenTelemetry{MetadataTags: map[string]string{"tenant": ""}},
+			expected: `metadata name for tag "tenant" in metadata_tags must not be empty`,
+		},
+		{
+			name:     "negative preferred batch size",
+			plugin:   &OpenTelemetry{PreferredBatchSize: -1},
+			expected: "preferred_batch_size must not be negative",
+		},
+		{
+			name:     "negative max batch hold",
+			plugin:   &OpenTelemetry{MaxBatchHold: config.Duration(-time.Second)},
+			expected: "max_batch_hold must not be negative",
+		},
+		{
+			name:     "descending export latency buckets",
+			plugin:   &OpenTelemetry{ExportLatencyBuckets: []config.Duration{config.Duration(time.Second), config.Duration(time.Millisecond)}},
+			expected: "export_latency_buckets must be ascending",
+		},
+		{
+			name:     "zero export latency bucket",
+			plugin:   &OpenTelemetry{ExportLatencyBuckets: []config.Duration{0}},
+			expected: "export_latency_buckets must be positive",
+		},
+		{
+			name:     "grpc retry policy with too many attempts",
+			plugin:   &OpenTelemetry{GRPCRetryPolicy: &GRPCRetryPolicy{MaxAttempts: 6}},
+			expected: "max_attempts of grpc_retry_policy must be between 2 and 5",
+		},
+		{
+			name:     "grpc retry policy with unsupported status code",
+			plugin:   &OpenTelemetry{GRPCRetryPolicy: &GRPCRetryPolicy{RetryableStatusCodes: []string{"GONE"}}},
+			expected: `unsupported status code "GONE" in retryable_status_codes of grpc_retry_policy`,
+		},
+		{
+			name:     "negative heartbeat interval",
+			plugin:   &OpenTelemetry{HeartbeatInterval: config.Duration(-time.Second)},
+			expected: "heartbeat_interval must not be negative",
+		},
+		{
+			name:   "include agent metadata",
+			plugin: &OpenTelemetry{IncludeAgentMetadata: true, Headers: map[string]string{"authorization": "secret"}},
+		},
+		{
+			name:     "insecure with tls options",
+			plugin:   &OpenTelemetry{Insecure: &[]bool{true}[0], ClientConfig: tls.ClientConfig{TLSCA: "ca.pem"}},
+			expected: "insecure = true cannot be combined with the tls_* options",
+		},
+		{
+			name:     "insecure with https address",
+			plugin:   &OpenTelemetry{Insecure: &[]bool{true}[0], Protocol: "http/protobuf", ServiceAddress: "https://collector:4318"},
+			expected: `address "https://collector:4318" contradicts insecure = true`,
+		},
+		{
+			name:     "secure with http address",
+			plugin:   &OpenTelemetry{Insecure: new(bool), Protocol: "http/protobuf", ServiceAddress: "http://collector:4318"},
+			expected: `address "http://collector:4318" contradicts insecure = false`,
+		},
+		{
+			name:     "negative max series",
+			plugin:   &OpenTelemetry{MaxSeries: -1},
+			expected: "max_series must not be negative",
+		},
+		{
+			name:     "endpoint tls of unknown endpoint",
+			plugin:   &OpenTelemetry{Endpoints: []string{"collector-1:4317"}, EndpointTLS: map[string]EndpointTLS{"collector-2:4317": {ServerName: "collector-2"}}},
+			expected: `endpoint_tls "collector-2:4317" is not one of the endpoints`,
+		},
+		{
+			name:     "endpoint tls with http address",
+			plugin:   &OpenTelemetry{Protocol: "http/protobuf", ServiceAddress: "http://collector:4318", EndpointTLS: map[string]EndpointTLS{"http://collector:4318": {ServerName: "collector"}}},
+			expected: `endpoint_tls "http://collector:4318" requires an "https://" address`,
+		},
+		{
+			name:     "endpoint tls server name not matching authority",
+			plugin:   &OpenTelemetry{Authority: "collector.example.com", EndpointTLS: map[string]EndpointTLS{"localhost:4317": {ServerName: "other.example.com"}}},
+			expected: `tls_server_name "other.example.com" of endpoint_tls "localhost:4317" does not match authority "collector.example.com"`,
+		},
+		{
+			name:     "timeout header with grpc",
+			plugin:   &OpenTelemetry{TimeoutHeader: "X-OTLP-Timeout"},
+			expected: `timeout_header is only supported with the "http/protobuf" protocol`,
+		},
+		{
+			name:     "invalid timeout header",
+			plugin:   &OpenTelemetry{Protocol: "http/protobuf", TimeoutHeader: "OTLP Timeout"},
+			expected: `invalid timeout_header "OTLP Timeout"`,
+		},
+		{
+			name:     "retry jitter out of range",
+			plugin:   &OpenTelemetry{RetryJitter: 1.5},
+			expected: "retry_jitter must be between 0 and 1",
+		},
+		{
+			name:     "max timeout less than timeout",
+			plugin:   &OpenTelemetry{Timeout: config.Duration(5 * time.Second), MaxTimeout: config.Duration(time.Second)},
+			expected: "max_timeout must not be less than timeout",
+		},
+		{
+			name:     "unsupported resource detector",
+			plugin:   &OpenTelemetry{ResourceDetection: true, ResourceDetectors: []string{"cloud.region"}},
+			expected: `unsupported resource detector "cloud.region"`,
+		},
+		{
+			name:     "invalid undefined env behavior",
+			plugin:   &OpenTelemetry{UndefinedEnvBehavior: "ignore"},
+			expected: `unsupported undefined_env_behavior "ignore"`,
+		},
+		{
+			name:     "invalid schema url",
+			plugin:   &OpenTelemetry{SchemaURL: "1.9.0"},
+			expected: `invalid schema_url "1.9.0"`,
+		},
+		{
+			name:     "invalid non-finite handling",
+			plugin:   &OpenTelemetry{NonFiniteHandling: "skip"},
+			expected: `unsupported non_finite_handling "skip"`,
+		},
+		{
+			name:     "compression level out of range",
+			plugin:   &OpenTelemetry{CompressionLevel: 10},
+			expected: "compression_level must be between 1 and 9",
+		},
+		{
+			name:     "compression level without gzip",
+			plugin:   &OpenTelemetry{Compression: "zstd", CompressionLevel: 3},
+			expected: "compression_level is only supported with gzip compression",
+		},
+		{
+			name:     "invalid untyped as",
+			plugin:   &OpenTelemetry{UntypedAs: "counter"},
+			expected: `unsupported untyped_as "counter"`,
+		},
+		{
+			name:     "json over grpc",
+			plugin:   &OpenTelemetry{Encoding: "json"},
+			expected: `encoding "json" is only supported with the "http/protobuf" protocol`,
+		},
+		{
+			name:     "invalid encoding",
+			plugin:   &OpenTelemetry{Encoding: "xml", Protocol: "http/protobuf"},
+			expected: `unsupported encoding "xml"`,
+		},
+		{
+			name:     "invalid signal override",
+			plugin:   &OpenTelemetry{Traces: &SignalConfig{Compression: "lz4"}},
+			expected: `traces: unsupported compression "lz4"`,
+		},
+		{
+			name:     "invalid proxy url",
+			plugin:   &OpenTelemetry{TCPProxy: proxy.TCPProxy{UseProxy: true, ProxyURL: "ftp://proxy:21"}},
+			expected: "creating proxy failed: proxy: unknown scheme: ftp",
+		},
+		{
+			name:     "invalid on full",
+			plugin:   &OpenTelemetry{QueueSize: 10, OnFull: "drop_newest"},
+			expected: `unsupported on_full "drop_newest"`,
+		},
+		{
+			name:     "service address and endpoints",
+			plugin:   &OpenTelemetry{ServiceAddress: "localhost:4317", Endpoints: []string{"localhost:4317"}},
+			expected: "service_address and endpoints are mutually exclusive",
+		},
+		{
+			name:     "invalid endpoint",
+			plugin:   &OpenTelemetry{Endpoints: []string{"localhost:4317", "collector"}},
+			expected: `invalid service_address "collector": address collector: missing port in address`,
+		},
+		{
+			name:   "round robin",
+			plugin: &OpenTelemetry{BalancerPolicy: "round_robin"},
+		},
+		{
+			name:     "unsupported balancer policy",
+			plugin:   &OpenTelemetry{BalancerPolicy: "random"},
+			expected: `unsupported balancer_policy "random"`,
+		},
+		{
+			name:     "unsupported protocol",
+			plugin:   &OpenTelemetry{Protocol: "http/json"},
+			expected: `unsupported protocol "http/json"`,
+		},
+		{
+			name:     "unsupported compression",
+			plugin:   &OpenTelemetry{Compression: "lz4"},
+			expected: `unsupported compression "lz4"`,
+		},
+		{
+			name:     "grpc address without port",
+			plugin:   &OpenTelemetry{ServiceAddress: "collector"},
+			expected: `invalid service_address "collector": address collector: missing port in address`,
+		},
+		{
+			name:     "http address without host",
+			plugin:   &OpenTelemetry{ServiceAddress: "http://", Protocol: "http/protobuf"},
+			expected: `invalid service_address "http://": missing host`,
+		},
+		{
+			name:   "keepalive",
+			plugin: &OpenTelemetry{KeepaliveTime: config.Duration(5 * time.Minute)},
+		},
+		{
+			name:     "keepalive time too low",
+			plugin:   &OpenTelemetry{KeepaliveTime: config.Duration(time.Second)},
+			expected: "keepalive_time must be at least 10s",
+		},
+		{
+			name:   "grpc window sizes",
+			plugin: &OpenTelemetry{GRPCInitialWindowSize: config.Size(1 << 20), GRPCInitialConnWindowSize: config.Size(16 << 20)},
+		},
+		{
+			name:     "grpc initial window size too small",
+			plugin:   &OpenTelemetry{GRPCInitialWindowSize: config.Size(1024)},
+			expected: "grpc_initial_window_size must be between 65536 and 2147483647 bytes",
+		},
+		{
+			name:     "grpc initial conn window size too large",
+			plugin:   &OpenTelemetry{GRPCInitialConnWindowSize: config.Size(1 << 31)},
+			expected: "grpc_initial_conn_window_size must be between 65536 and 2147483647 bytes",
+		},
+		{
+			name:     "max message size too large",
+			plugin:   &OpenTelemetry{MaxMsgSize: config.Size(1 << 32)},
+			expected: "max_msg_size must be between 0 and 2147483647 bytes",
+		},
+		{
+			name:     "negative max payload size",
+			plugin:   &OpenTelemetry{MaxPayloadSize: config.Size(-1)},
+			expected: "max_payload_size must not be negative",
+		},
+		{
+			name:     "unsupported aggregation temporality",
+			plugin:   &OpenTelemetry{AggregationTemporality: "rate"},
+			expected: `unsupported aggregation_temporality "rate"`,
+		},
+		{
+			name:     "unsupported temporality",
+			plugin:   &OpenTelemetry{Temporality: map[string]string{"cpu": "rate"}},
+			expected: `unsupported temporality "rate" for "cpu" in temporality`,
+		},
+		{
+			name:     "unsupported histogram type",
+			plugin:   &OpenTelemetry{HistogramType: "native"},
+			expected: `unsupported histogram_type "native"`,
+		},
+		{
+			name:     "incomplete oauth2",
+			plugin:   &OpenTelemetry{OAuth2: oauth.OAuth2Config{TokenURL: "https://auth.example.com/token"}},
+			expected: "oauth2 requires token_url, client_id and client_secret",
+		},
+		{
+			name:     "quantile field pattern without group",
+			plugin:   &OpenTelemetry{QuantileFieldPattern: "^p[0-9]+$"},
+			expected: "quantile_field_pattern needs a capturing group for the percentile",
+		},
+		{
+			name:     "unsupported metric type",
+			plugin:   &OpenTelemetry{MetricTypes: map[string]string{"cpu": "counter"}},
+			expected: `unsupported metric type "counter" for "cpu" in metric_types`,
+		},
+		{
+			name:     "unsupported excluded type",
+			plugin:   &OpenTelemetry{ExcludeTypes: []string{"sum"}},
+			expected: `unsupported type "sum" in exclude_types`,
+		},
+		{
+			name:     "invalid authority",
+			plugin:   &OpenTelemetry{Authority: "https://collector.example.com"},
+			expected: `invalid authority "https://collector.example.com"`,
+		},
+		{
+			name: "authority not matching server name",
+			plugin: &OpenTelemetry{
+				Authority:    "collector.example.com:443",
+				ClientConfig: tls.ClientConfig{ServerName: "other.example.com"},
+			},
+			expected: `authority "collector.example.com:443" does not match tls_server_name "other.example.com"`,
+		},
+		{
+			name:     "negative compression min size",
+			plugin:   &OpenTelemetry{CompressionMinSize: config.Size(-1)},
+			expected: "compression_min_size must not be negative",
+		},
+		{
+			name:     "short dns refresh interval",
+			plugin:   &OpenTelemetry{DNSRefreshInterval: config.Duration(time.Second)},
+			expected: "dns_refresh_interval must be at least 30s",
+		},
+		{
+			name:     "negative retries",
+			plugin:   &OpenTelemetry{MaxRetries: -1},
+			expected: "max_retries must not be negative",
+		},
+		{
+			name:     "negative circuit breaker threshold",
+			plugin:   &OpenTelemetry{CircuitBreakerThreshold: -1},
+			expected: "circuit_breaker_threshold must not be negative",
+		},
+		{
+			name: "retry intervals",
+			plugin: &OpenTelemetry{
+				RetryInitialInterval: config.Duration(time.Minute),
+				RetryMaxInterval:     config.Duration(time.Second),
+			},
+			expected: "retry_initial_interval must not exceed retry_max_interval",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.plugin.Init()
+			if tt.expected == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+func TestOpenTelemetryHTTPOAuth2(t *testing.T) {
+	var tokenRequests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokenRequests++
+			w.Header().Set("Content-Type", "application/json")
+			_, err := w.Write([]byte(`{"access_token":"secret-token","token_type":"bearer","expires_in":3600}`))
+			assert.NoError(t, err)
+		case "/v1/metrics":
+			assert.Equal(t, "Bearer secret-token", r.Header.Get("Authorization"))
+			assert.Equal(t, "header1", r.Header.Get("test"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Headers:        map[string]string{"test": "header1"},
+		OAuth2: oauth.OAuth2Config{
+			ClientID:     "client",
+			ClientSecret: "secret",
+			TokenURL:     ts.URL + "/token",
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, tokenRequests)
+}
+
+func TestOpenTelemetryOAuth2TokenFailure(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	plugin := newTestPlugin(t, m)
+	plugin.OAuth2 = oauth.OAuth2Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		TokenURL:     ts.URL,
+	}
+	plugin.connectOAuth2()
+
+	err := plugin.Write([]telegraf.Metric{newTestMetric()})
+	require.ErrorContains(t, err, "failed to obtain OAuth2 token")
+	require.Equal(t, 0, m.Requests())
+}
+
+func TestOpenTelemetryTraces(t *testing.T) {
+	expect := ptrace.NewTraces()
+	{
+		rs := expect.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().InsertString("service.name", "potato-service")
+		rs.Resource().Attributes().InsertString("attr-key", "attr-val")
+		ss := rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName("My Library Name")
+		span := ss.Spans().AppendEmpty()
+		span.SetTraceID(pcommon.NewTraceID([16]byte{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x00}))
+		span.SetSpanID(pcommon.NewSpanID([8]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}))
+		span.SetParentSpanID(pcommon.NewSpanID([8]byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01}))
+		span.SetName("GET /potato")
+		span.SetKind(ptrace.SpanKindServer)
+		span.SetStartTimestamp(pcommon.Timestamp(1622848686000000000))
+		span.SetEndTimestamp(pcommon.Timestamp(1622848686000500000))
+		span.Status().SetCode(ptrace.StatusCodeOk)
+		span.Attributes().InsertString("http.method", "GET")
+		span.Attributes().InsertInt("http.status_code", 200)
+		span.Attributes().Sort()
+	}
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	metricsConverter, err := influx2otel.NewLineProtocolToOtelMetrics(common.NoopLogger{})
+	require.NoError(t, err)
+	plugin := &OpenTelemetry{
+		ServiceAddress:       m.Address(),
+		Timeout:              config.Duration(time.Second),
+		Headers:              map[string]string{"test": "header1"},
+		Attributes:           map[string]string{"attr-key": "attr-val"},
+		Log:                  testutil.Logger{},
+		metricsConverter:     metricsConverter,
+		grpcClientConn:       m.GrpcClient(),
+		endpointMu:           &sync.Mutex{},
+		drainer:              newDrainer(),
+		metricsServiceClient: pmetricotlp.NewClient(m.GrpcClient()),
+		tracesServiceClient:  ptraceotlp.NewClient(m.GrpcClient()),
+	}
+	plugin.registerStats()
+
+	input := testutil.MustMetric(
+		"spans",
+		map[string]string{
+			"trace_id":          "0a0b0c0d0e0f01020304050607080900",
+			"span_id":           "0102030405060708",
+			"parent_span_id":    "0807060504030201",
+			"name":              "GET /potato",
+			"kind":              "SPAN_KIND_SERVER",
+			"otel.library.name": "My Library Name",
+			"service.name":      "potato-service",
+		},
+		map[string]interface{}{
+			"end_time_unix_nano": int64(1622848686000500000),
+			"duration_nano":      int64(500000),
+			"otel.status_code":   "OK",
+			"http.method":        "GET",
+			"http.status_code":   int64(200),
+		},
+		time.Unix(0, 1622848686000000000))
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{input}))
+	require.Equal(t, pmetric.Metrics{}, m.GotMetrics())
+
+	expectJSON, err := ptrace.NewJSONMarshaler().MarshalTraces(expect)
+	require.NoError(t, err)
+
+	gotJSON, err := ptrace.NewJSONMarshaler().MarshalTraces(m.GotTraces())
+	require.NoError(t, err)
+
+	assert.JSONEq(t, string(expectJSON), string(gotJSON))
+}
+
+func TestOpenTelemetryLogs(t *testing.T) {
+	expect := plog.NewLogs()
+	{
+		rl := expect.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().InsertString("host.name", "potato")
+		rl.Resource().Attributes().InsertString("attr-key", "attr-val")
+		sl := rl.ScopeLogs().AppendEmpty()
+		record := sl.LogRecords().AppendEmpty()
+		record.SetTimestamp(pcommon.Timestamp(1622848686000000000))
+		record.SetSeverityNumber(plog.SeverityNumberWARN)
+		record.SetSeverityText("warning")
+		record.Body().SetStringVal("disk is almost full")
+		record.Attributes().InsertString("appname", "diskd")
+		record.Attributes().InsertString("severity", "warning")
+		record.Attributes().InsertInt("severity_code", 4)
+		record.Attributes().Sort()
+	}
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	metricsConverter, err := influx2otel.NewLineProtocolToOtelMetrics(common.NoopLogger{})
+	require.NoError(t, err)
+	plugin := &OpenTelemetry{
+		ServiceAddress:       m.Address(),
+		Timeout:              config.Duration(time.Second),
+		Headers:              map[string]string{"test": "header1"},
+		Attributes:           map[string]string{"attr-key": "attr-val"},
+		LogMeasurements:      []string{"syslog"},
+		Log:                  testutil.Logger{},
+		metricsConverter:     metricsConverter,
+		grpcClientConn:       m.GrpcClient(),
+		endpointMu:           &sync.Mutex{},
+		drainer:              newDrainer(),
+		metricsServiceClient: pmetricotlp.NewClient(m.GrpcClient()),
+		logsServiceClient:    plogotlp.NewClient(m.GrpcClient()),
+	}
+	plugin.registerStats()
+
+	input := testutil.MustMetric(
+		"syslog",
+		map[string]string{
+			"appname":   "diskd",
+			"severity":  "warning",
+			"host.name": "potato",
+		},
+		map[string]interface{}{
+			"message":       "disk is almost full",
+			"severity_code": int64(4),
+		},
+		time.Unix(0, 1622848686000000000))
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{input}))
+	require.Equal(t, pmetric.Metrics{}, m.GotMetrics())
+
+	got := m.GotLogs()
+	require.Equal(t, 1, got.LogRecordCount())
+	record := got.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	require.NotZero(t, record.ObservedTimestamp())
+	record.SetObservedTimestamp(0)
+
+	expectJSON, err := plog.NewJSONMarshaler().MarshalLogs(expect)
+	require.NoError(t, err)
+
+	gotJSON, err := plog.NewJSONMarshaler().MarshalLogs(got)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, string(expectJSON), string(gotJSON))
+}
+
+func TestOpenTelemetryRetry(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+	m.FailNext(status.Error(codes.Unavailable, "restarting"), status.Error(codes.ResourceExhausted, "busy"))
+
+	plugin := newTestPlugin(t, m)
+	plugin.MaxRetries = 2
+	plugin.RetryInitialInterval = config.Duration(10 * time.Millisecond)
+	plugin.RetryMaxInterval = config.Duration(20 * time.Millisecond)
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 3, m.Requests())
+	require.Equal(t, 1, m.GotMetrics().DataPointCount())
+}
+
+func TestOpenTelemetryRetryExhausted(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+	m.FailNext(status.Error(codes.Unavailable, "down"), status.Error(codes.Unavailable, "down"))
+
+	plugin := newTestPlugin(t, m)
+	plugin.MaxRetries = 1
+	plugin.RetryInitialInterval = config.Duration(10 * time.Millisecond)
+	plugin.RetryMaxInterval = config.Duration(10 * time.Millisecond)
+
+	err := plugin.Write([]telegraf.Metric{newTestMetric()})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, 2, m.Requests())
+}
+
+func TestOpenTelemetryNoRetryOnPermanentError(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+	m.FailNext(status.Error(codes.InvalidArgument, "bad data"))
+
+	plugin := newTestPlugin(t, m)
+	plugin.MaxRetries = 3
+	plugin.RetryInitialInterval = config.Duration(10 * time.Millisecond)
+	plugin.RetryMaxInterval = config.Duration(10 * time.Millisecond)
+
+	err := plugin.Write([]telegraf.Metric{newTestMetric()})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, 1, m.Requests())
+}
+
+func TestOpenTelemetryRetryRespectsTimeout(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+	m.FailNext(status.Error(codes.Unavailable, "down"), status.Error(codes.Unavailable, "down"))
+
+	plugin := newTestPlugin(t, m)
+	plugin.Timeout = config.Duration(100 * time.Millisecond)
+	plugin.MaxRetries = 5
+	plugin.RetryInitialInterval = config.Duration(time.Second)
+	plugin.RetryMaxInterval = config.Duration(time.Second)
+
+	err := plugin.Write([]telegraf.Metric{newTestMetric()})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, 1, m.Requests())
+}
+
+func TestUpsertTypedAttribute(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected pcommon.Value
+	}{
+		{value: "demo", expected: pcommon.NewValueString("demo")},
+		{value: "true", expected: pcommon.NewValueBool(true)},
+		{value: "false", expected: pcommon.NewValueBool(false)},
+		{value: "True", expected: pcommon.NewValueString("True")},
+		{value: "42", expected: pcommon.NewValueInt(42)},
+		{value: "-7", expected: pcommon.NewValueInt(-7)},
+		{value: "042", expected: pcommon.NewValueString("042")},
+		{value: "0.5", expected: pcommon.NewValueDouble(0.5)},
+		{value: "1.10", expected: pcommon.NewValueString("1.10")},
+		{value: "", expected: pcommon.NewValueString("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			attributes := pcommon.NewMap()
+			upsertTypedAttribute(attributes, "key", tt.value)
+			actual, found := attributes.Get("key")
+			require.True(t, found)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestPromoteResourceTags(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	{
+		rm := metrics.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().InsertString("service.name", "demo")
+		sm := rm.ScopeMetrics().AppendEmpty()
+		sm.Scope().SetName("My Library Name")
+		m := sm.Metrics().AppendEmpty()
+		m.SetName("cpu_temp")
+		m.SetDataType(pmetric.MetricDataTypeGauge)
+		for i, host := range []string{"a", "b", "a"} {
+			dp := m.Gauge().DataPoints().AppendEmpty()
+			dp.Attributes().InsertString("host", host)
+			dp.Attributes().InsertString("cpu", "cpu0")
+			dp.SetDoubleVal(float64(i))
+		}
+	}
+
+	expect := pmetric.NewMetrics()
+	for _, host := range []string{"a", "b"} {
+		rm := expect.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().InsertString("host", host)
+		rm.Resource().Attributes().InsertString("service.name", "demo")
+		sm := rm.ScopeMetrics().AppendEmpty()
+		sm.Scope().SetName("My Library Name")
+		m := sm.Metrics().AppendEmpty()
+		m.SetName("cpu_temp")
+		m.SetDataType(pmetric.MetricDataTypeGauge)
+	}
+	for i, rmIndex := range []int{0, 1, 0} {
+		dp := expect.ResourceMetrics().At(rmIndex).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().AppendEmpty()
+		dp.Attributes().InsertString("cpu", "cpu0")
+		dp.SetDoubleVal(float64(i))
+	}
+
+	got := promoteResourceTags(metrics, []string{"host", "missing"})
+
+	expectJSON, err := pmetric.NewJSONMarshaler().MarshalMetrics(expect)
+	require.NoError(t, err)
+	gotJSON, err := pmetric.NewJSONMarshaler().MarshalMetrics(got)
+	require.NoError(t, err)
+	require.JSONEq(t, string(expectJSON), string(gotJSON))
+}
+
+func TestSetScopes(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	rm := metrics.ResourceMetrics().AppendEmpty()
+	rm.ScopeMetrics().AppendEmpty()
+	rm.ScopeMetrics().AppendEmpty().Scope().SetName("My Library Name")
+
+	plugin := &OpenTelemetry{ScopeName: "telegraf", ScopeVersion: "1.24.0"}
+	plugin.setScopes(metrics)
+
+	require.Equal(t, "telegraf", rm.ScopeMetrics().At(0).Scope().Name())
+	require.Equal(t, "1.24.0", rm.ScopeMetrics().At(0).Scope().Version())
+	require.Equal(t, "My Library Name", rm.ScopeMetrics().At(1).Scope().Name())
+	require.Empty(t, rm.ScopeMetrics().At(1).Scope().Version())
+}
+
+func TestSetSchemaURL(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	rm := metrics.ResourceMetrics().AppendEmpty()
+	rm.ScopeMetrics().AppendEmpty()
+
+	setSchemaURL(rm, "https://opentelemetry.io/schemas/1.9.0")
+
+	require.Equal(t, "https://opentelemetry.io/schemas/1.9.0", rm.SchemaUrl())
+	require.Equal(t, "https://opentelemetry.io/schemas/1.9.0", rm.ScopeMetrics().At(0).SchemaUrl())
+}
+
+func TestSplitMetrics(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	for _, host := range []string{"a", "b"} {
+		rm := metrics.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().InsertString("host.name", host)
+		sm := rm.ScopeMetrics().AppendEmpty()
+		for _, name := range []string{"cpu_temp", "mem_used"} {
+			m := sm.Metrics().AppendEmpty()
+			m.SetName(name)
+			m.SetDataType(pmetric.MetricDataTypeGauge)
+			for i := 0; i < 10; i++ {
+				dp := m.Gauge().DataPoints().AppendEmpty()
+				dp.Attributes().InsertString("cpu", fmt.Sprintf("cpu%d", i))
+				dp.SetDoubleVal(float64(i))
+			}
+		}
+	}
+	// A data point too large to be sent on its own
+	dp := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().AppendEmpty()
+	dp.Attributes().InsertString("huge", strings.Repeat("x", 1000))
+
+	const maxSize = 500
+	total := metrics.DataPointCount()
+	chunks, dropped := splitMetrics(metrics, maxSize)
+	require.Equal(t, 1, dropped)
+	require.Greater(t, len(chunks), 1)
+
+	var count int
+	for _, chunk := range chunks {
+		require.LessOrEqual(t, metricsSizer.MetricsSize(chunk), maxSize)
+		count += chunk.DataPointCount()
+	}
+	require.Equal(t, total-dropped, count)
+
+	chunks, dropped = splitMetrics(metrics, 1<<20)
+	require.Zero(t, dropped)
+	require.Len(t, chunks, 1)
+}
+
+func TestSplitMetricsByCount(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	for _, host := range []string{"a", "b"} {
+		rm := metrics.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().InsertString("host.name", host)
+		sm := rm.ScopeMetrics().AppendEmpty()
+		sm.Scope().SetName("telegraf")
+		for _, name := range []string{"requests", "errors"} {
+			m := sm.Metrics().AppendEmpty()
+			m.SetName(name)
+			m.SetDataType(pmetric.MetricDataTypeSum)
+			m.Sum().SetIsMonotonic(true)
+			m.Sum().SetAggregationTemporality(pmetric.MetricAggregationTemporalityCumulative)
+			for i := 0; i < 3; i++ {
+				m.Sum().DataPoints().AppendEmpty().SetIntVal(int64(i))
+			}
+		}
+	}
+
+	chunks := splitMetricsByCount(metrics, 4)
+	require.Len(t, chunks, 3)
+	require.Equal(t, 4, chunks[0].DataPointCount())
+	require.Equal(t, 4, chunks[1].DataPointCount())
+	require.Equal(t, 4, chunks[2].DataPointCount())
+
+	// The first chunk holds all data points of "requests" and one of
+	// "errors" of the first resource.
+	require.Equal(t, 1, chunks[0].ResourceMetrics().Len())
+	sm := chunks[0].ResourceMetrics().At(0).ScopeMetrics().At(0)
+	require.Equal(t, "telegraf", sm.Scope().Name())
+	require.Equal(t, 2, sm.Metrics().Len())
+	require.Equal(t, 3, sm.Metrics().At(0).Sum().DataPoints().Len())
+	require.True(t, sm.Metrics().At(1).Sum().IsMonotonic())
+	require.Equal(t, pmetric.MetricAggregationTemporalityCumulative, sm.Metrics().At(1).Sum().AggregationTemporality())
+
+	// The second chunk spans both resources.
+	require.Equal(t, 2, chunks[1].ResourceMetrics().Len())
+	host, _ := chunks[1].ResourceMetrics().At(1).Resource().Attributes().Get("host.name")
+	require.Equal(t, "b", host.StringVal())
+
+	require.Len(t, splitMetricsByCount(metrics, 12), 1)
+}
+
+func TestDeltaConverter(t *testing.T) {
+	newSum := func(ts int64, value float64) pmetric.Metrics {
+		metrics := pmetric.NewMetrics()
+		m := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		m.SetName("requests")
+		m.SetDataType(pmetric.MetricDataTypeSum)
+		m.Sum().SetAggregationTemporality(pmetric.MetricAggregationTemporalityCumulative)
+		m.Sum().SetIsMonotonic(true)
+		dp := m.Sum().DataPoints().AppendEmpty()
+		dp.Attributes().InsertString("host", "a")
+		dp.SetTimestamp(pcommon.Timestamp(ts))
+		dp.SetDoubleVal(value)
+		return metrics
+	}
+	now := time.Now()
+	c := newDeltaConverter()
+
+	// The first value is the baseline
+	metrics := newSum(1, 10)
+	c.convert(metrics, now, nil)
+	require.Zero(t, metrics.ResourceMetrics().Len())
+
+	metrics = newSum(2, 15)
+	c.convert(metrics, now, nil)
+	m := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	require.Equal(t, pmetric.MetricAggregationTemporalityDelta, m.Sum().AggregationTemporality())
+	require.Equal(t, 5.0, m.Sum().DataPoints().At(0).DoubleVal())
+	require.Equal(t, pcommon.Timestamp(1), m.Sum().DataPoints().At(0).StartTimestamp())
+
+	// Counter reset
+	metrics = newSum(3, 3)
+	c.convert(metrics, now, nil)
+	m = metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	require.Equal(t, 3.0, m.Sum().DataPoints().At(0).DoubleVal())
+
+	// Stale series are forgotten
+	c.convert(pmetric.NewMetrics(), now.Add(2*deltaStaleness), nil)
+	require.Empty(t, c.series)
+}
+
+func TestStartTimeTracker(t *testing.T) {
+	newSum := func(ts int64, value int64, monotonic bool) pmetric.Metrics {
+		metrics := pmetric.NewMetrics()
+		m := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		m.SetName("requests")
+		m.SetDataType(pmetric.MetricDataTypeSum)
+		m.Sum().SetAggregationTemporality(pmetric.MetricAggregationTemporalityCumulative)
+		m.Sum().SetIsMonotonic(monotonic)
+		dp := m.Sum().DataPoints().AppendEmpty()
+		dp.Attributes().InsertString("host", "a")
+		dp.SetTimestamp(pcommon.Timestamp(ts))
+		dp.SetIntVal(value)
+		return metrics
+	}
+	startOf := func(metrics pmetric.Metrics) pcommon.Timestamp {
+		return metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints().At(0).StartTimestamp()
+	}
+	now := time.Now()
+	tracker := newStartTimeTracker()
+
+	metrics := newSum(1, 10, true)
+	tracker.track(metrics, now)
+	require.Equal(t, pcommon.Timestamp(1), startOf(metrics))
+
+	metrics = newSum(2, 15, true)
+	tracker.track(metrics, now)
+	require.Equal(t, pcommon.Timestamp(1), startOf(metrics))
+
+	// Counter reset
+	metrics = newSum(3, 3, true)
+	tracker.track(metrics, now)
+	require.Equal(t, pcommon.Timestamp(2), startOf(metrics))
+
+	// Non-monotonic sums may decrease
+	metrics = newSum(4, 2, false)
+	tracker.track(metrics, now)
+	require.Equal(t, pcommon.Timestamp(2), startOf(metrics))
+
+	// Stale series are forgotten
+	tracker.track(pmetric.NewMetrics(), now.Add(2*deltaStaleness))
+	require.Empty(t, tracker.series)
+}
+
+func TestDeltaConverterHistogram(t *testing.T) {
+	newHistogram := func(count uint64, sum float64, buckets []uint64) pmetric.Metrics {
+		metrics := pmetric.NewMetrics()
+		m := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		m.SetName("latency")
+		m.SetDataType(pmetric.MetricDataTypeHistogram)
+		m.Histogram().SetAggregationTemporality(pmetric.MetricAggregationTemporalityCumulative)
+		dp := m.Histogram().DataPoints().AppendEmpty()
+		dp.SetCount(count)
+		dp.SetSum(sum)
+		dp.SetExplicitBounds(pcommon.NewImmutableFloat64Slice([]float64{1}))
+		dp.SetBucketCounts(pcommon.NewImmutableUInt64Slice(buckets))
+		return metrics
+	}
+	now := time.Now()
+	c := newDeltaConverter()
+
+	c.convert(newHistogram(3, 2.5, []uint64{2, 1}), now, nil)
+	metrics := newHistogram(5, 4, []uint64{3, 2})
+	c.convert(metrics, now, nil)
+	dp := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Histogram().DataPoints().At(0)
+	require.Equal(t, uint64(2), dp.Count())
+	require.Equal(t, 1.5, dp.Sum())
+	require.Equal(t, []uint64{1, 1}, dp.BucketCounts().AsRaw())
+}
+
+func TestOpenTelemetryTemporality(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.AggregationTemporality = temporalityCumulative
+	plugin.Temporality = map[string]string{"requests*": temporalityDelta, "requests_total": temporalityCumulative}
+	require.NoError(t, plugin.compileTemporality())
+	plugin.deltaConverter = newDeltaConverter()
+	plugin.startTimes = newStartTimeTracker()
+
+	now := time.Now()
+	for i, value := range []int64{10, 15} {
+		ts := now.Add(time.Duration(i) * time.Second)
+		require.NoError(t, plugin.Write([]telegraf.Metric{
+			testutil.MustMetric("requests", map[string]string{}, map[string]interface{}{"counter": value}, ts, telegraf.Counter),
+			testutil.MustMetric("requests_total", map[string]string{}, map[string]interface{}{"counter": value}, ts, telegraf.Counter),
+		}))
+	}
+
+	got := make(map[string]pmetric.Sum)
+	metrics := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		got[metrics.At(i).Name()] = metrics.At(i).Sum()
+	}
+	require.Len(t, got, 2)
+	require.Equal(t, pmetric.MetricAggregationTemporalityDelta, got["requests"].AggregationTemporality())
+	require.Equal(t, int64(5), got["requests"].DataPoints().At(0).IntVal())
+	// The longer pattern wins.
+	require.Equal(t, pmetric.MetricAggregationTemporalityCumulative, got["requests_total"].AggregationTemporality())
+	require.Equal(t, int64(15), got["requests_total"].DataPoints().At(0).IntVal())
+	require.Equal(t, pcommon.NewTimestampFromTime(now), got["requests_total"].DataPoints().At(0).StartTimestamp())
+}
+
+func TestConverterConcurrentBatches(t *testing.T) {
+	converter, err := influx2otel.NewLineProtocolToOtelMetrics(common.NoopLogger{})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			batch := converter.NewBatch()
+			for j := 0; j < 100; j++ {
+				tags := map[string]string{"worker": strconv.Itoa(i), "series": strconv.Itoa(j)}
+				fields := map[string]interface{}{"gauge": float64(j)}
+				assert.NoError(t, batch.AddPoint("cpu", tags, fields, time.Now(), common.InfluxMetricValueTypeGauge))
+			}
+			assert.Equal(t, 100, batch.GetMetrics().DataPointCount())
+		}(i)
+	}
+	wg.Wait()
+}
+
+func benchmarkMetrics(n int) []telegraf.Metric {
+	now := time.Now()
+	metrics := make([]telegraf.Metric, 0, n)
+	for i := 0; i < n; i++ {
+		metrics = append(metrics, testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "a", "cpu": strconv.Itoa(i)},
+			map[string]interface{}{"usage_user": 0.5, "usage_system": 0.25},
+			now,
+			telegraf.Gauge,
+		))
+	}
+	return metrics
+}
+
+func convertBatch(converter *influx2otel.LineProtocolToOtelMetrics, metrics []telegraf.Metric) error {
+	batch := converter.NewBatch()
+	for _, m := range metrics {
+		if err := batch.AddPoint(m.Name(), m.Tags(), m.Fields(), m.Time(), common.InfluxMetricValueTypeGauge); err != nil {
+			return err
+		}
+	}
+	batch.GetMetrics()
+	return nil
+}
+
+func BenchmarkConvert(b *testing.B) {
+	converter, err := influx2otel.NewLineProtocolToOtelMetrics(common.NoopLogger{})
+	require.NoError(b, err)
+	metrics := benchmarkMetrics(1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := convertBatch(converter, metrics); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkConvertParallel shares the converter between goroutines. As the
+// converter holds no lock, the throughput grows with the number of CPUs
+// compared to BenchmarkConvert.
+func BenchmarkConvertParallel(b *testing.B) {
+	converter, err := influx2otel.NewLineProtocolToOtelMetrics(common.NoopLogger{})
+	require.NoError(b, err)
+	metrics := benchmarkMetrics(1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := convertBatch(converter, metrics); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func TestConvertToExponentialHistograms(t *testing.T) {
+	metrics := pmetric.NewMetrics()
+	m := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("latency")
+	m.SetDataType(pmetric.MetricDataTypeHistogram)
+	m.Histogram().SetAggregationTemporality(pmetric.MetricAggregationTemporalityCumulative)
+	dp := m.Histogram().DataPoints().AppendEmpty()
+	dp.Attributes().InsertString("host", "a")
+	dp.SetTimestamp(pcommon.Timestamp(1622848686000000000))
+	dp.SetCount(10)
+	dp.SetSum(27)
+	dp.SetMin(0)
+	dp.SetMax(9)
+	dp.SetExplicitBounds(pcommon.NewImmutableFloat64Slice([]float64{0, 1, 2, 4}))
+	// Cumulative counts of 1, 2, 3, 0 and 4 values, as the converter creates
+	// them from the fields.
+	dp.SetBucketCounts(pcommon.NewImmutableUInt64Slice([]uint64{1, 3, 6, 6, 10}))
+
+	convertToExponentialHistograms(metrics)
+
+	require.Equal(t, pmetric.MetricDataTypeExponentialHistogram, m.DataType())
+	require.Equal(t, pmetric.MetricAggregationTemporalityCumulative, m.ExponentialHistogram().AggregationTemporality())
+	edp := m.ExponentialHistogram().DataPoints().At(0)
+	require.Equal(t, uint64(10), edp.Count())
+	require.Equal(t, 27.0, edp.Sum())
+	require.Equal(t, 0.0, edp.Min())
+	require.Equal(t, 9.0, edp.Max())
+	require.Equal(t, uint64(1), edp.ZeroCount())
+	require.Equal(t, pcommon.Timestamp(1622848686000000000), edp.Timestamp())
+	v, found := edp.Attributes().Get("host")
+	require.True(t, found)
+	require.Equal(t, "a", v.StringVal())
+
+	// 1, 2 and just above 4 with a resolution fitting 160 buckets
+	scale := edp.Scale()
+	require.Equal(t, int32(6), scale)
+	require.Equal(t, exponentialIndex(1, scale), edp.Positive().Offset())
+	buckets := edp.Positive().BucketCounts().AsRaw()
+	require.Len(t, buckets, int(exponentialIndex(math.Nextafter(4, 5), scale)-exponentialIndex(1, scale)+1))
+	require.Equal(t, uint64(2), buckets[0])
+	require.Equal(t, uint64(3), buckets[exponentialIndex(2, scale)-edp.Positive().Offset()])
+	require.Equal(t, uint64(4), buckets[len(buckets)-1])
+	require.Zero(t, edp.Negative().BucketCounts().Len())
+}
+
+func TestOpenTelemetryExponentialPrometheusHistogram(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.HistogramType = histogramTypeExponential
+
+	input := testutil.MustMetric(
+		"http_request_duration_seconds",
+		map[string]string{},
+		map[string]interface{}{
+			"0.1":   2.0,
+			"0.5":   5.0,
+			"1":     8.0,
+			"+Inf":  10.0,
+			"count": 10.0,
+			"sum":   4.2,
+		},
+		time.Unix(0, 1622848686000000000),
+		telegraf.Histogram,
+	)
+	require.NoError(t, plugin.Write([]telegraf.Metric{input}))
+
+	got := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	require.Equal(t, pmetric.MetricDataTypeExponentialHistogram, got.DataType())
+	dp := got.ExponentialHistogram().DataPoints().At(0)
+	require.Equal(t, uint64(10), dp.Count())
+
+	// 2, 3 and 3 values up to the bounds and 2 above the highest one.
+	buckets := dp.Positive().BucketCounts().AsRaw()
+	offset, scale := dp.Positive().Offset(), dp.Scale()
+	var total uint64
+	for _, count := range buckets {
+		total += count
+	}
+	require.Equal(t, uint64(10), total+dp.ZeroCount())
+	require.Equal(t, uint64(2), buckets[exponentialIndex(0.1, scale)-offset])
+	require.Equal(t, uint64(3), buckets[exponentialIndex(0.5, scale)-offset])
+	require.Equal(t, uint64(3), buckets[exponentialIndex(1, scale)-offset])
+	require.Equal(t, uint64(2), buckets[len(buckets)-1])
+	require.Equal(t, exponentialIndex(math.Nextafter(1, 2), scale), offset+int32(len(buckets))-1)
+}
+
+func TestOpenTelemetryStats(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.ServiceAddress = "stats-test"
+	plugin.registerStats()
+	plugin.MaxRetries = 1
+	plugin.RetryInitialInterval = config.Duration(time.Millisecond)
+	plugin.RetryMaxInterval = config.Duration(time.Millisecond)
+
+	m.FailNext(status.Error(codes.Unavailable, "unavailable"))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	m.FailNext(status.Error(codes.InvalidArgument, "invalid"))
+	require.Error(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+
+	require.Equal(t, int64(1), plugin.stats.metricsSent.Get())
+	require.Equal(t, int64(1), plugin.stats.batchesSent.Get())
+	require.Equal(t, int64(1), plugin.stats.exportErrors.Get())
+	require.Equal(t, int64(1), plugin.stats.retries.Get())
+	require.Greater(t, plugin.stats.exportDuration.Get(), int64(0))
+	// Every attempt is timed.
+	latency := plugin.stats.exportLatency
+	require.Equal(t, int64(3), latency.buckets[len(latency.buckets)-1].Get())
+	require.Greater(t, latency.sum.Get(), int64(0))
+	// So is every result.
+	results := make(map[string]int64)
+	for _, count := range plugin.stats.exportResults.counts {
+		if count.Get() > 0 {
+			results[count.FieldName()] = count.Get()
+		}
+	}
+	require.Equal(t, map[string]int64{"ok": 1, "unavailable": 1, "invalid_argument": 1}, results)
+}
+
+func TestExportResultCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected codes.Code
+	}{
+		{name: "success", expected: codes.OK},
+		{name: "grpc status", err: status.Error(codes.ResourceExhausted, "slow down"), expected: codes.ResourceExhausted},
+		{name: "undefined grpc status", err: status.Error(codes.Code(42), "custom"), expected: codes.Unknown},
+		{name: "http bad request", err: &httpStatusError{StatusCode: http.StatusBadRequest}, expected: codes.InvalidArgument},
+		{name: "http service unavailable", err: &httpStatusError{StatusCode: http.StatusServiceUnavailable}, expected: codes.Unavailable},
+		{name: "http unmapped server error", err: &httpStatusError{StatusCode: http.StatusHTTPVersionNotSupported}, expected: codes.Internal},
+		{name: "http unmapped client error", err: &httpStatusError{StatusCode: http.StatusTeapot}, expected: codes.Unknown},
+		{name: "deadline", err: fmt.Errorf("posting failed: %w", context.DeadlineExceeded), expected: codes.DeadlineExceeded},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, expected: codes.Unavailable},
+		{name: "other", err: errors.New("failed"), expected: codes.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, exportResultCode(tt.err))
+		})
+	}
+	require.Equal(t, "ok", codeFieldName(codes.OK))
+	require.Equal(t, "deadline_exceeded", codeFieldName(codes.DeadlineExceeded))
+	require.Equal(t, "unauthenticated", codeFieldName(codes.Unauthenticated))
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	bounds := []config.Duration{config.Duration(10 * time.Millisecond), config.Duration(time.Second)}
+	h := newLatencyHistogram("opentelemetry_latency_test", bounds, map[string]string{})
+	h.observe(5 * time.Millisecond)
+	h.observe(10 * time.Millisecond)
+	h.observe(500 * time.Millisecond)
+	h.observe(2 * time.Second)
+
+	counts := make([]int64, 0, len(h.buckets))
+	for _, bucket := range h.buckets {
+		counts = append(counts, bucket.Get())
+	}
+	require.Equal(t, []int64{2, 3, 4}, counts)
+	require.Equal(t, "le_10ms", h.buckets[0].FieldName())
+	require.Equal(t, "le_inf", h.buckets[2].FieldName())
+	require.Equal(t, (2515 * time.Millisecond).Nanoseconds(), h.sum.Get())
+}
+
+func TestOpenTelemetryHTTPConnectDuration(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Greater(t, plugin.stats.connectDuration.Get(), int64(0))
+}
+
+func TestOpenTelemetryNamespace(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.Namespace = "agent"
+	plugin.NamespaceSeparator = "_"
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	got := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	require.Equal(t, "agent_cpu_temp", got.Name())
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "cpu_usage_idle", expected: "cpu_usage_idle"},
+		{name: "disk.io/read bytes", expected: "disk_io_read_bytes"},
+		{name: "1m_load", expected: "_1m_load"},
+		{name: "ns:metric", expected: "ns:metric"},
+		{name: "température", expected: "temp_rature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, sanitizeName(tt.name))
+		})
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	tests := []struct {
+		name      string
+		dataType  pmetric.MetricDataType
+		monotonic bool
+		unit      string
+		expected  string
+	}{
+		{name: "cpu.usage_idle", dataType: pmetric.MetricDataTypeGauge, expected: "cpu_usage_idle"},
+		{name: "disk..io__read", dataType: pmetric.MetricDataTypeGauge, expected: "disk_io_read"},
+		{name: "http.requests", dataType: pmetric.MetricDataTypeSum, monotonic: true, expected: "http_requests_total"},
+		{name: "http_requests_total", dataType: pmetric.MetricDataTypeSum, monotonic: true, expected: "http_requests_total"},
+		{name: "queue.total.depth", dataType: pmetric.MetricDataTypeSum, expected: "queue_total_depth"},
+		{name: "http.duration", dataType: pmetric.MetricDataTypeHistogram, unit: "s", expected: "http_duration_seconds"},
+		{name: "net.received", dataType: pmetric.MetricDataTypeSum, monotonic: true, unit: "By", expected: "net_received_bytes_total"},
+		{name: "net_received_bytes_total", dataType: pmetric.MetricDataTypeSum, monotonic: true, unit: "By", expected: "net_received_bytes_total"},
+		{name: "net.rate", dataType: pmetric.MetricDataTypeGauge, unit: "By/s", expected: "net_rate_bytes_per_second"},
+		{name: "requests", dataType: pmetric.MetricDataTypeGauge, unit: "{request}/s", expected: "requests"},
+		{name: "cpu.utilization", dataType: pmetric.MetricDataTypeGauge, unit: "1", expected: "cpu_utilization_ratio"},
+		{name: "cpu.utilization", dataType: pmetric.MetricDataTypeSum, unit: "1", expected: "cpu_utilization"},
+		{name: "1m.load", dataType: pmetric.MetricDataTypeGauge, expected: "_1m_load"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.unit, func(t *testing.T) {
+			metric := pmetric.NewMetric()
+			metric.SetName(tt.name)
+			metric.SetUnit(tt.unit)
+			metric.SetDataType(tt.dataType)
+			if tt.dataType == pmetric.MetricDataTypeSum {
+				metric.Sum().SetIsMonotonic(tt.monotonic)
+			}
+			require.Equal(t, tt.expected, prometheusName(metric))
+		})
+	}
+}
+
+func TestPrometheusLabels(t *testing.T) {
+	attributes := pcommon.NewMap()
+	attributes.InsertString("host.name", "a")
+	attributes.InsertString("host_name", "b")
+	attributes.InsertString("5xx", "c")
+	attributes.InsertString("_private", "d")
+	attributes.InsertString("__reserved", "e")
+	attributes.InsertInt("http.status_code", 200)
+	prometheusLabels(attributes)
+
+	require.Equal(t, map[string]interface{}{
+		"host_name":        "a;b",
+		"key_5xx":          "c",
+		"key_private":      "d",
+		"__reserved":       "e",
+		"http_status_code": "200",
+	}, attributes.AsRaw())
+}
+
+func TestOpenTelemetryPrometheusCompatibleNames(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.PrometheusCompatibleNames = true
+	plugin.Units = map[string]string{"http.received": "By"}
+	require.NoError(t, plugin.compileUnits())
+
+	ts := time.Unix(0, 1622848686000000000)
+	input := []telegraf.Metric{
+		testutil.MustMetric("http", map[string]string{"http.method": "GET"}, map[string]interface{}{"received": int64(512)}, ts, telegraf.Counter),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	got := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	require.Equal(t, "http_received_bytes_total", got.Name())
+	require.Equal(t, "By", got.Unit())
+	require.Equal(t, map[string]interface{}{"http_method": "GET"}, got.Sum().DataPoints().At(0).Attributes().AsRaw())
+}
+
+func TestHandleNonFinite(t *testing.T) {
+	tests := []struct {
+		handling string
+		expected map[string]interface{}
+	}{
+		{
+			handling: nonFiniteDrop,
+			expected: map[string]interface{}{"value": 1.0, "count": int64(2)},
+		},
+		{
+			handling: nonFiniteZero,
+			expected: map[string]interface{}{"value": 1.0, "count": int64(2), "nan": 0.0, "inf": 0.0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.handling, func(t *testing.T) {
+			plugin := &OpenTelemetry{NonFiniteHandling: tt.handling, Log: testutil.Logger{}}
+			fields := map[string]interface{}{
+				"value": 1.0,
+				"count": int64(2),
+				"nan":   math.NaN(),
+				"inf":   math.Inf(-1),
+			}
+			require.Equal(t, tt.expected, plugin.handleNonFinite("test", fields))
+		})
+	}
+}
+
+func TestOpenTelemetryDropNonFinite(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.NonFiniteHandling = nonFiniteDrop
+
+	input := testutil.MustMetric(
+		"cpu_temp",
+		map[string]string{},
+		map[string]interface{}{"gauge": math.NaN()},
+		time.Unix(0, 1622848686000000000),
+		telegraf.Gauge)
+	require.NoError(t, plugin.Write([]telegraf.Metric{input, newTestMetric()}))
+	require.Equal(t, 1, m.GotMetrics().DataPointCount())
+}
+
+func TestOpenTelemetryUntypedAs(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.UntypedAs = untypedAsSum
+
+	input := testutil.MustMetric(
+		"requests",
+		map[string]string{},
+		map[string]interface{}{"value": int64(42)},
+		time.Unix(0, 1622848686000000000))
+	require.NoError(t, plugin.Write([]telegraf.Metric{input}))
+	got := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	require.Equal(t, "requests_value", got.Name())
+	require.Equal(t, pmetric.MetricDataTypeSum, got.DataType())
+}
+
+func TestOpenTelemetrySummary(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	ts := time.Unix(0, 1622848686000000000)
+	input := []telegraf.Metric{
+		testutil.MustMetric(
+			"latency",
+			map[string]string{},
+			map[string]interface{}{"count": int64(10), "sum": 5.5, "p50": 0.4, "p99": 1.2, "p999": int64(2)},
+			ts,
+			telegraf.Summary),
+		// Prometheus metric version 2
+		testutil.MustMetric(
+			"prometheus",
+			map[string]string{"quantile": "0.5"},
+			map[string]interface{}{"rpc_seconds": 0.1},
+			ts,
+			telegraf.Summary),
+		testutil.MustMetric(
+			"prometheus",
+			map[string]string{"quantile": "0.99"},
+			map[string]interface{}{"rpc_seconds": 0.3},
+			ts,
+			telegraf.Summary),
+		testutil.MustMetric(
+			"prometheus",
+			map[string]string{},
+			map[string]interface{}{"rpc_seconds_count": 3.0, "rpc_seconds_sum": 0.5},
+			ts,
+			telegraf.Summary),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	got := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	require.Equal(t, 2, got.Len())
+	expected := map[string]struct {
+		count     uint64
+		sum       float64
+		quantiles map[float64]float64
+	}{
+		"latency":     {10, 5.5, map[float64]float64{0.5: 0.4, 0.99: 1.2, 0.999: 2}},
+		"rpc_seconds": {3, 0.5, map[float64]float64{0.5: 0.1, 0.99: 0.3}},
+	}
+	for i := 0; i < got.Len(); i++ {
+		metric := got.At(i)
+		e, ok := expected[metric.Name()]
+		require.True(t, ok, metric.Name())
+		require.Equal(t, pmetric.MetricDataTypeSummary, metric.DataType())
+		dp := metric.Summary().DataPoints().At(0)
+		require.Equal(t, e.count, dp.Count())
+		require.Equal(t, e.sum, dp.Sum())
+		quantiles := make(map[float64]float64)
+		for j := 0; j < dp.QuantileValues().Len(); j++ {
+			quantiles[dp.QuantileValues().At(j).Quantile()] = dp.QuantileValues().At(j).Value()
+		}
+		require.Equal(t, e.quantiles, quantiles)
+	}
+}
+
+func TestPercentileToQuantile(t *testing.T) {
+	tests := []struct {
+		percentile string
+		quantile   float64
+		ok         bool
+	}{
+		{percentile: "50", quantile: 0.5, ok: true},
+		{percentile: "99.9", quantile: 0.999, ok: true},
+		{percentile: "999", quantile: 0.999, ok: true},
+		{percentile: "5", quantile: 0.05, ok: true},
+		{percentile: "100", quantile: 1, ok: true},
+		{percentile: "200.5"},
+		{percentile: "x"},
+	}
+	for _, tt := range tests {
+		quantile, ok := percentileToQuantile(tt.percentile)
+		require.Equal(t, tt.ok, ok, tt.percentile)
+		require.InDelta(t, tt.quantile, quantile, 1e-9, tt.percentile)
+	}
+}
+
+func TestOpenTelemetryMetricTypes(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.MetricTypes = map[string]string{
+		"queue":        "gauge",
+		"queue.served": "sum",
+		"queue.depth*": "non_monotonic_sum",
+	}
+	require.NoError(t, plugin.compileMetricTypes())
+
+	input := testutil.MustMetric(
+		"queue",
+		map[string]string{},
+		map[string]interface{}{"served": int64(42), "depth": int64(3), "capacity": int64(10)},
+		time.Unix(0, 1622848686000000000))
+	require.NoError(t, plugin.Write([]telegraf.Metric{input}))
+
+	got := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	require.Equal(t, 3, got.Len())
+	for i := 0; i < got.Len(); i++ {
+		metric := got.At(i)
+		switch metric.Name() {
+		case "queue_served":
+			require.Equal(t, pmetric.MetricDataTypeSum, metric.DataType())
+			require.True(t, metric.Sum().IsMonotonic())
+		case "queue_depth":
+			require.Equal(t, pmetric.MetricDataTypeSum, metric.DataType())
+			require.False(t, metric.Sum().IsMonotonic())
+		case "queue_capacity":
+			require.Equal(t, pmetric.MetricDataTypeGauge, metric.DataType())
+		default:
+			require.Failf(t, "unexpected metric", metric.Name())
+		}
+	}
+}
+
+func TestOpenTelemetryNonMonotonicCounters(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	f, err := filter.Compile([]string{"mem.free_delta"})
+	require.NoError(t, err)
+	plugin.nonMonotonicCounters = f
+
+	input := testutil.MustMetric(
+		"mem",
+		map[string]string{},
+		map[string]interface{}{"free_delta": int64(-5), "pgfault": int64(42)},
+		time.Unix(0, 1622848686000000000),
+		telegraf.Counter)
+	require.NoError(t, plugin.Write([]telegraf.Metric{input}))
+
+	got := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	monotonic := make(map[string]bool)
+	for i := 0; i < got.Len(); i++ {
+		monotonic[got.At(i).Name()] = got.At(i).Sum().IsMonotonic()
+	}
+	require.Equal(t, map[string]bool{"mem_free_delta": false, "mem_pgfault": true}, monotonic)
+}
+
+func TestOpenTelemetryExcludeTypes(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.IncludeTypes = []string{"gauge", "counter", "summary"}
+	plugin.ExcludeTypes = []string{"summary"}
+	require.NoError(t, plugin.compileTypeFilter())
+
+	ts := time.Unix(0, 1622848686000000000)
+	input := []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"usage": 0.5}, ts, telegraf.Gauge),
+		testutil.MustMetric("requests", map[string]string{}, map[string]interface{}{"counter": int64(5)}, ts, telegraf.Counter),
+		testutil.MustMetric("latency", map[string]string{}, map[string]interface{}{"count": 1.0, "sum": 2.0}, ts, telegraf.Summary),
+		testutil.MustMetric("mem", map[string]string{}, map[string]interface{}{"free": int64(3)}, ts),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	got := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	var names []string
+	for i := 0; i < got.Len(); i++ {
+		names = append(names, got.At(i).Name())
+	}
+	require.ElementsMatch(t, []string{"cpu_usage", "requests"}, names)
+}
+
+func TestOpenTelemetryMaxMetricAge(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.MaxMetricAge = config.Duration(time.Hour)
+
+	now := time.Now()
+	input := []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"usage": 0.5}, now),
+		testutil.MustMetric("mem", map[string]string{}, map[string]interface{}{"free": int64(3)}, now.Add(-2*time.Hour)),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	got := m.GotMetrics()
+	require.Equal(t, 1, got.DataPointCount())
+	require.Equal(t, "cpu_usage", got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Name())
+}
+
+func TestOpenTelemetryMaxFutureDrift(t *testing.T) {
+	now := time.Now()
+	for _, handling := range []string{"drop", "clamp"} {
+		t.Run(handling, func(t *testing.T) {
+			m := newMockOtelService(t)
+			t.Cleanup(m.Cleanup)
+
+			var logger capturingLogger
+			plugin := newTestPlugin(t, m)
+			plugin.Log = &logger
+			plugin.MaxFutureDrift = config.Duration(time.Minute)
+			plugin.FutureDriftHandling = handling
+
+			skewed := testutil.MustMetric("mem", map[string]string{"host": "skewed"}, map[string]interface{}{"free": int64(3)}, now.Add(time.Hour))
+			input := []telegraf.Metric{
+				testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"usage": 0.5}, now),
+				skewed,
+			}
+			require.NoError(t, plugin.Write(input))
+
+			_, warn := logger.messages()
+			require.Len(t, warn, 1)
+			require.Contains(t, warn[0], `Metric "mem" with tags map[host:skewed] is`)
+
+			got := m.GotMetrics()
+			if handling == "drop" {
+				require.Equal(t, 1, got.DataPointCount())
+				return
+			}
+			require.Equal(t, 2, got.DataPointCount())
+			require.Equal(t, now.Add(time.Hour), skewed.Time(), "input metric modified")
+			metrics := got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+			for i := 0; i < metrics.Len(); i++ {
+				require.False(t, metrics.At(i).Gauge().DataPoints().At(0).Timestamp().AsTime().After(time.Now()))
+			}
+		})
+	}
+}
+
+func TestOpenTelemetryMetadataTags(t *testing.T) {
+	var mu sync.Mutex
+	var tenants, keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		tenants = append(tenants, r.Header.Get("X-Tenant"))
+		keys = append(keys, r.Header.Get("X-Key"))
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Headers:        map[string]string{"X-Tenant": "default", "X-Key": "static"},
+		MetadataTags:   map[string]string{"tenant": "x-tenant"},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	now := time.Now()
+	input := []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{"tenant": "a"}, map[string]interface{}{"usage": 0.5}, now),
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"usage": 0.6}, now),
+		testutil.MustMetric("mem", map[string]string{"tenant": "b"}, map[string]interface{}{"free": int64(3)}, now),
+		testutil.MustMetric("mem", map[string]string{"tenant": "a"}, map[string]interface{}{"free": int64(4)}, now),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	// One request per tenant, the metric without the tag with the static headers
+	require.Equal(t, []string{"a", "default", "b"}, tenants)
+	require.Equal(t, []string{"static", "static", "static"}, keys)
+}
+
+func TestOpenTelemetryStale(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.NonFiniteHandling = "stale"
+	plugin.StaleTag = "otel_stale"
+	plugin.SortOutput = true
+
+	now := time.Now()
+	input := []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{"cpu": "0"}, map[string]interface{}{"usage": math.NaN(), "idle": 0.5}, now),
+		testutil.MustMetric("mem", map[string]string{"otel_stale": "true"}, map[string]interface{}{"free": int64(3)}, now),
+		testutil.MustMetric("disk", map[string]string{"otel_stale": "false"}, map[string]interface{}{"free": int64(4)}, now),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	got := make(map[string]pmetric.NumberDataPoint)
+	ms := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < ms.Len(); i++ {
+		got[ms.At(i).Name()] = ms.At(i).Gauge().DataPoints().At(0)
+	}
+	require.Len(t, got, 4)
+	for name, stale := range map[string]bool{"cpu_usage": true, "cpu_idle": false, "mem_free": true, "disk_free": false} {
+		require.Equal(t, stale, isStale(got[name]), name)
+		require.NotContains(t, got[name].Attributes().AsRaw(), staleMarker)
+		require.NotContains(t, got[name].Attributes().AsRaw(), "otel_stale")
+	}
+	require.Equal(t, map[string]interface{}{"cpu": "0"}, got["cpu_usage"].Attributes().AsRaw())
+	require.Equal(t, float64(0), got["cpu_usage"].DoubleVal())
+}
+
+func TestOpenTelemetrySortOutput(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.SortOutput = true
+	plugin.ResourceTags = []string{"host"}
+
+	now := time.Now()
+	input := []telegraf.Metric{
+		testutil.MustMetric("mem", map[string]string{"host": "b"}, map[string]interface{}{"free": int64(1), "used": int64(2)}, now),
+		testutil.MustMetric("cpu", map[string]string{"host": "b", "cpu": "1", "mode": "user"}, map[string]interface{}{"usage": 0.5}, now),
+		testutil.MustMetric("cpu", map[string]string{"host": "b", "mode": "user", "cpu": "0"}, map[string]interface{}{"usage": 0.6}, now),
+		testutil.MustMetric("cpu", map[string]string{"host": "a", "cpu": "0"}, map[string]interface{}{"usage": 0.7}, now),
+	}
+	var payloads []string
+	for _, metrics := range [][]telegraf.Metric{input, {input[3], input[2], input[1], input[0]}} {
+		require.NoError(t, plugin.Write(metrics))
+		payload, err := pmetric.NewJSONMarshaler().MarshalMetrics(m.GotMetrics())
+		require.NoError(t, err)
+		payloads = append(payloads, string(payload))
+	}
+	require.Equal(t, payloads[0], payloads[1])
+
+	rms := m.GotMetrics().ResourceMetrics()
+	require.Equal(t, map[string]interface{}{"host": "a"}, rms.At(0).Resource().Attributes().AsRaw())
+	ms := rms.At(1).ScopeMetrics().At(0).Metrics()
+	require.Equal(t, "cpu_usage", ms.At(0).Name())
+	require.Equal(t, "mem_free", ms.At(1).Name())
+	require.Equal(t, "mem_used", ms.At(2).Name())
+	dp := ms.At(0).Gauge().DataPoints().At(0)
+	require.Equal(t, 0.6, dp.DoubleVal())
+	var attributeKeys []string
+	dp.Attributes().Range(func(k string, _ pcommon.Value) bool {
+		attributeKeys = append(attributeKeys, k)
+		return true
+	})
+	require.Equal(t, []string{"cpu", "mode"}, attributeKeys)
+}
+
+func TestOpenTelemetryConcurrentExports(t *testing.T) {
+	var active, peak, requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress:          ts.URL,
+		Protocol:                "http/protobuf",
+		MaxDatapointsPerRequest: 1,
+		MaxConcurrentExports:    2,
+		Log:                     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	now := time.Now()
+	var input []telegraf.Metric
+	for i := 0; i < 4; i++ {
+		input = append(input, testutil.MustMetric("cpu", map[string]string{"cpu": strconv.Itoa(i)}, map[string]interface{}{"usage": 0.5}, now))
+	}
+	err := plugin.Write(input)
+	require.ErrorContains(t, err, "exporting 1 of 4 requests failed")
+	require.Equal(t, int32(4), atomic.LoadInt32(&requests))
+	require.Equal(t, int32(2), atomic.LoadInt32(&peak))
+}
+
+func TestOpenTelemetryPreferredBatchSize(t *testing.T) {
+	var mu sync.Mutex
+	var counts []int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		request := pmetricotlp.NewRequest()
+		require.NoError(t, request.UnmarshalProto(body))
+		mu.Lock()
+		counts = append(counts, request.Metrics().DataPointCount())
+		mu.Unlock()
+	}))
+	defer ts.Close()
+	requestCounts := func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), counts...)
+	}
+
+	plugin := &OpenTelemetry{
+		ServiceAddress:     ts.URL,
+		Protocol:           "http/protobuf",
+		Compression:        "none",
+		PreferredBatchSize: 3,
+		MaxBatchHold:       config.Duration(time.Hour),
+		Log:                testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+
+	now := time.Now()
+	metrics := func(n int) []telegraf.Metric {
+		var input []telegraf.Metric
+		for i := 0; i < n; i++ {
+			input = append(input, testutil.MustMetric("cpu", map[string]string{"cpu": strconv.Itoa(i)}, map[string]interface{}{"usage": 0.5}, now))
+		}
+		return input
+	}
+
+	// Under-sized writes are held.
+	require.NoError(t, plugin.Write(metrics(2)))
+	require.Empty(t, requestCounts())
+
+	// Full batches are exported, the remainder is held.
+	require.NoError(t, plugin.Write(metrics(5)))
+	require.Equal(t, []int{3, 3}, requestCounts())
+
+	// Closing flushes the remainder.
+	require.NoError(t, plugin.Close())
+	require.Equal(t, []int{3, 3, 1}, requestCounts())
+
+	// The remainder is flushed once the hold time has passed.
+	plugin.MaxBatchHold = config.Duration(10 * time.Millisecond)
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+	require.NoError(t, plugin.Write(metrics(1)))
+	require.Eventually(t, func() bool {
+		return len(requestCounts()) == 4
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []int{3, 3, 1, 1}, requestCounts())
+
+	// So is the remainder of a single write exceeding the batch size.
+	require.NoError(t, plugin.Write(metrics(4)))
+	require.Eventually(t, func() bool {
+		return len(requestCounts()) == 6
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []int{3, 3, 1, 1, 3, 1}, requestCounts())
+}
+
+func TestOpenTelemetryHeartbeat(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress:    m.Address(),
+		HeartbeatInterval: config.Duration(50 * time.Millisecond),
+		Headers:           map[string]string{"test": "header1"},
+		Attributes:        map[string]string{"attr-key": "attr-val"},
+		Log:               testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.Eventually(t, func() bool {
+		return m.Requests() > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	rm := m.GotMetrics().ResourceMetrics().At(0)
+	attr, ok := rm.Resource().Attributes().Get("attr-key")
+	require.True(t, ok)
+	require.Equal(t, "attr-val", attr.StringVal())
+	metric := rm.ScopeMetrics().At(0).Metrics().At(0)
+	require.Equal(t, "telegraf_heartbeat", metric.Name())
+	require.Equal(t, pmetric.MetricDataTypeGauge, metric.DataType())
+	require.Equal(t, int64(1), metric.Gauge().DataPoints().At(0).IntVal())
+
+	// Exported metrics postpone the heartbeat.
+	plugin.stopHeartbeat()
+	plugin.HeartbeatInterval = config.Duration(200 * time.Millisecond)
+	plugin.startHeartbeat()
+	requests := m.Requests()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.Equal(t, requests+5, m.Requests())
+	require.Equal(t, "cpu_temp", m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Name())
+}
+
+func TestOpenTelemetryHealthCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() { assert.NoError(t, server.Serve(listener)) }()
+	defer server.Stop()
+
+	var logger capturingLogger
+	plugin := &OpenTelemetry{
+		ServiceAddress:      listener.Addr().String(),
+		HealthCheckInterval: config.Duration(10 * time.Millisecond),
+		Log:                 &logger,
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.Eventually(t, func() bool {
+		_, warn := logger.messages()
+		return len(warn) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	_, warn := logger.messages()
+	require.Equal(t, []string{fmt.Sprintf("Collector %q reports NOT_SERVING", listener.Addr().String())}, warn)
+	require.Equal(t, int64(0), plugin.stats.collectorHealthy.Get())
+
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	require.Eventually(t, func() bool {
+		info, _ := logger.messages()
+		for _, msg := range info {
+			if strings.Contains(msg, "is serving again") {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, int64(1), plugin.stats.collectorHealthy.Get())
+}
+
+func TestOpenTelemetryHealthCheckUnimplemented(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	var logger capturingLogger
+	plugin := newTestPlugin(t, m)
+	plugin.Log = &logger
+	plugin.HealthCheckInterval = config.Duration(10 * time.Millisecond)
+	plugin.startHealthCheck()
+
+	// The probes stop on their own
+	plugin.healthCheck.wg.Wait()
+	info, warn := logger.messages()
+	require.Empty(t, warn)
+	require.Len(t, info, 1)
+	require.Contains(t, info[0], "does not implement the gRPC health service")
+	plugin.stopHealthCheck()
+}
+
+func TestOpenTelemetryBaggage(t *testing.T) {
+	var mu sync.Mutex
+	var baggage []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		baggage = append(baggage, r.Header.Get("Baggage"))
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Baggage:        map[string]string{"env": "prod", "team": "data infra"},
+		BaggageTags:    []string{"request_id"},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	now := time.Now()
+	input := []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{"request_id": "a,b"}, map[string]interface{}{"usage": 0.5}, now),
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"usage": 0.6}, now),
+	}
+	require.NoError(t, plugin.Write(input))
+	require.Equal(t, []string{
+		"env=prod,request_id=a%2Cb,team=data%20infra",
+		"env=prod,team=data%20infra",
+	}, baggage)
+}
+
+func TestEncodeBaggage(t *testing.T) {
+	require.Equal(t, "a=1,b=x%3By%25z%22,c=", encodeBaggage(map[string]string{"c": "", "b": `x;y%z"`, "a": "1"}))
+	require.True(t, isBaggageKey("user.id"))
+	require.False(t, isBaggageKey("user id"))
+	require.False(t, isBaggageKey(""))
+}
+
+func TestDiskQueueHeaders(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openDiskQueue(dir, 1024)
+	require.NoError(t, err)
+
+	data, err := pmetricotlp.NewRequest().MarshalProto()
+	require.NoError(t, err)
+	_, err = q.push(signalMetrics, data, map[string]string{"x-tenant": "a"})
+	require.NoError(t, err)
+	_, err = q.push(signalMetrics, data, nil)
+	require.NoError(t, err)
+
+	q, err = openDiskQueue(dir, 1024)
+	require.NoError(t, err)
+	require.Equal(t, 2, q.len())
+	f, ok := q.oldest()
+	require.True(t, ok)
+	call, err := q.load(f)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"x-tenant": "a"}, call.headers)
+
+	q.remove(f)
+	_, err = os.Stat(filepath.Join(dir, f.name+diskQueueHeadersSuffix))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	f, ok = q.oldest()
+	require.True(t, ok)
+	call, err = q.load(f)
+	require.NoError(t, err)
+	require.Nil(t, call.headers)
+}
+
+func TestOpenTelemetryAttributeRules(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.Attributes = map[string]string{"db.system": "none", "env": "prod"}
+	plugin.AttributeRules = []AttributeRule{
+		{NameFilter: []string{"postgresql*", "mysql"}, Attributes: map[string]string{"db.system": "postgresql"}},
+		{NameFilter: []string{"mysql"}, Attributes: map[string]string{"db.system": "mysql", "db.port": "3306"}},
+	}
+	require.NoError(t, plugin.compileAttributeRules())
+
+	now := time.Now()
+	input := []telegraf.Metric{
+		testutil.MustMetric("postgresql_stat", map[string]string{"db.system": "tag"}, map[string]interface{}{"rows": int64(1)}, now),
+		testutil.MustMetric("mysql", map[string]string{}, map[string]interface{}{"queries": int64(2)}, now),
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"usage": 0.5}, now),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	got := make(map[string]map[string]interface{})
+	rms := m.GotMetrics().ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		name := rm.ScopeMetrics().At(0).Metrics().At(0).Name()
+		got[name] = rm.Resource().Attributes().AsRaw()
+	}
+	require.Equal(t, map[string]map[string]interface{}{
+		"postgresql_stat_rows": {"db.system": "postgresql", "env": "prod"},
+		"mysql_queries":        {"db.system": "mysql", "db.port": int64(3306), "env": "prod"},
+		"cpu_usage":            {"db.system": "none", "env": "prod"},
+	}, got)
+
+	// The tag of the metric is kept as data point attribute
+	dp := rms.At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0)
+	require.Equal(t, map[string]interface{}{"db.system": "tag"}, dp.Attributes().AsRaw())
+}
+
+func TestOpenTelemetryStringOnlyMetrics(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.StringFields = "attribute"
+	plugin.StringOnlyMetrics = "info"
+	require.NoError(t, plugin.compileFieldFilter())
+
+	input := []telegraf.Metric{
+		testutil.MustMetric("service", map[string]string{}, map[string]interface{}{"status": "running"}, time.Now(), telegraf.Counter),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	got := m.GotMetrics()
+	require.Equal(t, 1, got.DataPointCount())
+	metric := got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	require.Equal(t, "service_info", metric.Name())
+	require.Equal(t, pmetric.MetricDataTypeGauge, metric.DataType())
+	dp := metric.Gauge().DataPoints().At(0)
+	require.Equal(t, int64(1), dp.IntVal())
+	require.Equal(t, map[string]interface{}{"status": "running"}, dp.Attributes().AsRaw())
+}
+
+func TestOpenTelemetryNonNumericFields(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	require.NoError(t, plugin.compileFieldFilter())
+
+	input := []telegraf.Metric{
+		testutil.MustMetric("disk", map[string]string{}, map[string]interface{}{"free": int64(3), "healthy": true}, time.Now(), telegraf.Counter),
+		testutil.MustMetric("link", map[string]string{}, map[string]interface{}{"up": false}, time.Now(), telegraf.Counter),
+		testutil.MustMetric("service", map[string]string{}, map[string]interface{}{"status": "running"}, time.Now()),
+	}
+	require.NoError(t, plugin.Write(input))
+	got := m.GotMetrics()
+	require.Equal(t, 1, got.DataPointCount())
+	require.Equal(t, "disk_free", got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Name())
+
+	plugin.BooleanFields = "gauge"
+	plugin.NonNumericMetrics = "presence"
+	require.NoError(t, plugin.compileFieldFilter())
+	require.NoError(t, plugin.Write(input))
+
+	got = m.GotMetrics()
+	require.Equal(t, 4, got.DataPointCount())
+	values := make(map[string]float64)
+	metrics := got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		if metric.Name() == "disk_free" {
+			require.Equal(t, pmetric.MetricDataTypeSum, metric.DataType())
+			require.Equal(t, int64(3), metric.Sum().DataPoints().At(0).IntVal())
+			continue
+		}
+		require.Equal(t, pmetric.MetricDataTypeGauge, metric.DataType())
+		dp := metric.Gauge().DataPoints().At(0)
+		require.Empty(t, dp.Attributes().AsRaw())
+		if metric.Name() == "service_info" {
+			require.Equal(t, int64(1), dp.IntVal())
+			continue
+		}
+		values[metric.Name()] = dp.DoubleVal()
+	}
+	require.Equal(t, map[string]float64{"disk_healthy": 1, "link_up": 0}, values)
+}
+
+func TestOpenTelemetryBoolFieldsAsGauge(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	up, down := 100.0, -1.0
+	plugin := newTestPlugin(t, m)
+	plugin.BoolFieldsAsGauge = true
+	plugin.BoolTrueValue = &up
+	plugin.BoolFalseValue = &down
+	require.NoError(t, plugin.compileFieldFilter())
+	require.Equal(t, "gauge", plugin.BooleanFields)
+
+	input := []telegraf.Metric{
+		testutil.MustMetric("check", map[string]string{}, map[string]interface{}{"up": true}, time.Now(), telegraf.Counter),
+		testutil.MustMetric("probe", map[string]string{}, map[string]interface{}{"up": false}, time.Now()),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	got := m.GotMetrics()
+	require.Equal(t, 2, got.DataPointCount())
+	values := make(map[string]float64)
+	metrics := got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		require.Equal(t, pmetric.MetricDataTypeGauge, metrics.At(i).DataType())
+		values[metrics.At(i).Name()] = metrics.At(i).Gauge().DataPoints().At(0).DoubleVal()
+	}
+	require.Equal(t, map[string]float64{"check_up": 100, "probe_up": -1}, values)
+}
+
+func TestOpenTelemetryFieldSelection(t *testing.T) {
+	for _, stringFields := range []string{"drop", "attribute"} {
+		t.Run(stringFields, func(t *testing.T) {
+			m := newMockOtelService(t)
+			t.Cleanup(m.Cleanup)
+
+			plugin := newTestPlugin(t, m)
+			plugin.FieldExclude = []string{"*_id"}
+			plugin.StringFields = stringFields
+			require.NoError(t, plugin.compileFieldFilter())
+
+			input := []telegraf.Metric{
+				testutil.MustMetric(
+					"disk",
+					map[string]string{"host": "a"},
+					map[string]interface{}{"free": int64(3), "used": int64(5), "disk_id": int64(7), "model": "ssd", "host": "b"},
+					time.Now(),
+				),
+				testutil.MustMetric("info", map[string]string{}, map[string]interface{}{"version": "1.0"}, time.Now()),
+			}
+			require.NoError(t, plugin.Write(input))
+
+			got := m.GotMetrics()
+			require.Equal(t, 2, got.DataPointCount())
+			metrics := got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+			expected := map[string]interface{}{"host": "a"}
+			if stringFields == "attribute" {
+				expected["model"] = "ssd"
+			}
+			for i := 0; i < metrics.Len(); i++ {
+				require.Contains(t, []string{"disk_free", "disk_used"}, metrics.At(i).Name())
+				require.Equal(t, expected, metrics.At(i).Gauge().DataPoints().At(0).Attributes().AsRaw())
+			}
+		})
+	}
+}
+
+func TestOpenTelemetryStrictTypes(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	input := []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"usage": 0.5}, time.Now(), telegraf.ValueType(42)),
+		newTestMetric(),
+	}
+	require.NoError(t, plugin.Write(input))
+	require.Equal(t, 1, m.Requests())
+
+	plugin.StrictTypes = true
+	require.EqualError(t, plugin.Write(input), `unrecognized type 42 of metric "cpu"`)
+	require.Equal(t, 1, m.Requests())
+}
+
+func TestOpenTelemetryAttributePrivacy(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.ResourceTags = []string{"email"}
+	plugin.HashAttributes = []string{"user_id", "email"}
+	plugin.HashSalt = "pepper"
+	plugin.DropAttributes = []string{"session*"}
+	require.NoError(t, plugin.compileAttributePrivacy())
+
+	input := testutil.MustMetric(
+		"logins",
+		map[string]string{"user_id": "42", "email": "jane@example.com", "session_id": "abc", "host": "a"},
+		map[string]interface{}{"gauge": 1.0},
+		time.Unix(0, 1622848686000000000),
+	)
+	require.NoError(t, plugin.Write([]telegraf.Metric{input}))
+
+	hash := func(value string) string {
+		mac := hmac.New(sha256.New, []byte("pepper"))
+		mac.Write([]byte(value))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	rm := m.GotMetrics().ResourceMetrics().At(0)
+	email, ok := rm.Resource().Attributes().Get("email")
+	require.True(t, ok)
+	require.Equal(t, hash("jane@example.com"), email.StringVal())
+	attributes := rm.ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0).Attributes().AsRaw()
+	require.Equal(t, map[string]interface{}{"user_id": hash("42"), "host": "a"}, attributes)
+}
+
+func TestOpenTelemetryExemplars(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.EnableExemplars = true
+
+	ts := time.Unix(0, 1622848686000000000)
+	input := []telegraf.Metric{
+		testutil.MustMetric(
+			"requests",
+			map[string]string{
+				"host":                      "a",
+				"exemplar_trace_id":         "5b8efff798038103d269b633813fc60c",
+				"exemplar_span_id":          "eee19b7ec3c1b174",
+				"exemplar_attribute_client": "web",
+			},
+			map[string]interface{}{"counter": int64(42), "exemplar_value": 0.25, "exemplar_time": int64(1622848685000000000)},
+			ts,
+			telegraf.Counter,
+		),
+		testutil.MustMetric(
+			"temperature",
+			map[string]string{"host": "a", "exemplar_trace_id": "5b8efff798038103d269b633813fc60c"},
+			map[string]interface{}{"gauge": 21.5, "exemplar_value": 21.5},
+			ts,
+			telegraf.Gauge,
+		),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	got := make(map[string]pmetric.Metric)
+	metrics := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		got[metrics.At(i).Name()] = metrics.At(i)
+	}
+	require.Len(t, got, 2)
+
+	dp := got["requests"].Sum().DataPoints().At(0)
+	require.Equal(t, map[string]interface{}{"host": "a"}, dp.Attributes().AsRaw())
+	require.Equal(t, 1, dp.Exemplars().Len())
+	ex := dp.Exemplars().At(0)
+	require.Equal(t, 0.25, ex.DoubleVal())
+	require.Equal(t, pcommon.Timestamp(1622848685000000000), ex.Timestamp())
+	require.Equal(t, "5b8efff798038103d269b633813fc60c", ex.TraceID().HexString())
+	require.Equal(t, "eee19b7ec3c1b174", ex.SpanID().HexString())
+	require.Equal(t, map[string]interface{}{"client": "web"}, ex.FilteredAttributes().AsRaw())
+
+	// Gauges carry no exemplars.
+	dp = got["temperature"].Gauge().DataPoints().At(0)
+	require.Equal(t, map[string]interface{}{"host": "a"}, dp.Attributes().AsRaw())
+	require.Zero(t, dp.Exemplars().Len())
+}
+
+func TestOpenTelemetryMetricFilter(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	var err error
+	plugin.metricFilter, err = filter.NewIncludeExcludeFilter([]string{"cpu*", "mem?"}, []string{"cpu_temp"})
+	require.NoError(t, err)
+
+	ts := time.Unix(0, 1622848686000000000)
+	input := []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"usage": 0.5}, ts),
+		testutil.MustMetric("cpu_temp", map[string]string{}, map[string]interface{}{"value": 50.0}, ts),
+		testutil.MustMetric("mem1", map[string]string{}, map[string]interface{}{"free": int64(3)}, ts),
+		testutil.MustMetric("mem", map[string]string{}, map[string]interface{}{"free": int64(3)}, ts),
+		testutil.MustMetric("disk", map[string]string{}, map[string]interface{}{"free": int64(3)}, ts),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	got := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	var names []string
+	for i := 0; i < got.Len(); i++ {
+		names = append(names, got.At(i).Name())
+	}
+	require.ElementsMatch(t, []string{"cpu_usage", "mem1_free"}, names)
+}
+
+func TestOpenTelemetryUnits(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.Units = map[string]string{
+		"http":          "ms",
+		"http.requests": "{request}",
+	}
+	plugin.UnitTag = "unit"
+	require.NoError(t, plugin.compileUnits())
+
+	ts := time.Unix(0, 1622848686000000000)
+	input := []telegraf.Metric{
+		testutil.MustMetric("http", map[string]string{}, map[string]interface{}{"latency": 1.5, "requests": int64(2)}, ts),
+		testutil.MustMetric("disk", map[string]string{"unit": "By"}, map[string]interface{}{"free": int64(1024)}, ts),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	got := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	units := make(map[string]string)
+	for i := 0; i < got.Len(); i++ {
+		units[got.At(i).Name()] = got.At(i).Unit()
+	}
+	require.Equal(t, map[string]string{"http_latency": "ms", "http_requests": "{request}", "disk_free": "By"}, units)
+	dp := got.At(2).Gauge().DataPoints().At(0)
+	_, found := dp.Attributes().Get("unit")
+	require.False(t, found)
+}
+
+func TestOpenTelemetryDescriptions(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.Descriptions = map[string]string{
+		"http":          "HTTP server statistics",
+		"http.requests": "Requests served",
+	}
+	plugin.DescriptionTag = "metric_help"
+	require.NoError(t, plugin.compileDescriptions())
+
+	ts := time.Unix(0, 1622848686000000000)
+	input := []telegraf.Metric{
+		testutil.MustMetric("http", map[string]string{}, map[string]interface{}{"latency": 1.5, "requests": int64(2)}, ts),
+		testutil.MustMetric("disk", map[string]string{"metric_help": "Free disk space"}, map[string]interface{}{"free": int64(1024)}, ts),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	got := m.GotMetrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	descriptions := make(map[string]string)
+	for i := 0; i < got.Len(); i++ {
+		descriptions[got.At(i).Name()] = got.At(i).Description()
+		_, found := got.At(i).Gauge().DataPoints().At(0).Attributes().Get("metric_help")
+		require.False(t, found)
+	}
+	require.Equal(t, map[string]string{
+		"http_latency":  "HTTP server statistics",
+		"http_requests": "Requests served",
+		"disk_free":     "Free disk space",
+	}, descriptions)
+}
+
+func TestUCUMPattern(t *testing.T) {
+	for _, unit := range []string{"ms", "By", "{request}/s", "kBy/s", "%", "[degF]", "{free text}"} {
+		require.True(t, ucumPattern.MatchString(unit), unit)
+	}
+	for _, unit := range []string{"", "milli seconds", "{open", "µs"} {
+		require.False(t, ucumPattern.MatchString(unit), unit)
+	}
+}
+
+func TestOpenTelemetryResourceAttributesFile(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	path := filepath.Join(t.TempDir(), "resource.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"k8s.namespace.name": "prod", "replicas": 3, "service.name": "file"}`), 0o600))
+
+	plugin := newTestPlugin(t, m)
+	plugin.Attributes = map[string]string{"service.name": "inline"}
+	var err error
+	plugin.attributesFile, err = newValuesFile("resource_attributes_file", path, time.Nanosecond, testutil.Logger{})
+	require.NoError(t, err)
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	attributes := m.GotMetrics().ResourceMetrics().At(0).Resource().Attributes()
+	require.Equal(t, map[string]interface{}{
+		"k8s.namespace.name": "prod",
+		"replicas":           int64(3),
+		"service.name":       "inline",
+	}, attributes.AsRaw())
+
+	// The file is read again after the reload interval.
+	require.NoError(t, os.WriteFile(path, []byte(`{"k8s.namespace.name": "staging"}`), 0o600))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	attributes = m.GotMetrics().ResourceMetrics().At(0).Resource().Attributes()
+	require.Equal(t, map[string]interface{}{
+		"k8s.namespace.name": "staging",
+		"service.name":       "inline",
+	}, attributes.AsRaw())
+
+	// Broken files keep the previous attributes.
+	require.NoError(t, os.WriteFile(path, []byte(`{`), 0o600))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	v, ok := m.GotMetrics().ResourceMetrics().At(0).Resource().Attributes().Get("k8s.namespace.name")
+	require.True(t, ok)
+	require.Equal(t, "staging", v.StringVal())
+}
+
+func TestOpenTelemetryHeadersFile(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	path := filepath.Join(t.TempDir(), "headers.toml")
+	require.NoError(t, os.WriteFile(path, []byte("authorization = \"Bearer one\"\n"), 0o600))
+
+	plugin := &OpenTelemetry{
+		ServiceAddress:        m.Address(),
+		Headers:               map[string]string{"test": "header1", "Authorization": "Bearer static", "x-tenant": "acme"},
+		HeadersFile:           path,
+		HeadersReloadInterval: config.Duration(time.Nanosecond),
+		Log:                   testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, []string{"Bearer one"}, m.Metadata().Get("authorization"))
+	require.Equal(t, []string{"acme"}, m.Metadata().Get("x-tenant"))
+
+	// The file is read again after the reload interval.
+	require.NoError(t, os.WriteFile(path, []byte("authorization = \"Bearer two\"\n"), 0o600))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, []string{"Bearer two"}, m.Metadata().Get("authorization"))
+
+	// Broken files keep the previous headers.
+	require.NoError(t, os.WriteFile(path, []byte("authorization ="), 0o600))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, []string{"Bearer two"}, m.Metadata().Get("authorization"))
+}
+
+func TestLoadValuesFile(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "resource.toml")
+	require.NoError(t, os.WriteFile(path, []byte("\"cloud.region\" = \"eu-west-1\"\nsampled = true\nratio = 0.5\n"), 0o600))
+	attributes, err := loadValuesFile("resource_attributes_file", path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"cloud.region": "eu-west-1", "sampled": "true", "ratio": "0.5"}, attributes)
+
+	path = filepath.Join(dir, "nested.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"labels": {"app": "web"}}`), 0o600))
+	_, err = loadValuesFile("resource_attributes_file", path)
+	require.EqualError(t, err, fmt.Sprintf("unsupported value of %q in resource_attributes_file %q", "labels", path))
+
+	path = filepath.Join(dir, "resource.yaml")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	_, err = loadValuesFile("resource_attributes_file", path)
+	require.EqualError(t, err, fmt.Sprintf("resource_attributes_file %q must be a .json or .toml file", path))
+}
+
+func TestOpenTelemetryServiceName(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.ServiceName = "billing"
+	plugin.ResourceTags = []string{"service.name"}
+
+	ts := time.Unix(0, 1622848686000000000)
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"usage": 0.5}, ts),
+		testutil.MustMetric("cpu", map[string]string{"service.name": "api"}, map[string]interface{}{"usage": 0.5}, ts),
+	}))
+
+	var names []string
+	for i := 0; i < m.GotMetrics().ResourceMetrics().Len(); i++ {
+		v, ok := m.GotMetrics().ResourceMetrics().At(i).Resource().Attributes().Get("service.name")
+		require.True(t, ok)
+		names = append(names, v.StringVal())
+	}
+	require.ElementsMatch(t, []string{"billing", "api"}, names)
+}
+
+func TestOpenTelemetryResourceDetection(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	plugin := newTestPlugin(t, m)
+	plugin.Attributes = map[string]string{"os.type": "custom"}
+	plugin.ResourceDetection = true
+	plugin.ResourceDetectors = []string{"host.name", "os.type", "host.arch"}
+	require.NoError(t, plugin.detectResources())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	got := m.GotMetrics()
+	require.Equal(t, 1, got.ResourceMetrics().Len())
+	attributes := got.ResourceMetrics().At(0).Resource().Attributes()
+
+	v, ok := attributes.Get("host.name")
+	require.True(t, ok)
+	require.Equal(t, hostname, v.StringVal())
+	v, ok = attributes.Get("os.type")
+	require.True(t, ok)
+	require.Equal(t, "custom", v.StringVal())
+	v, ok = attributes.Get("host.arch")
+	require.True(t, ok)
+	require.NotEmpty(t, v.StringVal())
+	_, ok = attributes.Get("host.id")
+	require.False(t, ok)
+}
+
+func TestOpenTelemetryAgentMetadata(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.Attributes = map[string]string{"telegraf.version": "custom"}
+	plugin.IncludeAgentMetadata = true
+	require.NoError(t, plugin.collectAgentMetadata())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	attributes := m.GotMetrics().ResourceMetrics().At(0).Resource().Attributes()
+	v, ok := attributes.Get("telegraf.version")
+	require.True(t, ok)
+	require.Equal(t, "custom", v.StringVal())
+	v, ok = attributes.Get("process.runtime.version")
+	require.True(t, ok)
+	require.Equal(t, runtime.Version(), v.StringVal())
+	v, ok = attributes.Get("telegraf.config_hash")
+	require.True(t, ok)
+	require.Regexp(t, "^[0-9a-f]{16}$", v.StringVal())
+	hash := v.StringVal()
+
+	// The hash changes with the configuration only.
+	same := newTestPlugin(t, m)
+	same.Attributes = map[string]string{"telegraf.version": "custom"}
+	same.IncludeAgentMetadata = true
+	require.NoError(t, same.collectAgentMetadata())
+	require.Equal(t, hash, same.agentAttributes["telegraf.config_hash"])
+
+	other := newTestPlugin(t, m)
+	other.IncludeAgentMetadata = true
+	require.NoError(t, other.collectAgentMetadata())
+	require.NotEqual(t, hash, other.agentAttributes["telegraf.config_hash"])
+}
+
+func TestOpenTelemetryExpandEnvVars(t *testing.T) {
+	t.Setenv("TEST_OTEL_POD", "pod-1")
+	t.Setenv("TEST_OTEL_TOKEN", "secret")
+
+	plugin := &OpenTelemetry{
+		Headers:    map[string]string{"authorization": "Bearer ${TEST_OTEL_TOKEN}"},
+		Attributes: map[string]string{"k8s.pod.name": "${TEST_OTEL_POD}", "price": "$5"},
+		Log:        testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.Equal(t, map[string]string{"authorization": "Bearer secret"}, plugin.Headers)
+	require.Equal(t, map[string]string{"k8s.pod.name": "pod-1", "price": "$5"}, plugin.Attributes)
+
+	plugin = &OpenTelemetry{
+		Attributes: map[string]string{"region": "${TEST_OTEL_UNDEFINED}"},
+		Log:        testutil.Logger{},
+	}
+	require.EqualError(t, plugin.Init(), `environment variable "TEST_OTEL_UNDEFINED" in attributes "region" is not defined`)
+
+	plugin = &OpenTelemetry{
+		Attributes:           map[string]string{"region": "eu-${TEST_OTEL_UNDEFINED}"},
+		UndefinedEnvBehavior: "empty",
+		Log:                  testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.Equal(t, map[string]string{"region": "eu-"}, plugin.Attributes)
+}
+
+func TestOpenTelemetryExpandEnvVarsSignals(t *testing.T) {
+	t.Setenv("TEST_OTEL_TOKEN", "Bearer ${literal}")
+	t.Setenv("TEST_OTEL_TENANT", "tenant-1")
+
+	plugin := &OpenTelemetry{
+		Headers: map[string]string{"authorization": "${TEST_OTEL_TOKEN}"},
+		Metrics: &SignalConfig{Headers: map[string]string{"x-tenant": "${TEST_OTEL_TENANT}"}},
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.Equal(t, map[string]string{"authorization": "Bearer ${literal}"}, plugin.Headers)
+	require.Equal(t, map[string]string{"authorization": "Bearer ${literal}", "x-tenant": "tenant-1"}, plugin.metricsOutput.Headers)
+
+	plugin = &OpenTelemetry{
+		Metrics: &SignalConfig{Headers: map[string]string{"x-tenant": "${TEST_OTEL_UNDEFINED}"}},
+		Log:     testutil.Logger{},
+	}
+	require.EqualError(t, plugin.Init(), `environment variable "TEST_OTEL_UNDEFINED" in metrics headers "x-tenant" is not defined`)
+}
+
+func TestRedactPayload(t *testing.T) {
+	payload := []byte(`{"resourceMetrics":[{"resource":{"attributes":[` +
+		`{"key":"host","value":{"stringValue":"server"}},` +
+		`{"key":"User.Email","value":{"stringValue":"user@example.com"}}]}}]}`)
+	redacted, err := redactPayload(payload, map[string]bool{"user.email": true})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"resourceMetrics":[{"resource":{"attributes":[`+
+		`{"key":"host","value":{"stringValue":"server"}},`+
+		`{"key":"User.Email","value":{"stringValue":"[redacted]"}}]}}]}`, string(redacted))
+}
+
+func TestOpenTelemetryAttributeLimits(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.MaxAttributeValueLength = 4
+	plugin.MaxAttributesPerDatapoint = 2
+
+	metric := testutil.MustMetric("cpu",
+		map[string]string{"a": "short", "b": "ÄÖÜäöü", "c": "dropped"},
+		map[string]interface{}{"gauge": 1.0},
+		time.Unix(0, 0),
+		telegraf.Gauge,
+	)
+	require.NoError(t, plugin.Write([]telegraf.Metric{metric}))
+
+	got := m.GotMetrics()
+	require.Equal(t, 1, got.DataPointCount())
+	attributes := got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0).Attributes()
+	require.Equal(t, map[string]interface{}{"a": "shor", "b": "ÄÖÜä"}, attributes.AsRaw())
+}
+
+func TestOpenTelemetryMaxSeries(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := newTestPlugin(t, m)
+	plugin.MaxSeries = 2
+	plugin.HighCardinalityAttributes = []string{"request_*"}
+	require.NoError(t, plugin.compileSeriesLimit())
+	plugin.seriesLimiter = newSeriesLimiter(plugin.MaxSeries, time.Duration(plugin.MaxSeriesWindow), plugin.cardinalityFilter)
+
+	ts := time.Unix(0, 1622848686000000000)
+	cpu := func(tags map[string]string) telegraf.Metric {
+		return testutil.MustMetric("cpu", tags, map[string]interface{}{"usage": 0.5}, ts)
+	}
+	require.NoError(t, plugin.Write([]telegraf.Metric{cpu(map[string]string{"host": "a"}), cpu(map[string]string{"host": "b"})}))
+	require.Equal(t, 2, m.GotMetrics().DataPointCount())
+
+	// New series beyond the cap are collapsed into known ones or dropped.
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		cpu(map[string]string{"host": "a", "request_id": "1"}),
+		cpu(map[string]string{"host": "c", "request_id": "2"}),
+		cpu(map[string]string{"host": "b"}),
+	}))
+	got := m.GotMetrics()
+	require.Equal(t, 2, got.DataPointCount())
+	dps := got.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints()
+	require.Equal(t, map[string]interface{}{"host": "a"}, dps.At(0).Attributes().AsRaw())
+	require.Equal(t, map[string]interface{}{"host": "b"}, dps.At(1).Attributes().AsRaw())
+	require.Equal(t, int64(1), plugin.stats.seriesDropped.Get())
+
+	// The series are forgotten when the window ends.
+	plugin.seriesLimiter.started = time.Time{}
+	require.NoError(t, plugin.Write([]telegraf.Metric{cpu(map[string]string{"host": "c"})}))
+	require.Equal(t, 1, m.GotMetrics().DataPointCount())
+}
+
+func TestOpenTelemetryCompressionFallback(t *testing.T) {
+	// Rejects compressed requests like a server without the decompressor.
+	var rejected int32
+	rejectCompressed := grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		stream, ok := grpc.ServerTransportStreamFromContext(ctx).(interface{ RecvCompress() string })
+		if ok && stream.RecvCompress() != "" && stream.RecvCompress() != encoding.Identity {
+			atomic.AddInt32(&rejected, 1)
+			return nil, status.Errorf(codes.Unimplemented, "grpc: Decompressor is not installed for grpc-encoding %q", stream.RecvCompress())
+		}
+		return handler(ctx, req)
+	})
+
+	for _, fallback := range []bool{false, true} {
+		t.Run(fmt.Sprintf("fallback %v", fallback), func(t *testing.T) {
+			atomic.StoreInt32(&rejected, 0)
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			m := newMockOtelServiceWithListener(t, listener, rejectCompressed)
+			t.Cleanup(m.Cleanup)
+
+			plugin := &OpenTelemetry{
+				ServiceAddress:      m.Address(),
+				Compression:         "zstd",
+				CompressionFallback: fallback,
+				Headers:             map[string]string{"test": "header1"},
+				Log:                 testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+			require.NoError(t, plugin.Connect())
+			t.Cleanup(func() { require.NoError(t, plugin.Close()) })
+
+			if !fallback {
+				require.ErrorContains(t, plugin.Write([]telegraf.Metric{newTestMetric()}), "Decompressor is not installed")
+				return
+			}
+			require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+			require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+			require.Equal(t, 1, m.GotMetrics().DataPointCount())
+			require.Equal(t, int32(1), atomic.LoadInt32(&rejected))
+		})
+	}
+}
+
+func TestRetryJitter(t *testing.T) {
+	plugin := &OpenTelemetry{}
+	require.Equal(t, time.Second, plugin.jitter(time.Second))
+
+	plugin.RetryJitter = 0.5
+	for i := 0; i < 100; i++ {
+		wait := plugin.jitter(time.Second)
+		require.GreaterOrEqual(t, wait, 500*time.Millisecond)
+		require.LessOrEqual(t, wait, time.Second)
+	}
+
+	plugin.RetryJitter = 1
+	for i := 0; i < 100; i++ {
+		wait := plugin.jitter(time.Second)
+		require.GreaterOrEqual(t, wait, time.Duration(0))
+		require.LessOrEqual(t, wait, time.Second)
+	}
+}
+
+func TestExportTimeout(t *testing.T) {
+	plugin := &OpenTelemetry{Timeout: config.Duration(5 * time.Second)}
+	require.Equal(t, 5*time.Second, plugin.exportTimeout(100000))
+
+	plugin.TimeoutPer1kPoints = config.Duration(time.Second)
+	require.Equal(t, 5*time.Second, plugin.exportTimeout(10))
+	require.Equal(t, 5*time.Second, plugin.exportTimeout(5000))
+	require.Equal(t, 20*time.Second, plugin.exportTimeout(20000))
+	require.Equal(t, 100*time.Second, plugin.exportTimeout(100000))
+
+	plugin.MaxTimeout = config.Duration(30 * time.Second)
+	require.Equal(t, 20*time.Second, plugin.exportTimeout(20000))
+	require.Equal(t, 30*time.Second, plugin.exportTimeout(100000))
+}
+
+func TestOpenTelemetryDryRun(t *testing.T) {
+	var logger capturingLogger
+	plugin := &OpenTelemetry{
+		ServiceAddress: "127.0.0.1:1",
+		DryRun:         true,
+		Log:            &logger,
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	require.Nil(t, plugin.grpcClientConn)
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.NoError(t, plugin.Close())
+	require.Len(t, logger.info, 1)
+	require.Regexp(t, `^Dry run, not exporting 1 data points in 1 resources and 1 scopes \(\d+ bytes\)$`, logger.info[0])
+}
+
+func TestOpenTelemetryLogPayloads(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	var logger capturingLogger
+	plugin := newTestPlugin(t, m)
+	plugin.Log = &logger
+	plugin.Headers["api-key"] = "secret"
+	plugin.LogPayloads = true
+	plugin.LogPayloadsMaxSize = 20
+	plugin.RedactKeys = []string{"API-Key"}
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Len(t, logger.debug, 1)
+	require.Contains(t, logger.debug[0], "with headers [api-key=[redacted] test=header1]")
+	require.Regexp(t, `: \{"resourceMetrics":\[\.\.\. \(truncated from \d+ bytes\)$`, logger.debug[0])
+}
+
+func TestOpenTelemetrySignalOverrides(t *testing.T) {
+	metrics := newMockOtelService(t)
+	t.Cleanup(metrics.Cleanup)
+	logs := newMockOtelService(t)
+	t.Cleanup(logs.Cleanup)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress:  metrics.Address(),
+		Headers:         map[string]string{"test": "header1"},
+		LogMeasurements: []string{"syslog"},
+		Logs: &SignalConfig{
+			ServiceAddress: logs.Address(),
+			Headers:        map[string]string{"signal": "logs"},
+			Timeout:        config.Duration(time.Second),
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.Equal(t, map[string]string{"test": "header1", "signal": "logs"}, plugin.logsOutput.Headers)
+	require.Equal(t, config.Duration(time.Second), plugin.logsOutput.Timeout)
+	require.Equal(t, defaultTimeout, plugin.Timeout)
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	input := testutil.MustMetric(
+		"syslog",
+		map[string]string{},
+		map[string]interface{}{"message": "disk is almost full"},
+		time.Unix(0, 1622848686000000000))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric(), input}))
+
+	require.Equal(t, 1, metrics.GotMetrics().DataPointCount())
+	require.Equal(t, plog.Logs{}, metrics.GotLogs())
+	require.Equal(t, 1, logs.GotLogs().LogRecordCount())
+	require.Zero(t, logs.Requests())
+}
+
+func TestOpenTelemetrySignalOverridesConnections(t *testing.T) {
+	services := make(map[string]*mockOtelService)
+	listeners := make(map[string]*connCountingListener)
+	for _, signal := range []string{"default", signalMetrics, signalTraces, signalLogs} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		listeners[signal] = &connCountingListener{Listener: listener}
+		services[signal] = newMockOtelServiceWithListener(t, listeners[signal])
+		t.Cleanup(services[signal].Cleanup)
+	}
+	before := settledGoroutines()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: services["default"].Address(),
+		WaitForReady:   true,
+		Metrics:        &SignalConfig{ServiceAddress: services[signalMetrics].Address()},
+		Traces:         &SignalConfig{ServiceAddress: services[signalTraces].Address()},
+		Logs:           &SignalConfig{ServiceAddress: services[signalLogs].Address()},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+
+	// Every service is dialed once by the plugin, besides the client of the
+	// mock itself.
+	for signal, listener := range listeners {
+		require.Equal(t, int32(2), atomic.LoadInt32(&listener.accepted), signal)
+	}
+
+	require.NoError(t, plugin.Close())
+	for signal, listener := range listeners {
+		require.Eventually(t, func() bool {
+			return atomic.LoadInt32(&listener.open) == 1
+		}, 5*time.Second, 10*time.Millisecond, signal)
+	}
+	// Polled without require.Eventually, which runs the condition in a
+	// goroutine of its own.
+	after := runtime.NumGoroutine()
+	for deadline := time.Now().Add(5 * time.Second); after > before && time.Now().Before(deadline); after = runtime.NumGoroutine() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.LessOrEqual(t, after, before)
+}
+
+// connCountingListener counts the accepted and the still open connections.
+type connCountingListener struct {
+	net.Listener
+	accepted int32
+	open     int32
+}
+
+func (l *connCountingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt32(&l.accepted, 1)
+	atomic.AddInt32(&l.open, 1)
+	return &countedConn{Conn: conn, open: &l.open}, nil
+}
+
+type countedConn struct {
+	net.Conn
+	open   *int32
+	closed sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.closed.Do(func() { atomic.AddInt32(c.open, -1) })
+	return c.Conn.Close()
+}
+
+// settledGoroutines returns the number of goroutines once it stopped
+// changing, as servers start the goroutines of connections after accepting
+// them.
+func settledGoroutines() int {
+	n := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		time.Sleep(20 * time.Millisecond)
+		current := runtime.NumGoroutine()
+		if current == n {
+			return n
+		}
+		n = current
+	}
+	return n
+}
+
+func TestOpenTelemetryQueue(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: m.Address(),
+		Headers:        map[string]string{"test": "header1"},
+		QueueSize:      10,
+		NumConsumers:   2,
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	}
+	// Closing exports all queued requests
+	require.NoError(t, plugin.Close())
+	require.Equal(t, 5, m.Requests())
+}
+
+func TestOpenTelemetryShutdownTimeout(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress:  ts.URL,
+		Protocol:        "http/protobuf",
+		Timeout:         config.Duration(time.Minute),
+		QueueSize:       1,
+		ShutdownTimeout: config.Duration(100 * time.Millisecond),
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	<-started
+
+	// Closing cancels the export once the shutdown timeout has passed
+	start := time.Now()
+	require.NoError(t, plugin.Close())
+	require.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestSendQueueDropOldest(t *testing.T) {
+	plugin := &OpenTelemetry{OnFull: onFullDropOldest, Log: testutil.Logger{}}
+	plugin.registerStats()
+	plugin.queue = &sendQueue{requests: make(chan exportCall, 1)}
+
+	plugin.enqueue(exportCall{items: "data points", count: 1})
+	plugin.enqueue(exportCall{items: "data points", count: 2})
+	require.Equal(t, 2, (<-plugin.queue.requests).count)
+	require.Equal(t, int64(1), plugin.stats.queueDropped.Get())
+}
+
+func TestOpenTelemetryDiskQueue(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	dir := t.TempDir()
+	plugin := &OpenTelemetry{
+		ServiceAddress: m.Address(),
+		Headers:        map[string]string{"test": "header1"},
+		DiskQueuePath:  dir,
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+
+	// The failed request is persisted and replayed after the next export
+	m.FailNext(status.Error(codes.Unavailable, "unavailable"))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, plugin.diskQueue.len())
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 3, m.Requests())
+	require.Equal(t, 0, plugin.diskQueue.len())
+
+	// Persisted requests survive a restart
+	m.FailNext(status.Error(codes.Unavailable, "unavailable"))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.NoError(t, plugin.Close())
+
+	plugin = &OpenTelemetry{
+		ServiceAddress: m.Address(),
+		Headers:        map[string]string{"test": "header1"},
+		DiskQueuePath:  dir,
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+	require.Eventually(t, func() bool {
+		return plugin.diskQueue.len() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 5, m.Requests())
+}
+
+func TestDiskQueueMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openDiskQueue(dir, 25)
+	require.NoError(t, err)
+
+	for _, data := range []string{"first-req", "secondreq", "third-req"} {
+		_, err := q.push(signalMetrics, []byte(data), nil)
+		require.NoError(t, err)
+	}
+	_, err = q.push(signalMetrics, make([]byte, 26), nil)
+	require.Error(t, err)
+
+	// The oldest request was dropped; the others are loaded on reopening
+	q, err = openDiskQueue(dir, 25)
+	require.NoError(t, err)
+	require.Equal(t, 2, q.len())
+	f, ok := q.oldest()
+	require.True(t, ok)
+	data, err := os.ReadFile(filepath.Join(dir, f.name))
+	require.NoError(t, err)
+	require.Equal(t, "secondreq", string(data))
+}
+
+func TestOpenTelemetryCircuitBreaker(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress:          m.Address(),
+		Headers:                 map[string]string{"test": "header1"},
+		CircuitBreakerThreshold: 2,
+		Log:                     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	for i := 0; i < 2; i++ {
+		m.FailNext(status.Error(codes.Unavailable, "unavailable"))
+		require.Error(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	}
+	require.ErrorIs(t, plugin.Write([]telegraf.Metric{newTestMetric()}), errCircuitOpen)
+	require.Equal(t, 2, m.Requests())
+
+	// After the cooldown the probe closes the circuit
+	plugin.breaker.openedAt = time.Now().Add(-time.Duration(plugin.CircuitBreakerCooldown))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 4, m.Requests())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	b := newCircuitBreaker(1, time.Minute, testutil.Logger{})
+
+	// Permanent errors keep the circuit closed
+	require.True(t, b.allow(now))
+	b.record(status.Error(codes.InvalidArgument, "invalid"), now)
+	require.True(t, b.allow(now))
+	b.record(unavailable, now)
+	require.False(t, b.allow(now.Add(time.Second)))
+
+	// Only a single probe is attempted, its failure opens the circuit again
+	probe := now.Add(time.Minute)
+	require.True(t, b.allow(probe))
+	require.False(t, b.allow(probe))
+	b.record(unavailable, probe)
+	require.False(t, b.allow(probe.Add(time.Second)))
+	require.True(t, b.allow(probe.Add(time.Minute)))
+	b.record(nil, probe.Add(time.Minute))
+	require.True(t, b.allow(probe.Add(time.Minute)))
+}
+
+func TestOpenTelemetryRoundRobin(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: m.Address(),
+		BalancerPolicy: "round_robin",
+		Headers:        map[string]string{"test": "header1"},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.Equal(t, "dns:///"+m.Address(), plugin.endpoints[0].address)
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, m.Requests())
+}
+
+func TestOpenTelemetryGRPCRetryPolicy(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: m.Address(),
+		GRPCRetryPolicy: &GRPCRetryPolicy{
+			InitialBackoff: config.Duration(time.Millisecond),
+		},
+		Headers: map[string]string{"test": "header1"},
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	// gRPC retries the export itself.
+	m.FailNext(status.Error(codes.Unavailable, "unavailable"))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 2, m.Requests())
+	require.Equal(t, int64(0), plugin.stats.retries.Get())
+}
+
+func TestServiceConfig(t *testing.T) {
+	plugin := &OpenTelemetry{
+		BalancerPolicy: balancerRoundRobin,
+		GRPCRetryPolicy: &GRPCRetryPolicy{
+			MaxAttempts:          4,
+			InitialBackoff:       config.Duration(250 * time.Millisecond),
+			MaxBackoff:           config.Duration(time.Second),
+			BackoffMultiplier:    1.5,
+			RetryableStatusCodes: []string{"UNAVAILABLE", "RESOURCE_EXHAUSTED"},
+		},
+	}
+	sc, err := plugin.serviceConfig()
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"loadBalancingConfig": [{"round_robin": {}}],
+		"methodConfig": [{
+			"name": [
+				{"service": "opentelemetry.proto.collector.metrics.v1.MetricsService"},
+				{"service": "opentelemetry.proto.collector.trace.v1.TraceService"},
+				{"service": "opentelemetry.proto.collector.logs.v1.LogsService"}
+			],
+			"retryPolicy": {
+				"maxAttempts": 4,
+				"initialBackoff": "0.25s",
+				"maxBackoff": "1s",
+				"backoffMultiplier": 1.5,
+				"retryableStatusCodes": ["UNAVAILABLE", "RESOURCE_EXHAUSTED"]
+			}
+		}]
+	}`, sc)
+
+	sc, err = (&OpenTelemetry{BalancerPolicy: balancerPickFirst}).serviceConfig()
+	require.NoError(t, err)
+	require.Empty(t, sc)
+}
+
+func TestOpenTelemetryDNSRefresh(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress:     m.Address(),
+		DNSRefreshInterval: config.Duration(time.Minute),
+		Headers:            map[string]string{"test": "header1"},
+		Log:                testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.Equal(t, "dns:///"+m.Address(), plugin.endpoints[0].address)
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, m.Requests())
+}
+
+// capturingLogger records the debug, info and warning messages.
+type capturingLogger struct {
+	testutil.Logger
+	mu    sync.Mutex
+	debug []string
+	info  []string
+	warn  []string
+}
+
+func (l *capturingLogger) Warnf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warn = append(l.warn, fmt.Sprintf(format, args...))
+}
+
+func (l *capturingLogger) messages() (info, warn []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.info...), append([]string(nil), l.warn...)
+}
+
+func (l *capturingLogger) Infof(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.info = append(l.info, fmt.Sprintf(format, args...))
+}
+
+func (l *capturingLogger) Debugf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.debug = append(l.debug, fmt.Sprintf(format, args...))
+}
+
+type mockResolver struct {
+	resolved chan struct{}
+}
+
+func (r *mockResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolved <- struct{}{}:
+	default:
+	}
+}
+
+func (*mockResolver) Close() {}
+
+type mockResolverBuilder struct {
+	r *mockResolver
+}
+
+func (b *mockResolverBuilder) Build(resolver.Target, resolver.ClientConn, resolver.BuildOptions) (resolver.Resolver, error) {
+	return b.r, nil
+}
+
+func (*mockResolverBuilder) Scheme() string {
+	return "dns"
+}
+
+func TestRefreshingDNSBuilder(t *testing.T) {
+	r := &mockResolver{resolved: make(chan struct{})}
+	b := &refreshingDNSBuilder{Builder: &mockResolverBuilder{r: r}, interval: 10 * time.Millisecond}
+
+	rr, err := b.Build(resolver.Target{}, nil, resolver.BuildOptions{})
+	require.NoError(t, err)
+	defer rr.Close()
+
+	select {
+	case <-r.resolved:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "target not resolved again")
+	}
+}
+
+func TestOpenTelemetryFailover(t *testing.T) {
+	primary := newMockOtelService(t)
+	t.Cleanup(primary.Cleanup)
+	secondary := newMockOtelService(t)
+	t.Cleanup(secondary.Cleanup)
+
+	plugin := &OpenTelemetry{
+		Endpoints: []string{primary.Address(), secondary.Address()},
+		Headers:   map[string]string{"test": "header1"},
+		Log:       testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	primary.FailNext(status.Error(codes.Unavailable, "unavailable"))
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, primary.Requests())
+	require.Equal(t, 1, secondary.Requests())
+
+	// The secondary endpoint stays in use
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, primary.Requests())
+	require.Equal(t, 2, secondary.Requests())
+
+	// Permanent errors do not cause a failover
+	secondary.FailNext(status.Error(codes.InvalidArgument, "invalid"))
+	require.Error(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, primary.Requests())
+	require.Equal(t, 3, secondary.Requests())
+}
+
+func TestOpenTelemetryHTTPFailover(t *testing.T) {
+	unavailable := httptest.NewServer(http.NotFoundHandler())
+	unavailable.Close()
+
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer ts.Close()
+
+	plugin := &OpenTelemetry{
+		Endpoints: []string{unavailable.URL, ts.URL},
+		Protocol:  "http/protobuf",
+		Log:       testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, requests)
+}
+
+func TestOpenTelemetryUnixSocket(t *testing.T) {
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "otel.sock"))
+	require.NoError(t, err)
+	m := newMockOtelServiceWithListener(t, listener)
+	t.Cleanup(m.Cleanup)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: m.Address(),
+		Headers:        map[string]string{"test": "header1"},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, m.Requests())
+}
+
+func TestOpenTelemetryHTTPUnixSocket(t *testing.T) {
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "otel.sock"))
+	require.NoError(t, err)
+
+	var requests int
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/metrics", r.URL.Path)
+		requests++
+	})}
+	go func() { assert.ErrorIs(t, server.Serve(listener), http.ErrServerClosed) }()
+	defer server.Close()
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: "unix://" + listener.Addr().String(),
+		Protocol:       "http/protobuf",
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, requests)
+}
+
+func TestOpenTelemetryProxy(t *testing.T) {
+	m := newMockOtelService(t)
+	t.Cleanup(m.Cleanup)
+	tunnels := newConnectProxy(t)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: m.Address(),
+		Headers:        map[string]string{"test": "header1"},
+		Log:            testutil.Logger{},
+	}
+	plugin.UseProxy = true
+	plugin.ProxyURL = tunnels.URL
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, m.Requests())
+	require.Equal(t, []string{m.Address()}, tunnels.Targets())
+}
+
+func TestOpenTelemetryHTTPProxy(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer ts.Close()
+	tunnels := newConnectProxy(t)
+
+	plugin := &OpenTelemetry{
+		ServiceAddress: ts.URL,
+		Protocol:       "http/protobuf",
+		Log:            testutil.Logger{},
+	}
+	plugin.UseProxy = true
+	plugin.ProxyURL = tunnels.URL
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{newTestMetric()}))
+	require.Equal(t, 1, requests)
+	require.Equal(t, []string{strings.TrimPrefix(ts.URL, "http://")}, tunnels.Targets())
+}
+
+func TestIsRetryable(t *testing.T) {
+	require.True(t, isRetryable(status.Error(codes.Aborted, "")))
+	require.True(t, isRetryable(status.Error(codes.DeadlineExceeded, "")))
+	require.False(t, isRetryable(status.Error(codes.PermissionDenied, "")))
+	require.True(t, isRetryable(&httpStatusError{StatusCode: http.StatusServiceUnavailable}))
+	require.True(t, isRetryable(fmt.Errorf("wrapped: %w", &httpStatusError{StatusCode: http.StatusTooManyRequests})))
+	require.False(t, isRetryable(&httpStatusError{StatusCode: http.StatusBadRequest}))
+	require.True(t, isRetryable(&tokenError{err: errors.New("connection refused")}))
+	require.False(t, isRetryable(errors.New("unknown")))
+}
+
+// newTestPlugin returns a plugin wired to the mock service, as Connect would.
+func newTestPlugin(t *testing.T, m *mockOtelService) *OpenTelemetry {
+	metricsConverter, err := influx2otel.NewLineProtocolToOtelMetrics(common.NoopLogger{})
+	require.NoError(t, err)
+	plugin := &OpenTelemetry{
+		ServiceAddress:       m.Address(),
+		Timeout:              config.Duration(time.Second),
+		Headers:              map[string]string{"test": "header1"},
+		Log:                  testutil.Logger{},
+		metricsConverter:     metricsConverter,
+		grpcClientConn:       m.GrpcClient(),
+		endpointMu:           &sync.Mutex{},
+		drainer:              newDrainer(),
+		metricsServiceClient: pmetricotlp.NewClient(m.GrpcClient()),
+		tracesServiceClient:  ptraceotlp.NewClient(m.GrpcClient()),
+		logsServiceClient:    plogotlp.NewClient(m.GrpcClient()),
+		quantilePattern:      regexp.MustCompile(defaultQuantileFieldPattern),
+	}
+	plugin.registerStats()
+	return plugin
+}
+
+func newTestMetric() telegraf.Metric {
+	return testutil.MustMetric(
+		"cpu_temp",
+		map[string]string{
+			"foo": "bar",
+		},
+		map[string]interface{}{
+			"gauge": 87.332,
+		},
+		time.Unix(0, 1622848686000000000))
+}
+
+// connectProxy is an HTTP CONNECT proxy recording the tunnel targets.
+type connectProxy struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	targets []string
+}
+
+func newConnectProxy(t *testing.T) *connectProxy {
+	p := &connectProxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		p.mu.Lock()
+		p.targets = append(p.targets, r.Host)
+		p.mu.Unlock()
+
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if !assert.NoError(t, err) {
+			target.Close()
+			return
+		}
+		_, err = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		assert.NoError(t, err)
+		go func() {
+			_, _ = io.Copy(target, conn)
+			target.Close()
+		}()
+		go func() {
+			_, _ = io.Copy(conn, target)
+			conn.Close()
+		}()
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *connectProxy) Targets() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
 var _ pmetricotlp.Server = (*mockOtelService)(nil)
+var _ ptraceotlp.Server = (*mockTracesService)(nil)
+var _ plogotlp.Server = (*mockLogsService)(nil)
 
 type mockOtelService struct {
 	t          *testing.T
@@ -94,12 +4359,23 @@ type mockOtelService struct {
 	grpcClient *grpc.ClientConn
 
 	metrics pmetric.Metrics
+	traces  ptrace.Traces
+	logs    plog.Logs
+
+	mu       sync.Mutex
+	requests int
+	errs     []error
+	md       metadata.MD
 }
 
 func newMockOtelService(t *testing.T) *mockOtelService {
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	require.NoError(t, err)
-	grpcServer := grpc.NewServer()
+	return newMockOtelServiceWithListener(t, listener)
+}
+
+func newMockOtelServiceWithListener(t *testing.T, listener net.Listener, opts ...grpc.ServerOption) *mockOtelService {
+	grpcServer := grpc.NewServer(opts...)
 
 	mockOtelService := &mockOtelService{
 		t:          t,
@@ -108,9 +4384,11 @@ func newMockOtelService(t *testing.T) *mockOtelService {
 	}
 
 	pmetricotlp.RegisterServer(grpcServer, mockOtelService)
+	ptraceotlp.RegisterServer(grpcServer, &mockTracesService{mockOtelService})
+	plogotlp.RegisterServer(grpcServer, &mockLogsService{mockOtelService})
 	go func() { assert.NoError(t, grpcServer.Serve(listener)) }()
 
-	grpcClient, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	grpcClient, err := grpc.Dial(mockOtelService.Address(), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
 	require.NoError(t, err)
 	mockOtelService.grpcClient = grpcClient
 
@@ -127,17 +4405,106 @@ func (m *mockOtelService) GrpcClient() *grpc.ClientConn {
 }
 
 func (m *mockOtelService) GotMetrics() pmetric.Metrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.metrics
 }
 
+// Metadata returns the metadata of the last metrics export.
+func (m *mockOtelService) Metadata() metadata.MD {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.md
+}
+
+func (m *mockOtelService) GotTraces() ptrace.Traces {
+	return m.traces
+}
+
+func (m *mockOtelService) GotLogs() plog.Logs {
+	return m.logs
+}
+
 func (m *mockOtelService) Address() string {
+	if m.listener.Addr().Network() == "unix" {
+		return "unix://" + m.listener.Addr().String()
+	}
 	return m.listener.Addr().String()
 }
 
+// FailNext makes the next export calls fail with the given errors, in order.
+func (m *mockOtelService) FailNext(errs ...error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errs = append(m.errs, errs...)
+}
+
+func (m *mockOtelService) Requests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests
+}
+
+func (m *mockOtelService) nextError() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests++
+	if len(m.errs) == 0 {
+		return nil
+	}
+	err := m.errs[0]
+	m.errs = m.errs[1:]
+	return err
+}
+
 func (m *mockOtelService) Export(ctx context.Context, request pmetricotlp.Request) (pmetricotlp.Response, error) {
+	if err := m.nextError(); err != nil {
+		return pmetricotlp.NewResponse(), err
+	}
+	ctxMetadata, ok := metadata.FromIncomingContext(ctx)
+	m.mu.Lock()
 	m.metrics = request.Metrics().Clone()
+	m.md = ctxMetadata
+	m.mu.Unlock()
+	assert.Equal(m.t, []string{"header1"}, ctxMetadata.Get("test"))
+	assert.True(m.t, ok)
+	return pmetricotlp.NewResponse(), nil
+}
+
+type mockTracesService struct {
+	*mockOtelService
+}
+
+func (m *mockTracesService) Export(ctx context.Context, request ptraceotlp.Request) (ptraceotlp.Response, error) {
+	m.traces = request.Traces().Clone()
+	ctxMetadata, ok := metadata.FromIncomingContext(ctx)
+	assert.Equal(m.t, []string{"header1"}, ctxMetadata.Get("test"))
+	assert.True(m.t, ok)
+	return ptraceotlp.NewResponse(), nil
+}
+
+type mockLogsService struct {
+	*mockOtelService
+}
+
+func (m *mockLogsService) Export(ctx context.Context, request plogotlp.Request) (plogotlp.Response, error) {
+	m.logs = request.Logs().Clone()
 	ctxMetadata, ok := metadata.FromIncomingContext(ctx)
 	assert.Equal(m.t, []string{"header1"}, ctxMetadata.Get("test"))
 	assert.True(m.t, ok)
-	return pmetricotlp.Response{}, nil
+	return plogotlp.NewResponse(), nil
+}
+
+// partialSuccessResponse encodes an export response carrying a partial
+// success.
+func partialSuccessResponse(rejected int64, message string) []byte {
+	var ps []byte
+	ps = protowire.AppendTag(ps, 1, protowire.VarintType)
+	ps = protowire.AppendVarint(ps, uint64(rejected))
+	ps = protowire.AppendTag(ps, 2, protowire.BytesType)
+	ps = protowire.AppendString(ps, message)
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	return protowire.AppendBytes(b, ps)
 }
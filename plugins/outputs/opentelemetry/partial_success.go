@@ -0,0 +1,149 @@
+package opentelemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/proto"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// partialSuccess holds the partial_success message of an OTLP export
+// response. The pdata version in use predates that message, so it is decoded
+// from the raw response instead.
+type partialSuccess struct {
+	Rejected     int64
+	ErrorMessage string
+}
+
+// parsePartialSuccess decodes the partial_success field (1) of an
+// Export{Metrics,Trace,Logs}ServiceResponse. The rejected count is field 1 and
+// the error message field 2 for all three signals.
+func parsePartialSuccess(data []byte) (partialSuccess, error) {
+	var ps partialSuccess
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return ps, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if num != 1 || typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return ps, protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+
+		msg, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return ps, protowire.ParseError(n)
+		}
+		data = data[n:]
+		if err := ps.unmarshal(msg); err != nil {
+			return ps, err
+		}
+	}
+	return ps, nil
+}
+
+// parsePartialSuccessJSON decodes the partialSuccess field of a JSON encoded
+// export response. The name of the rejected count differs between the
+// signals; as an int64 it may be encoded as number or string.
+func parsePartialSuccessJSON(data []byte) (partialSuccess, error) {
+	var ps partialSuccess
+	if len(bytes.TrimSpace(data)) == 0 {
+		return ps, nil
+	}
+	var response struct {
+		PartialSuccess map[string]json.RawMessage `json:"partialSuccess"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return ps, err
+	}
+	for key, value := range response.PartialSuccess {
+		switch {
+		case key == "errorMessage":
+			if err := json.Unmarshal(value, &ps.ErrorMessage); err != nil {
+				return ps, err
+			}
+		case strings.HasPrefix(key, "rejected"):
+			rejected, err := strconv.ParseInt(strings.Trim(string(value), `"`), 10, 64)
+			if err != nil {
+				return ps, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			ps.Rejected = rejected
+		}
+	}
+	return ps, nil
+}
+
+func (ps *partialSuccess) unmarshal(data []byte) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			ps.Rejected = int64(v)
+			data = data[n:]
+		case num == 2 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			ps.ErrorMessage = v
+			data = data[n:]
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+	return nil
+}
+
+// partialSuccessCodec is the gRPC proto codec, additionally recording the
+// partial success of the response it decodes. A new instance is needed for
+// every call.
+type partialSuccessCodec struct {
+	encoding.Codec
+	partialSuccess partialSuccess
+}
+
+func newPartialSuccessCodec() *partialSuccessCodec {
+	return &partialSuccessCodec{Codec: encoding.GetCodec(proto.Name)}
+}
+
+func (c *partialSuccessCodec) Unmarshal(data []byte, v interface{}) error {
+	ps, err := parsePartialSuccess(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode partial success: %w", err)
+	}
+	c.partialSuccess = ps
+	return c.Codec.Unmarshal(data, v)
+}
+
+// logPartialSuccess warns about items the server rejected despite accepting
+// the request.
+func (o *OpenTelemetry) logPartialSuccess(ps partialSuccess, items string) {
+	if ps.Rejected <= 0 {
+		return
+	}
+	o.Log.Warnf("Export partially succeeded, %d %s rejected: %s", ps.Rejected, items, ps.ErrorMessage)
+}
@@ -0,0 +1,98 @@
+package opentelemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+const (
+	defaultLogPayloadsMaxSize = config.Size(4096)
+
+	redactedValue = "[redacted]"
+)
+
+// logPayload logs the request as OTLP JSON along with the headers. The
+// values of attributes and headers listed in redact_keys are replaced.
+func (o *OpenTelemetry) logPayload(call exportCall) {
+	payload, err := call.request.MarshalJSON()
+	if err != nil {
+		o.Log.Debugf("Serializing %d %s for logging failed: %v", call.count, call.items, err)
+		return
+	}
+	if len(o.RedactKeys) > 0 {
+		if payload, err = redactPayload(payload, o.redactKeys()); err != nil {
+			o.Log.Debugf("Redacting %d %s for logging failed: %v", call.count, call.items, err)
+			return
+		}
+	}
+
+	size := len(payload)
+	if size > int(o.LogPayloadsMaxSize) {
+		payload = append(payload[:o.LogPayloadsMaxSize], fmt.Sprintf("... (truncated from %d bytes)", size)...)
+	}
+	o.Log.Debugf("Exporting %d %s with headers %s: %s", call.count, call.items, o.redactedHeaders(), payload)
+}
+
+// redactKeys returns the keys to redact in lower case.
+func (o *OpenTelemetry) redactKeys() map[string]bool {
+	keys := make(map[string]bool, len(o.RedactKeys))
+	for _, k := range o.RedactKeys {
+		keys[strings.ToLower(k)] = true
+	}
+	return keys
+}
+
+func (o *OpenTelemetry) redactedHeaders() string {
+	keys := o.redactKeys()
+	static := o.staticHeaders()
+	names := make([]string, 0, len(static))
+	for name := range static {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := make([]string, 0, len(names))
+	for _, name := range names {
+		value := static[name]
+		if keys[strings.ToLower(name)] {
+			value = redactedValue
+		}
+		headers = append(headers, name+"="+value)
+	}
+	return "[" + strings.Join(headers, " ") + "]"
+}
+
+// redactPayload replaces the values of the attributes with the given keys in
+// the OTLP JSON payload, where attributes are objects with a "key" and a
+// "value".
+func redactPayload(payload []byte, keys map[string]bool) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return nil, err
+	}
+	redactValue(v, keys)
+	return json.Marshal(v)
+}
+
+func redactValue(v interface{}, keys map[string]bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if key, ok := v["key"].(string); ok && keys[strings.ToLower(key)] {
+			if _, ok := v["value"]; ok {
+				v["value"] = map[string]interface{}{"stringValue": redactedValue}
+				return
+			}
+		}
+		for _, child := range v {
+			redactValue(child, keys)
+		}
+	case []interface{}:
+		for _, child := range v {
+			redactValue(child, keys)
+		}
+	}
+}
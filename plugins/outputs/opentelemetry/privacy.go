@@ -0,0 +1,75 @@
+package opentelemetry
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/influxdata/telegraf/filter"
+)
+
+// compileAttributePrivacy compiles the hash_attributes and drop_attributes
+// patterns.
+func (o *OpenTelemetry) compileAttributePrivacy() error {
+	var err error
+	if o.hashFilter, err = filter.Compile(o.HashAttributes); err != nil {
+		return fmt.Errorf("invalid hash_attributes: %w", err)
+	}
+	if o.dropFilter, err = filter.Compile(o.DropAttributes); err != nil {
+		return fmt.Errorf("invalid drop_attributes: %w", err)
+	}
+	return nil
+}
+
+// protectDataPoints applies drop_attributes and hash_attributes to the
+// attributes of all data points.
+func (o *OpenTelemetry) protectDataPoints(metrics pmetric.Metrics) {
+	if o.hashFilter == nil && o.dropFilter == nil {
+		return
+	}
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				for _, attributes := range dataPointAttributes(sm.Metrics().At(k)) {
+					o.protectAttributes(attributes)
+				}
+			}
+		}
+	}
+}
+
+// protectAttributes removes the attributes matching drop_attributes and
+// replaces the values of those matching hash_attributes by their salted
+// hash.
+func (o *OpenTelemetry) protectAttributes(attributes pcommon.Map) {
+	if o.hashFilter == nil && o.dropFilter == nil {
+		return
+	}
+	if o.dropFilter != nil {
+		attributes.RemoveIf(func(k string, _ pcommon.Value) bool {
+			return o.dropFilter.Match(k)
+		})
+	}
+	if o.hashFilter != nil {
+		attributes.Range(func(k string, v pcommon.Value) bool {
+			if o.hashFilter.Match(k) {
+				v.SetStringVal(o.hashValue(v.AsString()))
+			}
+			return true
+		})
+	}
+}
+
+// hashValue returns the HMAC-SHA256 of the value keyed by the hash_salt in
+// hex, so equal values still hash to the same string.
+func (o *OpenTelemetry) hashValue(value string) string {
+	mac := hmac.New(sha256.New, []byte(o.HashSalt))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
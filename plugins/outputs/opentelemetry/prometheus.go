@@ -0,0 +1,226 @@
+package opentelemetry
+
+import (
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// prometheusUnits are the names Prometheus uses for the suffix of a UCUM unit.
+var prometheusUnits = map[string]string{
+	// Time
+	"d":   "days",
+	"h":   "hours",
+	"min": "minutes",
+	"s":   "seconds",
+	"ms":  "milliseconds",
+	"us":  "microseconds",
+	"ns":  "nanoseconds",
+
+	// Bytes
+	"By":   "bytes",
+	"KiBy": "kibibytes",
+	"MiBy": "mebibytes",
+	"GiBy": "gibibytes",
+	"TiBy": "tibibytes",
+	"KBy":  "kilobytes",
+	"MBy":  "megabytes",
+	"GBy":  "gigabytes",
+	"TBy":  "terabytes",
+
+	// SI
+	"m": "meters",
+	"V": "volts",
+	"A": "amperes",
+	"J": "joules",
+	"W": "watts",
+	"g": "grams",
+
+	// Misc
+	"Cel": "celsius",
+	"Hz":  "hertz",
+	"1":   "",
+	"%":   "percent",
+}
+
+// prometheusPerUnits are the names Prometheus uses for the denominator of a
+// unit such as "By/s".
+var prometheusPerUnits = map[string]string{
+	"s":  "second",
+	"m":  "minute",
+	"h":  "hour",
+	"d":  "day",
+	"w":  "week",
+	"mo": "month",
+	"y":  "year",
+}
+
+// prometheusMetricNames renames the metrics and their data point attributes
+// the way Prometheus translates OTLP names, so the names sent are the ones
+// queried in PromQL.
+func (o *OpenTelemetry) prometheusMetricNames(metrics pmetric.Metrics) {
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+				for _, attributes := range dataPointAttributes(metric) {
+					prometheusLabels(attributes)
+				}
+				name := prometheusName(metric)
+				if name == metric.Name() {
+					continue
+				}
+				if o.loggedRenames < maxLoggedRenames {
+					o.Log.Debugf("Renamed metric %q to %q", metric.Name(), name)
+					o.loggedRenames++
+				}
+				metric.SetName(name)
+			}
+		}
+	}
+}
+
+// prometheusName returns the Prometheus name of the metric. Runs of
+// characters other than letters and digits become a single underscore, the
+// unit is appended unless the name already contains it, monotonic sums end in
+// "_total" and gauges of unit "1" in "_ratio". Names starting with a digit
+// are prefixed by an underscore. Prometheus names are left unchanged.
+func prometheusName(metric pmetric.Metric) string {
+	tokens := nameTokens(metric.Name())
+
+	unit, per := prometheusUnit(metric.Unit())
+	for _, token := range nameTokens(unit) {
+		if !containsToken(tokens, token) {
+			tokens = append(tokens, token)
+		}
+	}
+	if per != "" && !containsToken(tokens, per) {
+		tokens = append(tokens, "per")
+		tokens = append(tokens, nameTokens(per)...)
+	}
+
+	switch metric.DataType() {
+	case pmetric.MetricDataTypeSum:
+		if metric.Sum().IsMonotonic() {
+			tokens = append(removeToken(tokens, "total"), "total")
+		}
+	case pmetric.MetricDataTypeGauge:
+		if metric.Unit() == "1" && !containsToken(tokens, "ratio") {
+			tokens = append(tokens, "ratio")
+		}
+	}
+
+	name := strings.Join(tokens, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// prometheusUnit returns the name and the denominator of the unit as used in
+// Prometheus names. Annotations in curly braces carry no unit.
+func prometheusUnit(unit string) (string, string) {
+	if strings.ContainsAny(unit, "{}") {
+		return "", ""
+	}
+	main, per := unit, ""
+	if i := strings.Index(unit, "/"); i >= 0 {
+		main, per = unit[:i], unit[i+1:]
+	}
+	if name, ok := prometheusUnits[main]; ok {
+		main = name
+	}
+	if name, ok := prometheusPerUnits[per]; ok {
+		per = name
+	}
+	return main, per
+}
+
+func nameTokens(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+}
+
+func containsToken(tokens []string, token string) bool {
+	for _, t := range tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+func removeToken(tokens []string, token string) []string {
+	result := tokens[:0]
+	for _, t := range tokens {
+		if t != token {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// prometheusLabels renames the attributes to valid Prometheus label names.
+// Attributes mapping to the same label are merged, joining their values by
+// ";" in the order of the original keys, like Prometheus does.
+func prometheusLabels(attributes pcommon.Map) {
+	renamed := make(map[string][]string)
+	attributes.Range(func(k string, v pcommon.Value) bool {
+		if label := prometheusLabel(k); label != k {
+			renamed[label] = append(renamed[label], k)
+		}
+		return true
+	})
+	if len(renamed) == 0 {
+		return
+	}
+
+	labels := make([]string, 0, len(renamed))
+	for label := range renamed {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		keys := renamed[label]
+		if _, ok := attributes.Get(label); ok {
+			keys = append(keys, label)
+		}
+		sort.Strings(keys)
+		values := make([]string, 0, len(keys))
+		for _, k := range keys {
+			v, _ := attributes.Get(k)
+			values = append(values, v.AsString())
+		}
+		for _, k := range keys {
+			attributes.Remove(k)
+		}
+		attributes.UpsertString(label, strings.Join(values, ";"))
+	}
+}
+
+// prometheusLabel replaces all characters other than letters, digits and
+// underscores by underscores. Labels starting with a digit are prefixed by
+// "key_" and labels starting with a single underscore by "key". Labels
+// starting with "__" are kept, like Prometheus does.
+func prometheusLabel(key string) string {
+	label := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, key)
+	switch {
+	case label == "":
+		return label
+	case label[0] >= '0' && label[0] <= '9':
+		return "key_" + label
+	case strings.HasPrefix(label, "_") && !strings.HasPrefix(label, "__"):
+		return "key" + label
+	}
+	return label
+}
@@ -0,0 +1,68 @@
+package opentelemetry
+
+import (
+	"sync"
+)
+
+const (
+	onFullBlock      = "block"
+	onFullDropOldest = "drop_oldest"
+
+	defaultNumConsumers = 1
+)
+
+// sendQueue decouples writing from exporting. The requests are exported by
+// the consumers in the background; when the queue is full the writer either
+// blocks or the oldest request is dropped.
+type sendQueue struct {
+	requests chan exportCall
+	wg       sync.WaitGroup
+}
+
+func (o *OpenTelemetry) startQueue() {
+	q := &sendQueue{requests: make(chan exportCall, o.QueueSize)}
+	for i := 0; i < o.NumConsumers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for call := range q.requests {
+				if err := o.deliver(call); err != nil {
+					o.Log.Errorf("Exporting %d %s failed: %v", call.count, call.items, err)
+				}
+			}
+		}()
+	}
+	o.queue = q
+}
+
+// enqueue adds the request to the queue, applying the on_full policy.
+func (o *OpenTelemetry) enqueue(call exportCall) {
+	if o.OnFull == onFullBlock {
+		o.queue.requests <- call
+		return
+	}
+
+	for {
+		select {
+		case o.queue.requests <- call:
+			return
+		default:
+		}
+		select {
+		case dropped := <-o.queue.requests:
+			o.stats.queueDropped.Incr(int64(dropped.count))
+			o.Log.Warnf("Send queue full, dropped %d %s", dropped.count, dropped.items)
+		default:
+		}
+	}
+}
+
+// stopQueue waits for the consumers to export all queued requests.
+func (o *OpenTelemetry) stopQueue() {
+	if o.queue == nil {
+		return
+	}
+	close(o.queue.requests)
+	o.queue.wg.Wait()
+	o.queue = nil
+}
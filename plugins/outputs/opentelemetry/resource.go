@@ -0,0 +1,167 @@
+package opentelemetry
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/influxdata/telegraf/internal/choice"
+)
+
+// serviceNameAttribute is the resource attribute backends group the data by.
+const serviceNameAttribute = "service.name"
+
+// promoteResourceTags moves the given data point attributes to the resource
+// of their metrics. Data points that disagree on the value of a promoted
+// attribute end up in different resources.
+func promoteResourceTags(metrics pmetric.Metrics, keys []string) pmetric.Metrics {
+	if len(keys) == 0 {
+		return metrics
+	}
+
+	result := pmetric.NewMetrics()
+	rmByResource := make(map[string]pmetric.ResourceMetrics)
+	smByScope := make(map[string]pmetric.ScopeMetrics)
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+
+				// Assign each data point to a group of data points sharing
+				// the same resource.
+				var groups []pcommon.Map
+				groupIndex := make(map[string]int)
+				var groupOf []int
+				for _, attributes := range dataPointAttributes(metric) {
+					resource := pcommon.NewMap()
+					rm.Resource().Attributes().CopyTo(resource)
+					for _, key := range keys {
+						if v, found := attributes.Get(key); found {
+							resource.Upsert(key, v)
+							attributes.Remove(key)
+						}
+					}
+					resource.Sort()
+
+					rKey := attributesToKey(resource)
+					g, found := groupIndex[rKey]
+					if !found {
+						g = len(groups)
+						groups = append(groups, resource)
+						groupIndex[rKey] = g
+					}
+					groupOf = append(groupOf, g)
+				}
+				if len(groups) == 0 {
+					groups = append(groups, rm.Resource().Attributes())
+				}
+
+				for g, resource := range groups {
+					target := lookupScopeMetrics(result, rmByResource, smByScope, rm, sm, resource)
+					if len(groups) == 1 {
+						metric.MoveTo(target.Metrics().AppendEmpty())
+						break
+					}
+					dst := target.Metrics().AppendEmpty()
+					metric.CopyTo(dst)
+					n := 0
+					removeDataPoints(dst, func() bool {
+						keep := groupOf[n] == g
+						n++
+						return !keep
+					})
+				}
+			}
+		}
+	}
+	return result
+}
+
+// isResourceTag reports whether key is a tag configured to be promoted to the
+// resource.
+func isResourceTag(key string, tags map[string]string, resourceTags []string) bool {
+	if _, found := tags[key]; !found {
+		return false
+	}
+	return choice.Contains(key, resourceTags)
+}
+
+func lookupScopeMetrics(
+	metrics pmetric.Metrics,
+	rmByResource map[string]pmetric.ResourceMetrics,
+	smByScope map[string]pmetric.ScopeMetrics,
+	rm pmetric.ResourceMetrics,
+	sm pmetric.ScopeMetrics,
+	resource pcommon.Map,
+) pmetric.ScopeMetrics {
+	rKey := rm.SchemaUrl() + "|" + attributesToKey(resource)
+	resourceMetrics, found := rmByResource[rKey]
+	if !found {
+		resourceMetrics = metrics.ResourceMetrics().AppendEmpty()
+		resourceMetrics.SetSchemaUrl(rm.SchemaUrl())
+		resource.CopyTo(resourceMetrics.Resource().Attributes())
+		rmByResource[rKey] = resourceMetrics
+	}
+
+	sKey := rKey + "|" + sm.SchemaUrl() + "|" + sm.Scope().Name() + ":" + sm.Scope().Version()
+	scopeMetrics, found := smByScope[sKey]
+	if !found {
+		scopeMetrics = resourceMetrics.ScopeMetrics().AppendEmpty()
+		scopeMetrics.SetSchemaUrl(sm.SchemaUrl())
+		sm.Scope().CopyTo(scopeMetrics.Scope())
+		smByScope[sKey] = scopeMetrics
+	}
+	return scopeMetrics
+}
+
+// dataPointAttributes returns the attributes of all data points of the
+// metric, in order.
+func dataPointAttributes(metric pmetric.Metric) []pcommon.Map {
+	var attributes []pcommon.Map
+	switch metric.DataType() {
+	case pmetric.MetricDataTypeGauge:
+		dps := metric.Gauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			attributes = append(attributes, dps.At(i).Attributes())
+		}
+	case pmetric.MetricDataTypeSum:
+		dps := metric.Sum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			attributes = append(attributes, dps.At(i).Attributes())
+		}
+	case pmetric.MetricDataTypeHistogram:
+		dps := metric.Histogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			attributes = append(attributes, dps.At(i).Attributes())
+		}
+	case pmetric.MetricDataTypeExponentialHistogram:
+		dps := metric.ExponentialHistogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			attributes = append(attributes, dps.At(i).Attributes())
+		}
+	case pmetric.MetricDataTypeSummary:
+		dps := metric.Summary().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			attributes = append(attributes, dps.At(i).Attributes())
+		}
+	}
+	return attributes
+}
+
+// removeDataPoints calls remove for each data point of the metric in order
+// and removes the data point if it returns true.
+func removeDataPoints(metric pmetric.Metric, remove func() bool) {
+	switch metric.DataType() {
+	case pmetric.MetricDataTypeGauge:
+		metric.Gauge().DataPoints().RemoveIf(func(pmetric.NumberDataPoint) bool { return remove() })
+	case pmetric.MetricDataTypeSum:
+		metric.Sum().DataPoints().RemoveIf(func(pmetric.NumberDataPoint) bool { return remove() })
+	case pmetric.MetricDataTypeHistogram:
+		metric.Histogram().DataPoints().RemoveIf(func(pmetric.HistogramDataPoint) bool { return remove() })
+	case pmetric.MetricDataTypeExponentialHistogram:
+		metric.ExponentialHistogram().DataPoints().RemoveIf(func(pmetric.ExponentialHistogramDataPoint) bool { return remove() })
+	case pmetric.MetricDataTypeSummary:
+		metric.Summary().DataPoints().RemoveIf(func(pmetric.SummaryDataPoint) bool { return remove() })
+	}
+}
@@ -0,0 +1,86 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/shirou/gopsutil/v3/host"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+const (
+	detectorHostName = "host.name"
+	detectorHostID   = "host.id"
+	detectorOSType   = "os.type"
+	detectorHostArch = "host.arch"
+)
+
+var defaultResourceDetectors = []string{detectorHostName, detectorHostID, detectorOSType, detectorHostArch}
+
+// hostArchs maps Go architectures to the host.arch values of the semantic
+// conventions where they differ.
+var hostArchs = map[string]string{
+	"386":     "x86",
+	"arm":     "arm32",
+	"ppc":     "ppc32",
+	"ppc64le": "ppc64",
+}
+
+// osTypes maps Go operating systems to the os.type values of the semantic
+// conventions where they differ.
+var osTypes = map[string]string{
+	"dragonfly": "dragonflybsd",
+	"illumos":   "solaris",
+	"zos":       "z_os",
+}
+
+// detectResources determines the host resource attributes of the enabled
+// detectors. Attributes that cannot be detected are skipped with a warning.
+func (o *OpenTelemetry) detectResources() error {
+	o.detectedAttributes = nil
+	if !o.ResourceDetection {
+		return nil
+	}
+	if o.ResourceDetectors == nil {
+		o.ResourceDetectors = defaultResourceDetectors
+	}
+
+	o.detectedAttributes = make(map[string]string, len(o.ResourceDetectors))
+	for _, detector := range o.ResourceDetectors {
+		var value string
+		var err error
+		switch detector {
+		case detectorHostName:
+			value, err = os.Hostname()
+		case detectorHostID:
+			value, err = host.HostID()
+		case detectorOSType:
+			value = runtime.GOOS
+			if t, ok := osTypes[value]; ok {
+				value = t
+			}
+		case detectorHostArch:
+			value = runtime.GOARCH
+			if arch, ok := hostArchs[value]; ok {
+				value = arch
+			}
+		default:
+			return fmt.Errorf("unsupported resource detector %q", detector)
+		}
+		if err != nil || value == "" {
+			o.Log.Warnf("Detecting the %q resource attribute failed: %v", detector, err)
+			continue
+		}
+		o.detectedAttributes[detector] = value
+	}
+	return nil
+}
+
+// setDetectedAttributes adds the detected host attributes the resource does
+// not have already, so tags and configured attributes take precedence.
+func (o *OpenTelemetry) setDetectedAttributes(resource pcommon.Resource) {
+	for k, v := range o.detectedAttributes {
+		resource.Attributes().InsertString(k, v)
+	}
+}
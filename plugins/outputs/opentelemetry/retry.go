@@ -0,0 +1,88 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retryableCodes are the gRPC status codes the OTLP specification considers
+// transient.
+var retryableCodes = map[codes.Code]bool{
+	codes.Unavailable:       true,
+	codes.DeadlineExceeded:  true,
+	codes.ResourceExhausted: true,
+	codes.Aborted:           true,
+}
+
+// retryableStatusCodes are the HTTP status codes the OTLP specification
+// considers transient.
+var retryableStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+func isRetryable(err error) bool {
+	var tokenErr *tokenError
+	if errors.As(err, &tokenErr) {
+		return true
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatusCodes[statusErr.StatusCode]
+	}
+	if s, ok := status.FromError(err); ok {
+		return retryableCodes[s.Code()]
+	}
+	return false
+}
+
+// withRetry calls export until it succeeds, fails with a non-retryable error
+// or the retries are exhausted. The backoff doubles after each attempt up to
+// the configured maximum, shortened by a random jitter, and never waits past
+// the deadline of ctx.
+func (o *OpenTelemetry) withRetry(ctx context.Context, export func(context.Context) error) error {
+	interval := time.Duration(o.RetryInitialInterval)
+	for attempt := 0; ; attempt++ {
+		err := export(ctx)
+		if err == nil || attempt >= o.MaxRetries || !isRetryable(err) {
+			return err
+		}
+		wait := o.jitter(interval)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		o.Log.Debugf("Export failed, retrying in %s: %v", wait, err)
+		o.stats.retries.Incr(1)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		interval *= 2
+		if maxInterval := time.Duration(o.RetryMaxInterval); interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+// jitter shortens the interval by a random part of up to retry_jitter of it,
+// so agents retrying after the same outage spread out. A jitter of 1 waits a
+// random time up to the interval, 0.5 between half and the full interval.
+func (o *OpenTelemetry) jitter(interval time.Duration) time.Duration {
+	if o.RetryJitter <= 0 {
+		return interval
+	}
+	return interval - time.Duration(rand.Float64()*o.RetryJitter*float64(interval)) //nolint:gosec // The jitter needs no cryptographic randomness
+}
@@ -0,0 +1,49 @@
+package opentelemetry
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// maxLoggedRenames is the number of metric name rewrites logged.
+const maxLoggedRenames = 10
+
+// sanitizeMetricNames rewrites the metric names to the charset accepted by
+// Prometheus compatible backends.
+func (o *OpenTelemetry) sanitizeMetricNames(metrics pmetric.Metrics) {
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+				name := sanitizeName(metric.Name())
+				if name == metric.Name() {
+					continue
+				}
+				if o.loggedRenames < maxLoggedRenames {
+					o.Log.Debugf("Renamed metric %q to %q", metric.Name(), name)
+					o.loggedRenames++
+				}
+				metric.SetName(name)
+			}
+		}
+	}
+}
+
+// sanitizeName replaces all characters other than letters, digits,
+// underscores and colons by underscores and prefixes names starting with a
+// digit by an underscore.
+func sanitizeName(name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+	if sanitized != "" && sanitized[0] >= '0' && sanitized[0] <= '9' {
+		sanitized = "_" + sanitized
+	}
+	return sanitized
+}
@@ -0,0 +1,57 @@
+package opentelemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+const defaultShutdownTimeout = config.Duration(30 * time.Second)
+
+// drainer tracks the exports in flight, so closing can wait for them, and
+// cancels them once the shutdown timeout has passed.
+type drainer struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	inflight sync.WaitGroup
+}
+
+func newDrainer() *drainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &drainer{ctx: ctx, cancel: cancel}
+}
+
+// drain waits up to the shutdown timeout for the queued requests, a running
+// replay of the disk queue and the exports in flight to finish, then cancels
+// the remaining exports.
+func (o *OpenTelemetry) drain() {
+	d := o.drainer
+	done := make(chan struct{})
+	go func() {
+		o.stopQueue()
+		if o.diskQueue != nil {
+			o.diskQueue.replayMu.Lock()
+			o.diskQueue.replayMu.Unlock() //nolint:staticcheck // Only waits for a running replay
+		}
+		d.inflight.Wait()
+		close(done)
+	}()
+
+	timeout := time.Duration(o.ShutdownTimeout)
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		o.Log.Warnf("Exports did not finish within the shutdown_timeout of %s, cancelling them", timeout)
+		d.cancel()
+		<-done
+	}
+	d.cancel()
+	o.drainer = newDrainer()
+}
+
+// shuttingDown reports whether the exports were cancelled on closing.
+func (o *OpenTelemetry) shuttingDown() bool {
+	return o.drainer.ctx.Err() != nil
+}
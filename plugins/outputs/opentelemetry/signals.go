@@ -0,0 +1,100 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+// SignalConfig overrides the connection settings for a single signal. Unset
+// fields fall back to the top-level settings, headers are added to the
+// top-level headers.
+type SignalConfig struct {
+	ServiceAddress string            `toml:"service_address"`
+	Headers        map[string]string `toml:"headers"`
+	Compression    string            `toml:"compression"`
+	Timeout        config.Duration   `toml:"timeout"`
+}
+
+// initSignals creates a separate output for every signal with overrides. It
+// must run before the top-level settings are defaulted.
+func (o *OpenTelemetry) initSignals() error {
+	var err error
+	if o.metricsOutput, err = o.newSignalOutput(signalMetrics, o.Metrics); err != nil {
+		return err
+	}
+	if o.tracesOutput, err = o.newSignalOutput(signalTraces, o.Traces); err != nil {
+		return err
+	}
+	o.logsOutput, err = o.newSignalOutput(signalLogs, o.Logs)
+	return err
+}
+
+func (o *OpenTelemetry) newSignalOutput(signal string, cfg *SignalConfig) (*OpenTelemetry, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	output := *o
+	output.derived = true
+	output.Metrics, output.Traces, output.Logs = nil, nil, nil
+	// The outputs of the signals created before belong to the plugin only.
+	output.metricsOutput, output.tracesOutput, output.logsOutput = nil, nil, nil
+	// The plugin itself sends the heartbeat through the metrics output.
+	output.HeartbeatInterval = 0
+	if cfg.ServiceAddress != "" {
+		output.ServiceAddress = cfg.ServiceAddress
+		output.Endpoints = nil
+	}
+	if len(cfg.Headers) > 0 {
+		// The headers of the plugin are expanded already, expanding them again
+		// would resolve references contained in the values of variables.
+		headers, err := o.expandValues(signal+" headers", cfg.Headers)
+		if err != nil {
+			return nil, err
+		}
+		output.Headers = make(map[string]string, len(o.Headers)+len(headers))
+		for k, v := range o.Headers {
+			output.Headers[k] = v
+		}
+		for k, v := range headers {
+			output.Headers[k] = v
+		}
+	}
+	if cfg.Compression != "" {
+		output.Compression = cfg.Compression
+	}
+	if cfg.Timeout > 0 {
+		output.Timeout = cfg.Timeout
+	}
+	// Each output replays only its own persisted requests.
+	if o.DiskQueuePath != "" {
+		output.DiskQueuePath = filepath.Join(o.DiskQueuePath, signal)
+	}
+
+	if err := output.Init(); err != nil {
+		return nil, fmt.Errorf("%s: %w", signal, err)
+	}
+	return &output, nil
+}
+
+// signalOutputs returns the outputs of the signals with overrides.
+func (o *OpenTelemetry) signalOutputs() []*OpenTelemetry {
+	var outputs []*OpenTelemetry
+	for _, output := range []*OpenTelemetry{o.metricsOutput, o.tracesOutput, o.logsOutput} {
+		if output != nil {
+			outputs = append(outputs, output)
+		}
+	}
+	return outputs
+}
+
+// signalOutput returns the output of a signal, which is the plugin itself
+// unless the signal has overrides.
+func (o *OpenTelemetry) signalOutput(output *OpenTelemetry) *OpenTelemetry {
+	if output != nil {
+		return output
+	}
+	return o
+}
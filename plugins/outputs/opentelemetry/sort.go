@@ -0,0 +1,91 @@
+package opentelemetry
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// attributeKeys caches the keys of sorted attribute maps, so sorting computes
+// every key only once.
+type attributeKeys map[pcommon.Map]string
+
+func (k attributeKeys) of(attributes pcommon.Map) string {
+	key, ok := k[attributes]
+	if !ok {
+		key = attributesToKey(attributes.Sort())
+		k[attributes] = key
+	}
+	return key
+}
+
+// sortMetrics orders the resources by their attributes, the scopes by name
+// and version, the metrics by name and the data points by their attributes
+// and timestamp. The attributes themselves are sorted by key, so the request
+// does not depend on the iteration order of tags and fields.
+func sortMetrics(metrics pmetric.Metrics) {
+	keys := make(attributeKeys)
+	rms := metrics.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		keys.of(rms.At(i).Resource().Attributes())
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			ms := sms.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				sortDataPoints(ms.At(k), keys)
+			}
+			ms.Sort(func(a, b pmetric.Metric) bool {
+				return a.Name() < b.Name()
+			})
+		}
+		sms.Sort(func(a, b pmetric.ScopeMetrics) bool {
+			if a.Scope().Name() != b.Scope().Name() {
+				return a.Scope().Name() < b.Scope().Name()
+			}
+			return a.Scope().Version() < b.Scope().Version()
+		})
+	}
+	rms.Sort(func(a, b pmetric.ResourceMetrics) bool {
+		return keys.of(a.Resource().Attributes()) < keys.of(b.Resource().Attributes())
+	})
+}
+
+func sortDataPoints(metric pmetric.Metric, keys attributeKeys) {
+	// Computing the keys sorts the attributes, also of a single data point.
+	for _, attributes := range dataPointAttributes(metric) {
+		keys.of(attributes)
+	}
+	less := func(a, b pcommon.Map, ta, tb pcommon.Timestamp) bool {
+		if ka, kb := keys.of(a), keys.of(b); ka != kb {
+			return ka < kb
+		}
+		return ta < tb
+	}
+	switch metric.DataType() {
+	case pmetric.MetricDataTypeGauge:
+		metric.Gauge().DataPoints().Sort(func(a, b pmetric.NumberDataPoint) bool {
+			return less(a.Attributes(), b.Attributes(), a.Timestamp(), b.Timestamp())
+		})
+	case pmetric.MetricDataTypeSum:
+		metric.Sum().DataPoints().Sort(func(a, b pmetric.NumberDataPoint) bool {
+			return less(a.Attributes(), b.Attributes(), a.Timestamp(), b.Timestamp())
+		})
+	case pmetric.MetricDataTypeHistogram:
+		metric.Histogram().DataPoints().Sort(func(a, b pmetric.HistogramDataPoint) bool {
+			return less(a.Attributes(), b.Attributes(), a.Timestamp(), b.Timestamp())
+		})
+	case pmetric.MetricDataTypeExponentialHistogram:
+		metric.ExponentialHistogram().DataPoints().Sort(func(a, b pmetric.ExponentialHistogramDataPoint) bool {
+			return less(a.Attributes(), b.Attributes(), a.Timestamp(), b.Timestamp())
+		})
+	case pmetric.MetricDataTypeSummary:
+		dps := metric.Summary().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			dps.At(i).QuantileValues().Sort(func(a, b pmetric.ValueAtQuantile) bool {
+				return a.Quantile() < b.Quantile()
+			})
+		}
+		dps.Sort(func(a, b pmetric.SummaryDataPoint) bool {
+			return less(a.Attributes(), b.Attributes(), a.Timestamp(), b.Timestamp())
+		})
+	}
+}
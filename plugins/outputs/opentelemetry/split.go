@@ -0,0 +1,199 @@
+package opentelemetry
+
+import (
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+var (
+	metricsSizer = pmetric.NewProtoMarshaler().(pmetric.Sizer)
+	tracesSizer  = ptrace.NewProtoMarshaler().(ptrace.Sizer)
+	logsSizer    = plog.NewProtoMarshaler().(plog.Sizer)
+)
+
+// splitMetrics splits the metrics into chunks that each serialize to at most
+// maxSize bytes. Resource metrics are kept whole where possible and are
+// otherwise split by scope, metric and finally data point. Data points that
+// exceed the limit on their own are dropped; their number is returned.
+func splitMetrics(metrics pmetric.Metrics, maxSize int) ([]pmetric.Metrics, int) {
+	if metricsSizer.MetricsSize(metrics) <= maxSize {
+		return []pmetric.Metrics{metrics}, 0
+	}
+
+	var dropped int
+	var chunks []pmetric.Metrics
+	var current pmetric.Metrics
+	var currentSize int
+	add := func(piece pmetric.Metrics, size int) {
+		if currentSize == 0 || currentSize+size > maxSize {
+			current = pmetric.NewMetrics()
+			currentSize = 0
+			chunks = append(chunks, current)
+		}
+		piece.ResourceMetrics().MoveAndAppendTo(current.ResourceMetrics())
+		currentSize += size
+	}
+
+	// Every piece holds a single resource metrics entry. As they are
+	// elements of a repeated top-level field their sizes add up exactly.
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		piece := pmetric.NewMetrics()
+		rm.CopyTo(piece.ResourceMetrics().AppendEmpty())
+		if size := metricsSizer.MetricsSize(piece); size <= maxSize {
+			add(piece, size)
+			continue
+		}
+
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			piece, target := newPiece(rm, sm)
+			sm.Metrics().CopyTo(target.Metrics())
+			if size := metricsSizer.MetricsSize(piece); size <= maxSize {
+				add(piece, size)
+				continue
+			}
+
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+				piece, target := newPiece(rm, sm)
+				metric.CopyTo(target.Metrics().AppendEmpty())
+				if size := metricsSizer.MetricsSize(piece); size <= maxSize {
+					add(piece, size)
+					continue
+				}
+
+				for n := 0; n < dataPointCount(metric); n++ {
+					piece, target := newPiece(rm, sm)
+					copyDataPoint(metric, n, target.Metrics().AppendEmpty())
+					if size := metricsSizer.MetricsSize(piece); size <= maxSize {
+						add(piece, size)
+						continue
+					}
+					dropped++
+				}
+			}
+		}
+	}
+	return chunks, dropped
+}
+
+// splitMetricsByCount splits the metrics into chunks of at most max data
+// points. Consecutive data points of the same resource, scope and metric stay
+// together within a chunk.
+func splitMetricsByCount(metrics pmetric.Metrics, max int) []pmetric.Metrics {
+	if metrics.DataPointCount() <= max {
+		return []pmetric.Metrics{metrics}
+	}
+
+	var chunks []pmetric.Metrics
+	var current pmetric.Metrics
+	var count int
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			var target pmetric.ScopeMetrics
+			hasTarget := false
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+				var dst pmetric.Metric
+				hasDst := false
+				for n := 0; n < dataPointCount(metric); n++ {
+					if len(chunks) == 0 || count == max {
+						current = pmetric.NewMetrics()
+						chunks = append(chunks, current)
+						count = 0
+						hasTarget, hasDst = false, false
+					}
+					if !hasTarget {
+						target = appendScope(current, rm, sm)
+						hasTarget = true
+					}
+					if !hasDst {
+						dst = target.Metrics().AppendEmpty()
+						copyMetricDescriptor(metric, dst)
+						hasDst = true
+					}
+					appendDataPoint(metric, n, dst)
+					count++
+				}
+			}
+		}
+	}
+	return chunks
+}
+
+// newPiece returns metrics holding an empty copy of the resource and scope.
+func newPiece(rm pmetric.ResourceMetrics, sm pmetric.ScopeMetrics) (pmetric.Metrics, pmetric.ScopeMetrics) {
+	piece := pmetric.NewMetrics()
+	return piece, appendScope(piece, rm, sm)
+}
+
+// appendScope appends an empty copy of the resource and scope to metrics.
+func appendScope(metrics pmetric.Metrics, rm pmetric.ResourceMetrics, sm pmetric.ScopeMetrics) pmetric.ScopeMetrics {
+	pieceRM := metrics.ResourceMetrics().AppendEmpty()
+	pieceRM.SetSchemaUrl(rm.SchemaUrl())
+	rm.Resource().CopyTo(pieceRM.Resource())
+	pieceSM := pieceRM.ScopeMetrics().AppendEmpty()
+	pieceSM.SetSchemaUrl(sm.SchemaUrl())
+	sm.Scope().CopyTo(pieceSM.Scope())
+	return pieceSM
+}
+
+func dataPointCount(metric pmetric.Metric) int {
+	switch metric.DataType() {
+	case pmetric.MetricDataTypeGauge:
+		return metric.Gauge().DataPoints().Len()
+	case pmetric.MetricDataTypeSum:
+		return metric.Sum().DataPoints().Len()
+	case pmetric.MetricDataTypeHistogram:
+		return metric.Histogram().DataPoints().Len()
+	case pmetric.MetricDataTypeExponentialHistogram:
+		return metric.ExponentialHistogram().DataPoints().Len()
+	case pmetric.MetricDataTypeSummary:
+		return metric.Summary().DataPoints().Len()
+	}
+	return 0
+}
+
+// copyDataPoint copies the metric to dst, keeping only its n-th data point.
+func copyDataPoint(metric pmetric.Metric, n int, dst pmetric.Metric) {
+	copyMetricDescriptor(metric, dst)
+	appendDataPoint(metric, n, dst)
+}
+
+// copyMetricDescriptor copies the metric to dst without its data points.
+func copyMetricDescriptor(metric pmetric.Metric, dst pmetric.Metric) {
+	dst.SetName(metric.Name())
+	dst.SetDescription(metric.Description())
+	dst.SetUnit(metric.Unit())
+	dst.SetDataType(metric.DataType())
+	switch metric.DataType() {
+	case pmetric.MetricDataTypeSum:
+		dst.Sum().SetAggregationTemporality(metric.Sum().AggregationTemporality())
+		dst.Sum().SetIsMonotonic(metric.Sum().IsMonotonic())
+	case pmetric.MetricDataTypeHistogram:
+		dst.Histogram().SetAggregationTemporality(metric.Histogram().AggregationTemporality())
+	case pmetric.MetricDataTypeExponentialHistogram:
+		dst.ExponentialHistogram().SetAggregationTemporality(metric.ExponentialHistogram().AggregationTemporality())
+	}
+}
+
+// appendDataPoint appends the n-th data point of the metric to dst, which
+// must have the type of the metric.
+func appendDataPoint(metric pmetric.Metric, n int, dst pmetric.Metric) {
+	switch metric.DataType() {
+	case pmetric.MetricDataTypeGauge:
+		metric.Gauge().DataPoints().At(n).CopyTo(dst.Gauge().DataPoints().AppendEmpty())
+	case pmetric.MetricDataTypeSum:
+		metric.Sum().DataPoints().At(n).CopyTo(dst.Sum().DataPoints().AppendEmpty())
+	case pmetric.MetricDataTypeHistogram:
+		metric.Histogram().DataPoints().At(n).CopyTo(dst.Histogram().DataPoints().AppendEmpty())
+	case pmetric.MetricDataTypeExponentialHistogram:
+		metric.ExponentialHistogram().DataPoints().At(n).CopyTo(dst.ExponentialHistogram().DataPoints().AppendEmpty())
+	case pmetric.MetricDataTypeSummary:
+		metric.Summary().DataPoints().At(n).CopyTo(dst.Summary().DataPoints().AppendEmpty())
+	}
+}
@@ -0,0 +1,111 @@
+package opentelemetry
+
+import (
+	"math"
+
+	"github.com/influxdata/influxdb-observability/common"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// staleMarker is the attribute marking the data points to flag as having no
+// recorded value on their way through the conversion.
+const staleMarker = "\x00stale"
+
+var noRecordedValue = pmetric.NewMetricDataPointFlags(pmetric.MetricDataPointFlagNoRecordedValue)
+
+// isStaleMetric reports whether the stale_tag of the metric is "true". The
+// tag is removed from the tags.
+func (o *OpenTelemetry) isStaleMetric(tags map[string]string) bool {
+	if o.StaleTag == "" {
+		return false
+	}
+	value, ok := tags[o.StaleTag]
+	if !ok {
+		return false
+	}
+	delete(tags, o.StaleTag)
+	return value == "true"
+}
+
+// splitStale moves the fields of gauges and sums to send as stale data points
+// into groups of their own: all fields of stale metrics and, with
+// non_finite_handling set to "stale", NaN fields. Their values are replaced
+// by zero.
+func (o *OpenTelemetry) splitStale(groups []fieldGroup, stale bool) []fieldGroup {
+	if !stale && o.NonFiniteHandling != nonFiniteStale {
+		return groups
+	}
+
+	result := make([]fieldGroup, 0, len(groups))
+	for _, group := range groups {
+		switch group.vType {
+		case common.InfluxMetricValueTypeGauge, common.InfluxMetricValueTypeSum, common.InfluxMetricValueTypeUntyped:
+		default:
+			result = append(result, group)
+			continue
+		}
+
+		staleGroup := fieldGroup{fields: make(map[string]interface{}), vType: group.vType, nonMonotonic: group.nonMonotonic, stale: true}
+		fields := make(map[string]interface{}, len(group.fields))
+		for k, v := range group.fields {
+			if f, ok := v.(float64); stale || ok && math.IsNaN(f) {
+				staleGroup.fields[k] = float64(0)
+				continue
+			}
+			fields[k] = v
+		}
+		if len(fields) > 0 {
+			group.fields = fields
+			result = append(result, group)
+		}
+		if len(staleGroup.fields) > 0 {
+			result = append(result, staleGroup)
+		}
+	}
+	return result
+}
+
+// withStaleMarker returns a copy of the tags with the stale marker.
+func withStaleMarker(tags map[string]string) map[string]string {
+	result := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		result[k] = v
+	}
+	result[staleMarker] = "true"
+	return result
+}
+
+// markStale flags the data points carrying the stale marker as having no
+// recorded value and removes the marker.
+func markStale(metrics pmetric.Metrics) {
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				var dps pmetric.NumberDataPointSlice
+				switch metric := sm.Metrics().At(k); metric.DataType() {
+				case pmetric.MetricDataTypeGauge:
+					dps = metric.Gauge().DataPoints()
+				case pmetric.MetricDataTypeSum:
+					dps = metric.Sum().DataPoints()
+				default:
+					continue
+				}
+				for n := 0; n < dps.Len(); n++ {
+					dp := dps.At(n)
+					if _, ok := dp.Attributes().Get(staleMarker); ok {
+						dp.Attributes().Remove(staleMarker)
+						dp.SetFlags(noRecordedValue)
+					}
+				}
+			}
+		}
+	}
+}
+
+// isStale reports whether the data point is flagged as having no recorded
+// value.
+func isStale(dp pmetric.NumberDataPoint) bool {
+	return dp.Flags().HasFlag(pmetric.MetricDataPointFlagNoRecordedValue)
+}
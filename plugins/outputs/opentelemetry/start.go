@@ -0,0 +1,97 @@
+package opentelemetry
+
+import (
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// startSeries is the start time and last value seen for a cumulative series.
+type startSeries struct {
+	start     pcommon.Timestamp
+	timestamp pcommon.Timestamp
+	value     float64
+	lastSeen  time.Time
+}
+
+// startTimeTracker sets the start timestamp of cumulative sums and histograms
+// to the time the series was first seen. A value lower than the previous one
+// is taken as a counter reset and starts the series again after the previous
+// timestamp. Non-monotonic sums are never reset.
+type startTimeTracker struct {
+	series map[string]*startSeries
+}
+
+func newStartTimeTracker() *startTimeTracker {
+	return &startTimeTracker{series: make(map[string]*startSeries)}
+}
+
+func (t *startTimeTracker) track(metrics pmetric.Metrics, now time.Time) {
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		rKey := attributesToKey(rm.Resource().Attributes().Sort())
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			sKey := rKey + "|" + sm.Scope().Name() + ":" + sm.Scope().Version()
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+				mKey := sKey + "|" + metric.Name() + "|"
+				switch metric.DataType() {
+				case pmetric.MetricDataTypeSum:
+					sum := metric.Sum()
+					if sum.AggregationTemporality() != pmetric.MetricAggregationTemporalityCumulative {
+						continue
+					}
+					for n := 0; n < sum.DataPoints().Len(); n++ {
+						dp := sum.DataPoints().At(n)
+						if dp.StartTimestamp() != 0 || isStale(dp) {
+							continue
+						}
+						value := dp.DoubleVal()
+						if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+							value = float64(dp.IntVal())
+						}
+						key := mKey + attributesToKey(dp.Attributes().Sort())
+						dp.SetStartTimestamp(t.start(key, dp.Timestamp(), value, sum.IsMonotonic(), now))
+					}
+				case pmetric.MetricDataTypeHistogram:
+					histogram := metric.Histogram()
+					if histogram.AggregationTemporality() != pmetric.MetricAggregationTemporalityCumulative {
+						continue
+					}
+					for n := 0; n < histogram.DataPoints().Len(); n++ {
+						dp := histogram.DataPoints().At(n)
+						if dp.StartTimestamp() != 0 {
+							continue
+						}
+						key := mKey + attributesToKey(dp.Attributes().Sort())
+						dp.SetStartTimestamp(t.start(key, dp.Timestamp(), float64(dp.Count()), true, now))
+					}
+				}
+			}
+		}
+	}
+
+	for key, s := range t.series {
+		if now.Sub(s.lastSeen) > deltaStaleness {
+			delete(t.series, key)
+		}
+	}
+}
+
+// start returns the start timestamp of the series and records the value.
+func (t *startTimeTracker) start(key string, timestamp pcommon.Timestamp, value float64, monotonic bool, now time.Time) pcommon.Timestamp {
+	s, found := t.series[key]
+	switch {
+	case !found:
+		s = &startSeries{start: timestamp}
+		t.series[key] = s
+	case monotonic && value < s.value:
+		s.start = s.timestamp
+	}
+	s.timestamp = timestamp
+	s.value = value
+	s.lastSeen = now
+	return s.start
+}
@@ -0,0 +1,43 @@
+package opentelemetry
+
+import (
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// exportStats are the internal metrics of the exporter, reported as the
+// internal_opentelemetry measurement.
+type exportStats struct {
+	metricsSent      selfstat.Stat
+	batchesSent      selfstat.Stat
+	exportErrors     selfstat.Stat
+	exportDuration   selfstat.Stat
+	retries          selfstat.Stat
+	queueDropped     selfstat.Stat
+	diskQueueDropped selfstat.Stat
+	collectorHealthy selfstat.Stat
+	connectDuration  selfstat.Stat
+	seriesDropped    selfstat.Stat
+	exportLatency    *latencyHistogram
+	exportResults    *exportResults
+}
+
+func (o *OpenTelemetry) registerStats() {
+	tags := map[string]string{
+		"service_address": o.ServiceAddress,
+		"protocol":        o.Protocol,
+	}
+	o.stats = exportStats{
+		metricsSent:      selfstat.Register("opentelemetry", "metrics_sent", tags),
+		batchesSent:      selfstat.Register("opentelemetry", "batches_sent", tags),
+		exportErrors:     selfstat.Register("opentelemetry", "export_errors", tags),
+		exportDuration:   selfstat.RegisterTiming("opentelemetry", "export_duration_ns", tags),
+		retries:          selfstat.Register("opentelemetry", "retries", tags),
+		queueDropped:     selfstat.Register("opentelemetry", "queue_dropped", tags),
+		diskQueueDropped: selfstat.Register("opentelemetry", "disk_queue_dropped", tags),
+		collectorHealthy: selfstat.Register("opentelemetry", "collector_healthy", tags),
+		connectDuration:  selfstat.RegisterTiming("opentelemetry", "connect_duration_ns", tags),
+		seriesDropped:    selfstat.Register("opentelemetry", "series_dropped", tags),
+		exportLatency:    newLatencyHistogram("opentelemetry_export_latency", o.ExportLatencyBuckets, tags),
+		exportResults:    newExportResults("opentelemetry_export_results", tags),
+	}
+}
@@ -0,0 +1,186 @@
+package opentelemetry
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb-observability/common"
+)
+
+// defaultQuantileFieldPattern matches percentile fields such as "p50", "p99"
+// or "p99.9".
+const defaultQuantileFieldPattern = `^p(\d+(?:\.\d+)?)$`
+
+// summaryFields returns the fields of a summary in the form expected by the
+// converter: float "count" and "sum" fields and one float field per quantile
+// named by its fraction, for example "0.99". Fields matching the quantile
+// field pattern are renamed, other fields are kept and ignored by the
+// converter.
+func (o *OpenTelemetry) summaryFields(fields map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		value, ok := toFloat(v)
+		if !ok {
+			result[k] = v
+			continue
+		}
+		switch {
+		case k == common.MetricSummaryCountFieldKey || k == common.MetricSummarySumFieldKey:
+			result[k] = value
+		case isQuantile(k):
+			result[k] = value
+		default:
+			if m := o.quantilePattern.FindStringSubmatch(k); m != nil {
+				if quantile, ok := percentileToQuantile(m[1]); ok {
+					result[strconv.FormatFloat(quantile, 'g', -1, 64)] = value
+					continue
+				}
+			}
+			result[k] = v
+		}
+	}
+	return result
+}
+
+func isQuantile(s string) bool {
+	quantile, err := strconv.ParseFloat(s, 64)
+	return err == nil && quantile >= 0 && quantile <= 1
+}
+
+// percentileToQuantile converts a percentile to the quantile fraction. Digits
+// exceeding 100 without a decimal point are read as a fraction, so "999" is
+// the 99.9th percentile.
+func percentileToQuantile(s string) (float64, bool) {
+	percentile, err := strconv.ParseFloat(s, 64)
+	if err != nil || percentile < 0 {
+		return 0, false
+	}
+	if percentile > 100 {
+		if strings.Contains(s, ".") {
+			return 0, false
+		}
+		quantile, err := strconv.ParseFloat("0."+s, 64)
+		return quantile, err == nil
+	}
+	return percentile / 100, true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// prometheusSummaries collects summaries in the Prometheus metric version 2
+// format, which spreads a summary over one metric per quantile, tagged with
+// the quantile, and a metric with the "_count" and "_sum" fields. The
+// converter would take them as histograms.
+type prometheusSummaries struct {
+	summaries map[string]*prometheusSummary
+	order     []string
+}
+
+type prometheusSummary struct {
+	name   string
+	tags   map[string]string
+	fields map[string]interface{}
+	ts     time.Time
+}
+
+// add adds the metric to its summary and reports whether it is part of one.
+func (s *prometheusSummaries) add(tags map[string]string, fields map[string]interface{}, ts time.Time) bool {
+	if tag, ok := tags[common.MetricSummaryQuantileKeyV2]; ok {
+		if len(fields) != 1 || !isQuantile(tag) {
+			return false
+		}
+		quantile, _ := strconv.ParseFloat(tag, 64)
+		for name, v := range fields {
+			value, ok := toFloat(v)
+			if !ok {
+				return false
+			}
+			s.summary(name, tags, ts).fields[strconv.FormatFloat(quantile, 'g', -1, 64)] = value
+		}
+		return true
+	}
+
+	var name string
+	for k := range fields {
+		var n string
+		switch {
+		case strings.HasSuffix(k, common.MetricSummaryCountSuffix):
+			n = strings.TrimSuffix(k, common.MetricSummaryCountSuffix)
+		case strings.HasSuffix(k, common.MetricSummarySumSuffix):
+			n = strings.TrimSuffix(k, common.MetricSummarySumSuffix)
+		default:
+			return false
+		}
+		if name != "" && n != name {
+			return false
+		}
+		name = n
+	}
+	if name == "" {
+		return false
+	}
+
+	summary := s.summary(name, tags, ts)
+	for k, v := range fields {
+		value, ok := toFloat(v)
+		if !ok {
+			continue
+		}
+		if strings.HasSuffix(k, common.MetricSummaryCountSuffix) {
+			summary.fields[common.MetricSummaryCountFieldKey] = value
+		} else {
+			summary.fields[common.MetricSummarySumFieldKey] = value
+		}
+	}
+	return true
+}
+
+func (s *prometheusSummaries) summary(name string, tags map[string]string, ts time.Time) *prometheusSummary {
+	summaryTags := make(map[string]string, len(tags))
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if k == common.MetricSummaryQuantileKeyV2 {
+			continue
+		}
+		summaryTags[k] = v
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var key strings.Builder
+	key.WriteString(name)
+	for _, k := range keys {
+		key.WriteString("," + k + "=" + summaryTags[k])
+	}
+	key.WriteString(" " + strconv.FormatInt(ts.UnixNano(), 10))
+
+	if s.summaries == nil {
+		s.summaries = make(map[string]*prometheusSummary)
+	}
+	summary, ok := s.summaries[key.String()]
+	if !ok {
+		summary = &prometheusSummary{name: name, tags: summaryTags, fields: make(map[string]interface{}), ts: ts}
+		s.summaries[key.String()] = summary
+		s.order = append(s.order, key.String())
+	}
+	return summary
+}
+
+// each calls fn for every summary in the order they were first seen.
+func (s *prometheusSummaries) each(fn func(summary *prometheusSummary)) {
+	for _, key := range s.order {
+		fn(s.summaries[key])
+	}
+}
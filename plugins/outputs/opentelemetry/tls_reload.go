@@ -0,0 +1,106 @@
+package opentelemetry
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// tlsConfig returns the client TLS configuration, or nil for plaintext
+// connections. TLS is used if any of the tls_* options is set, unless
+// insecure overrides it: true always connects in plaintext and false always
+// uses TLS, with the system roots if no tls_* option is set. The server name
+// defaults to the host of the authority. With a tls_reload_interval the
+// client certificate is read again on handshakes once the interval has
+// passed, so rotated certificates are used for new connections.
+func (o *OpenTelemetry) tlsConfig() (*tls.Config, error) {
+	if o.Insecure != nil && *o.Insecure {
+		return nil, nil
+	}
+	tlsConfig, err := o.ClientConfig.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		if o.Insecure == nil {
+			return nil, nil
+		}
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" && o.Authority != "" {
+		tlsConfig.ServerName = authorityHost(o.Authority)
+	}
+	if o.TLSReloadInterval <= 0 || len(tlsConfig.Certificates) == 0 {
+		return tlsConfig, nil
+	}
+
+	reloader := &certificateReloader{
+		certFile: o.TLSCert,
+		keyFile:  o.TLSKey,
+		interval: time.Duration(o.TLSReloadInterval),
+		log:      o.Log,
+		cert:     &tlsConfig.Certificates[0],
+		loaded:   time.Now(),
+	}
+	tlsConfig.Certificates = nil
+	tlsConfig.GetClientCertificate = reloader.getClientCertificate
+	return tlsConfig, nil
+}
+
+// checkInsecure rejects settings contradicting an explicit insecure, namely
+// tls_* options with insecure = true and addresses with a scheme selecting the
+// other mode.
+func (o *OpenTelemetry) checkInsecure() error {
+	if o.Insecure == nil {
+		return nil
+	}
+	if *o.Insecure {
+		// The configuration fails to load only if options are set.
+		if tlsConfig, err := o.ClientConfig.TLSConfig(); err != nil || tlsConfig != nil {
+			return errors.New("insecure = true cannot be combined with the tls_* options")
+		}
+	}
+	for _, address := range o.addresses() {
+		if (*o.Insecure && strings.HasPrefix(address, httpsScheme)) || (!*o.Insecure && strings.HasPrefix(address, httpScheme)) {
+			return fmt.Errorf("address %q contradicts insecure = %t", address, *o.Insecure)
+		}
+	}
+	return nil
+}
+
+// certificateReloader keeps the client certificate loaded from the files,
+// reading them again once the interval has passed. If that fails the previous
+// certificate is kept.
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	log      telegraf.Logger
+
+	sync.Mutex
+	cert   *tls.Certificate
+	loaded time.Time
+}
+
+func (r *certificateReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if time.Since(r.loaded) < r.interval {
+		return r.cert, nil
+	}
+	r.loaded = time.Now()
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		r.log.Errorf("Reloading client certificate failed, keeping the previous one: %v", err)
+		return r.cert, nil
+	}
+	r.cert = &cert
+	return r.cert, nil
+}
@@ -0,0 +1,249 @@
+package opentelemetry
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb-observability/common"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/influxdata/telegraf"
+)
+
+// measurementTraces is accepted as an alias of common.MeasurementSpans.
+const measurementTraces = "traces"
+
+var spanKinds = []ptrace.SpanKind{
+	ptrace.SpanKindInternal,
+	ptrace.SpanKindServer,
+	ptrace.SpanKindClient,
+	ptrace.SpanKindProducer,
+	ptrace.SpanKindConsumer,
+}
+
+// isSpan reports whether the metric carries a span in the schema written by
+// the OpenTelemetry input plugin.
+func isSpan(metric telegraf.Metric) bool {
+	if metric.Name() != common.MeasurementSpans && metric.Name() != measurementTraces {
+		return false
+	}
+	return hasTagOrField(metric, common.AttributeTraceID) && hasTagOrField(metric, common.AttributeSpanID)
+}
+
+func hasTagOrField(metric telegraf.Metric, key string) bool {
+	if metric.HasTag(key) {
+		return true
+	}
+	return metric.HasField(key)
+}
+
+// tracesBatch groups spans by resource and instrumentation scope, in the same
+// way the influx2otel metrics batch does for metrics.
+type tracesBatch struct {
+	traces       ptrace.Traces
+	rsByResource map[string]ptrace.ResourceSpans
+	ssByScope    map[string]map[string]ptrace.ScopeSpans
+	resourceTags []string
+	logger       common.Logger
+}
+
+func newTracesBatch(resourceTags []string, logger common.Logger) *tracesBatch {
+	return &tracesBatch{
+		traces:       ptrace.NewTraces(),
+		rsByResource: make(map[string]ptrace.ResourceSpans),
+		ssByScope:    make(map[string]map[string]ptrace.ScopeSpans),
+		resourceTags: resourceTags,
+		logger:       logger,
+	}
+}
+
+func (b *tracesBatch) GetTraces() ptrace.Traces {
+	return b.traces
+}
+
+func (b *tracesBatch) AddSpan(tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	var scopeName, scopeVersion string
+	resourceAttributes := pcommon.NewMap()
+	spanAttributes := pcommon.NewMap()
+	span := ptrace.NewSpan()
+
+	values := make(map[string]interface{}, len(tags)+len(fields))
+	for k, v := range fields {
+		values[k] = v
+	}
+	for k, v := range tags {
+		values[k] = v
+	}
+
+	for k, v := range values {
+		switch {
+		case k == common.AttributeTraceID:
+			traceID, err := parseTraceID(v)
+			if err != nil {
+				return err
+			}
+			span.SetTraceID(traceID)
+		case k == common.AttributeSpanID:
+			spanID, err := parseSpanID(v)
+			if err != nil {
+				return err
+			}
+			span.SetSpanID(spanID)
+		case k == common.AttributeParentSpanID:
+			spanID, err := parseSpanID(v)
+			if err != nil {
+				return err
+			}
+			span.SetParentSpanID(spanID)
+		case k == common.AttributeTraceState:
+			span.SetTraceState(ptrace.TraceState(fmt.Sprint(v)))
+		case k == common.AttributeName:
+			span.SetName(fmt.Sprint(v))
+		case k == common.AttributeSpanKind:
+			span.SetKind(parseSpanKind(fmt.Sprint(v)))
+		case k == common.AttributeEndTimeUnixNano:
+			if end, ok := v.(int64); ok {
+				span.SetEndTimestamp(pcommon.Timestamp(end))
+			}
+		case k == common.AttributeDurationNano:
+			// The end time takes precedence; the duration is only used as
+			// a fallback below.
+		case k == common.AttributeStatusCode:
+			switch v {
+			case common.AttributeStatusCodeOK:
+				span.Status().SetCode(ptrace.StatusCodeOk)
+			case common.AttributeStatusCodeError:
+				span.Status().SetCode(ptrace.StatusCodeError)
+			}
+		case k == common.AttributeStatusMessage:
+			span.Status().SetMessage(fmt.Sprint(v))
+		case k == common.AttributeInstrumentationLibraryName:
+			scopeName = fmt.Sprint(v)
+		case k == common.AttributeInstrumentationLibraryVersion:
+			scopeVersion = fmt.Sprint(v)
+		case k == common.AttributeDroppedSpanAttributesCount,
+			k == common.AttributeDroppedEventsCount,
+			k == common.AttributeDroppedLinksCount:
+			continue
+		case common.ResourceNamespace.MatchString(k) || isResourceTag(k, tags, b.resourceTags):
+			insertAttribute(resourceAttributes, k, v, b.logger)
+		default:
+			insertAttribute(spanAttributes, k, v, b.logger)
+		}
+	}
+
+	if span.TraceID().IsEmpty() {
+		return fmt.Errorf("span has no trace ID")
+	}
+	if span.SpanID().IsEmpty() {
+		return fmt.Errorf("span has no span ID")
+	}
+
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(ts))
+	if span.EndTimestamp() == 0 {
+		if duration, ok := values[common.AttributeDurationNano].(int64); ok {
+			span.SetEndTimestamp(pcommon.NewTimestampFromTime(ts.Add(time.Duration(duration))))
+		}
+	}
+	spanAttributes.Sort().CopyTo(span.Attributes())
+
+	resourceAttributes.Sort()
+	span.MoveTo(b.lookupScopeSpans(resourceAttributes, scopeName, scopeVersion).Spans().AppendEmpty())
+	return nil
+}
+
+func (b *tracesBatch) lookupScopeSpans(resourceAttributes pcommon.Map, scopeName, scopeVersion string) ptrace.ScopeSpans {
+	rKey := attributesToKey(resourceAttributes)
+	resourceSpans, found := b.rsByResource[rKey]
+	if !found {
+		resourceSpans = b.traces.ResourceSpans().AppendEmpty()
+		resourceAttributes.CopyTo(resourceSpans.Resource().Attributes())
+		b.rsByResource[rKey] = resourceSpans
+		b.ssByScope[rKey] = make(map[string]ptrace.ScopeSpans)
+	}
+
+	sKey := scopeName + ":" + scopeVersion
+	scopeSpans, found := b.ssByScope[rKey][sKey]
+	if !found {
+		scopeSpans = resourceSpans.ScopeSpans().AppendEmpty()
+		scopeSpans.Scope().SetName(scopeName)
+		scopeSpans.Scope().SetVersion(scopeVersion)
+		b.ssByScope[rKey][sKey] = scopeSpans
+	}
+	return scopeSpans
+}
+
+// attributesToKey builds a lookup key from sorted attributes.
+func attributesToKey(attributes pcommon.Map) string {
+	var key strings.Builder
+	attributes.Range(func(k string, v pcommon.Value) bool {
+		key.WriteString(k)
+		key.WriteByte('=')
+		key.WriteString(v.AsString())
+		key.WriteByte(';')
+		return true
+	})
+	return key.String()
+}
+
+// insertAttribute adds a Telegraf tag or field value to the attributes using
+// the closest OTLP value type.
+func insertAttribute(attributes pcommon.Map, key string, value interface{}, logger common.Logger) {
+	switch v := value.(type) {
+	case string:
+		attributes.UpsertString(key, v)
+	case bool:
+		attributes.UpsertBool(key, v)
+	case int64:
+		attributes.UpsertInt(key, v)
+	case uint64:
+		attributes.UpsertInt(key, int64(v))
+	case float64:
+		attributes.UpsertDouble(key, v)
+	default:
+		logger.Debug("unsupported attribute value type", "key", key, "type", fmt.Sprintf("%T", value))
+	}
+}
+
+func parseTraceID(value interface{}) (pcommon.TraceID, error) {
+	var id [16]byte
+	if err := decodeHexID(value, id[:]); err != nil {
+		return pcommon.InvalidTraceID(), fmt.Errorf("invalid trace ID: %w", err)
+	}
+	return pcommon.NewTraceID(id), nil
+}
+
+func parseSpanID(value interface{}) (pcommon.SpanID, error) {
+	var id [8]byte
+	if err := decodeHexID(value, id[:]); err != nil {
+		return pcommon.InvalidSpanID(), fmt.Errorf("invalid span ID: %w", err)
+	}
+	return pcommon.NewSpanID(id), nil
+}
+
+func decodeHexID(value interface{}, dst []byte) error {
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("unexpected type %T", value)
+	}
+	if hex.DecodedLen(len(s)) != len(dst) {
+		return fmt.Errorf("%q has wrong length", s)
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+func parseSpanKind(s string) ptrace.SpanKind {
+	for _, kind := range spanKinds {
+		if kind.String() == s {
+			return kind
+		}
+	}
+	return ptrace.SpanKindUnspecified
+}
@@ -0,0 +1,83 @@
+package opentelemetry
+
+import (
+	"fmt"
+	"regexp"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/influxdata/telegraf/filter"
+)
+
+// ucumPattern is a rough check of the UCUM case sensitive syntax: printable
+// ASCII without spaces, where annotations in curly braces may contain any
+// printable character.
+var ucumPattern = regexp.MustCompile(`^(?:[!-z|~]|\{[ -z|~]*\})+$`)
+
+// unitMapping sets the unit of the fields matching the pattern.
+type unitMapping struct {
+	filter filter.Filter
+	unit   string
+}
+
+// compileUnits compiles the units table. As for metric_types, longer
+// patterns take precedence.
+func (o *OpenTelemetry) compileUnits() error {
+	patterns := sortedPatterns(o.Units)
+	o.units = make([]unitMapping, 0, len(patterns))
+	for _, pattern := range patterns {
+		unit := o.Units[pattern]
+		if !ucumPattern.MatchString(unit) {
+			o.Log.Warnf("Unit %q of %q does not follow the UCUM syntax", unit, pattern)
+		}
+		f, err := filter.Compile([]string{pattern})
+		if err != nil {
+			return fmt.Errorf("invalid units pattern %q: %w", pattern, err)
+		}
+		o.units = append(o.units, unitMapping{filter: f, unit: unit})
+	}
+	return nil
+}
+
+// splitTag returns the tags without the given tag and the value it holds,
+// such as the unit of the unit_tag.
+func splitTag(tags map[string]string, key string) (map[string]string, string) {
+	value, ok := tags[key]
+	if key == "" || !ok {
+		return tags, ""
+	}
+	result := make(map[string]string, len(tags)-1)
+	for k, v := range tags {
+		if k != key {
+			result[k] = v
+		}
+	}
+	return result, value
+}
+
+// unitOf returns the unit of the field from the units table, falling back to
+// the unit tag of the metric.
+func (o *OpenTelemetry) unitOf(measurement, field, unitTag string) string {
+	for _, mapping := range o.units {
+		if mapping.filter.Match(measurement+"."+field) || mapping.filter.Match(measurement) {
+			return mapping.unit
+		}
+	}
+	return unitTag
+}
+
+// setUnits sets the unit of the metrics with the given names.
+func setUnits(metrics pmetric.Metrics, units map[string]string) {
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+				if unit, ok := units[metric.Name()]; ok {
+					metric.SetUnit(unit)
+				}
+			}
+		}
+	}
+}